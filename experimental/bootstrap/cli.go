@@ -6,6 +6,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit.aws/plugin/instance"
 	"github.com/docker/infrakit/pkg/spi/group"
 	"github.com/spf13/cobra"
@@ -56,6 +57,34 @@ func (c *clusterIDFlags) flags() *pflag.FlagSet {
 	return clusterIDFlags
 }
 
+// sessionOptions configure the sessions of the AWS API clients.  The region is that of the cluster.
+var sessionOptions instance.SessionOptions
+
+func sessionFlags() *pflag.FlagSet {
+	sessionFlags := pflag.NewFlagSet("session", pflag.ExitOnError)
+	sessionOptions.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "region" {
+			sessionFlags.AddFlag(flag)
+		}
+	})
+	return sessionFlags
+}
+
+// auditOptions configure the journal of the AWS API operations of the commands.
+var auditOptions audit.Options
+
+// auditCall journals the AWS API operations of the command, if the journal is configured.
+var auditCall *audit.Call
+
+// beginAudit opens the journal, and journals the operations of the command as made for the command on the cluster.
+func beginAudit(command string, cluster clusterID) {
+	journal, err := auditOptions.Open()
+	if err != nil {
+		abort("%s", err)
+	}
+	auditCall = journal.Auditor("infrakitctl").Begin(command, "", map[string]string{clusterTag: cluster.name})
+}
+
 func (c *clusterIDFlags) valid() bool {
 	return c.ID.region != "" && c.ID.name != ""
 }
@@ -130,6 +159,7 @@ func (a *CLI) AddCommands(root *cobra.Command) {
 				spec.applyDefaults()
 			}
 
+			beginAudit("create", spec.cluster())
 			err := bootstrap(spec)
 			if err != nil {
 				abort(err.Error())
@@ -137,6 +167,8 @@ func (a *CLI) AddCommands(root *cobra.Command) {
 		},
 	}
 	createCmd.Flags().AddFlagSet(cluster.flags())
+	createCmd.Flags().AddFlagSet(sessionFlags())
+	createCmd.Flags().AddFlagSet(auditOptions.Flags())
 	createCmd.Flags().StringVar(&keyName, "key", "", "The existing SSH key in AWS to use for provisioned instances")
 	createCmd.Flags().IntVar(&workerSize, "worker_size", workerSize, "Size of worker group")
 
@@ -165,6 +197,7 @@ The cluster may be identified manually or based on the contents of a cluster spe
 				id = spec.cluster()
 			}
 
+			beginAudit("destroy", id)
 			err := destroy(id)
 			if err != nil {
 				abort(err.Error())
@@ -174,12 +207,9 @@ The cluster may be identified manually or based on the contents of a cluster spe
 	destroyCmd.Flags().StringVar(&clusterSpec, "config", "", "A cluster spec file")
 
 	destroyCmd.Flags().AddFlagSet(cluster.flags())
+	destroyCmd.Flags().AddFlagSet(sessionFlags())
+	destroyCmd.Flags().AddFlagSet(auditOptions.Flags())
 	root.AddCommand(&destroyCmd)
-}
-
-type logger struct {
-}
 
-func (l logger) Log(args ...interface{}) {
-	log.Println(args)
+	root.AddCommand(audit.Command())
 }
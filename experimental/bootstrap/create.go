@@ -608,7 +608,10 @@ const (
 )
 
 func bootstrap(spec clusterSpec) error {
-	sess := spec.cluster().getAWSClient()
+	sess, err := spec.cluster().getAWSClient()
+	if err != nil {
+		return err
+	}
 
 	keyNames := []*string{}
 	for _, g := range spec.Groups {
@@ -616,7 +619,7 @@ func bootstrap(spec clusterSpec) error {
 	}
 
 	ec2Client := ec2.New(sess)
-	_, err := ec2Client.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+	_, err = ec2Client.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
 		KeyNames: keyNames,
 	})
 	if err != nil {
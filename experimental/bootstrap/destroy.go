@@ -228,7 +228,10 @@ func destroyNetwork(config client.ConfigProvider, cluster clusterID, vpcID strin
 }
 
 func destroy(cluster clusterID) error {
-	sess := cluster.getAWSClient()
+	sess, err := cluster.getAWSClient()
+	if err != nil {
+		return err
+	}
 	ec2Client := ec2.New(sess)
 
 	vpcs, err := ec2Client.DescribeVpcs(&ec2.DescribeVpcsInput{Filters: []*ec2.Filter{cluster.clusterFilter()}})
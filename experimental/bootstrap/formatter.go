@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	infrakit_instance "github.com/docker/infrakit.aws/plugin/instance"
 	"strings"
 	"text/template"
 	"time"
@@ -23,18 +24,20 @@ func formatVolumes(config client.ConfigProvider, spec clusterSpec, volumeIDs []*
 	// On the host OS we are using to format, mounted block devices appear as '/dev/xvdX' even though we ask EC2
 	// to mount as '/dev/sdX'.  This behavior is explicitly mentioned in
 	// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/device_naming.html#device-name-limits
-	volumeLetters := []string{"f", "g", "h", "i", "j", "k", "l"}
+	deviceNames, err := infrakit_instance.AllocateDeviceNames(nil, len(volumeIDs))
+	if err != nil {
+		return err
+	}
 	volumeToName := map[string]string{}
 	hostDeviceNames := []string{}
 	for index, volumeID := range volumeIDs {
-		letter := volumeLetters[index]
-		volumeToName[*volumeID] = fmt.Sprintf("/dev/sd%s", letter)
-		hostDeviceNames = append(hostDeviceNames, fmt.Sprintf("/dev/xvd%s", letter))
+		volumeToName[*volumeID] = deviceNames[index]
+		hostDeviceNames = append(hostDeviceNames, strings.Replace(deviceNames[index], "/dev/sd", "/dev/xvd", 1))
 	}
 
 	userDataTemplate := template.Must(template.New("userdata").Parse(userData))
 	buffer := bytes.Buffer{}
-	err := userDataTemplate.Execute(&buffer, map[string]string{"Devices": strings.Join(hostDeviceNames, " ")})
+	err = userDataTemplate.Execute(&buffer, map[string]string{"Devices": strings.Join(hostDeviceNames, " ")})
 	if err != nil {
 		return fmt.Errorf("Failed to generate UserData: %s", err)
 	}
@@ -5,10 +5,6 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/docker/infrakit.aws/plugin/instance"
 	"github.com/docker/infrakit/pkg/spi/group"
@@ -26,18 +22,14 @@ type clusterID struct {
 	name   string
 }
 
-func (c clusterID) getAWSClient() client.ConfigProvider {
-	providers := []credentials.Provider{
-		&ec2rolecreds.EC2RoleProvider{Client: ec2metadata.New(session.New())},
-		&credentials.EnvProvider{},
-		&credentials.SharedCredentialsProvider{},
+func (c clusterID) getAWSClient() (client.ConfigProvider, error) {
+	options := sessionOptions
+	options.Region = c.region
+	sess, err := instance.NewSession(options)
+	if err != nil {
+		return nil, err
 	}
-
-	return session.New(aws.NewConfig().
-		WithRegion(c.region).
-		WithCredentialsChainVerboseErrors(true).
-		WithCredentials(credentials.NewChainCredentials(providers)).
-		WithLogger(&logger{}))
+	return auditCall.Client(sess), nil
 }
 
 func (c clusterID) resourceFilter(vpcID string) []*ec2.Filter {
@@ -0,0 +1,391 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+)
+
+// autoScalingPageSize is the default number of groups or launch configurations of a page.
+const autoScalingPageSize = 50
+
+// AutoScaling is a fake of the AutoScaling API, which supports groups, their lifecycle hooks, and launch
+// configurations.  Groups launch no instances.
+type AutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	Failures
+
+	lock                 sync.Mutex
+	groups               map[string]*autoscaling.Group
+	lifecycleHooks       map[string]map[string]*autoscaling.LifecycleHook
+	launchConfigurations map[string]*autoscaling.LaunchConfiguration
+}
+
+// NewAutoScaling returns a fake of the AutoScaling API.
+func NewAutoScaling() *AutoScaling {
+	return &AutoScaling{
+		groups:               map[string]*autoscaling.Group{},
+		lifecycleHooks:       map[string]map[string]*autoscaling.LifecycleHook{},
+		launchConfigurations: map[string]*autoscaling.LaunchConfiguration{},
+	}
+}
+
+// autoScalingARN returns the ARN of a group or launch configuration.
+func autoScalingARN(kind, name string) string {
+	return fmt.Sprintf("arn:aws:autoscaling:%s:%s:%s:00000000-0000-0000-0000-000000000000:%sName/%s", Region, Account,
+		kind, kind, name)
+}
+
+// validationError returns a ValidationError, which AutoScaling returns for most invalid requests.
+func validationError(format string, args ...interface{}) error {
+	return newError(400, "ValidationError", format, args...)
+}
+
+// namesOf returns the requested names, or all the names of the map, a map by name, sorted.  Names not found are
+// ignored, as in AWS.
+func namesOf(m interface{}, requested []*string) []string {
+	all := sortedIDs(m)
+	if len(requested) == 0 {
+		return all
+	}
+	names := []string{}
+	for _, name := range requested {
+		i := sort.SearchStrings(all, aws.StringValue(name))
+		if i < len(all) && all[i] == *name {
+			names = append(names, *name)
+		}
+	}
+	return names
+}
+
+// CreateAutoScalingGroup creates a group with a unique name, of an existing launch configuration.
+func (f *AutoScaling) CreateAutoScalingGroup(input *autoscaling.CreateAutoScalingGroupInput) (
+	*autoscaling.CreateAutoScalingGroupOutput, error) {
+	if err := f.call("CreateAutoScalingGroup", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.AutoScalingGroupName)
+	if _, has := f.groups[name]; has {
+		return nil, newError(400, "AlreadyExists", "AutoScalingGroup by this name already exists - A group with the "+
+			"name %s already exists", name)
+	}
+	if _, has := f.launchConfigurations[aws.StringValue(input.LaunchConfigurationName)]; !has {
+		return nil, validationError("Launch configuration name not found - Launch configuration %s not found",
+			aws.StringValue(input.LaunchConfigurationName))
+	}
+	if len(input.AvailabilityZones) == 0 && aws.StringValue(input.VPCZoneIdentifier) == "" {
+		return nil, validationError("At least one Availability Zone or VPC Subnet is required.")
+	}
+	desired := input.DesiredCapacity
+	if desired == nil {
+		desired = input.MinSize
+	}
+	if aws.Int64Value(input.MinSize) > aws.Int64Value(input.MaxSize) ||
+		aws.Int64Value(desired) < aws.Int64Value(input.MinSize) ||
+		aws.Int64Value(desired) > aws.Int64Value(input.MaxSize) {
+		return nil, validationError("Desired capacity:%d must be between the specified min size:%d and max size:%d",
+			aws.Int64Value(desired), aws.Int64Value(input.MinSize), aws.Int64Value(input.MaxSize))
+	}
+
+	zones := input.AvailabilityZones
+	if len(zones) == 0 {
+		zones = []*string{aws.String(DefaultAvailabilityZone)}
+	}
+	cooldown := input.DefaultCooldown
+	if cooldown == nil {
+		cooldown = aws.Int64(300)
+	}
+	healthCheckType := input.HealthCheckType
+	if healthCheckType == nil {
+		healthCheckType = aws.String("EC2")
+	}
+	terminationPolicies := input.TerminationPolicies
+	if len(terminationPolicies) == 0 {
+		terminationPolicies = []*string{aws.String("Default")}
+	}
+	group := &autoscaling.Group{
+		AutoScalingGroupARN:              aws.String(autoScalingARN("autoScalingGroup", name)),
+		AutoScalingGroupName:             aws.String(name),
+		AvailabilityZones:                zones,
+		CreatedTime:                      aws.Time(time.Now()),
+		DefaultCooldown:                  cooldown,
+		DesiredCapacity:                  desired,
+		EnabledMetrics:                   []*autoscaling.EnabledMetric{},
+		HealthCheckGracePeriod:           aws.Int64(aws.Int64Value(input.HealthCheckGracePeriod)),
+		HealthCheckType:                  healthCheckType,
+		Instances:                        []*autoscaling.Instance{},
+		LaunchConfigurationName:          input.LaunchConfigurationName,
+		LoadBalancerNames:                input.LoadBalancerNames,
+		MaxSize:                          input.MaxSize,
+		MinSize:                          input.MinSize,
+		NewInstancesProtectedFromScaleIn: aws.Bool(aws.BoolValue(input.NewInstancesProtectedFromScaleIn)),
+		PlacementGroup:                   input.PlacementGroup,
+		SuspendedProcesses:               []*autoscaling.SuspendedProcess{},
+		Tags:                             []*autoscaling.TagDescription{},
+		TargetGroupARNs:                  input.TargetGroupARNs,
+		TerminationPolicies:              terminationPolicies,
+		VPCZoneIdentifier:                input.VPCZoneIdentifier,
+	}
+	for _, tag := range input.Tags {
+		group.Tags = append(group.Tags, &autoscaling.TagDescription{
+			Key:               tag.Key,
+			PropagateAtLaunch: aws.Bool(aws.BoolValue(tag.PropagateAtLaunch)),
+			ResourceId:        aws.String(name),
+			ResourceType:      aws.String("auto-scaling-group"),
+			Value:             tag.Value,
+		})
+	}
+	f.groups[name] = awsutil.CopyOf(group).(*autoscaling.Group)
+	f.lifecycleHooks[name] = map[string]*autoscaling.LifecycleHook{}
+	return &autoscaling.CreateAutoScalingGroupOutput{}, nil
+}
+
+// DescribeAutoScalingGroups describes the named groups, or all of them, in pages of MaxRecords.  Groups not found
+// are ignored.
+func (f *AutoScaling) DescribeAutoScalingGroups(input *autoscaling.DescribeAutoScalingGroupsInput) (
+	*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	if err := f.call("DescribeAutoScalingGroups", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := namesOf(f.groups, input.AutoScalingGroupNames)
+	start, end, next, err := page(len(names), input.NextToken, pageSize(input.MaxRecords, autoScalingPageSize))
+	if err != nil {
+		return nil, err
+	}
+	output := &autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: []*autoscaling.Group{}, NextToken: next}
+	for _, name := range names[start:end] {
+		output.AutoScalingGroups = append(output.AutoScalingGroups,
+			awsutil.CopyOf(f.groups[name]).(*autoscaling.Group))
+	}
+	return output, nil
+}
+
+// DeleteAutoScalingGroup deletes a group, with its lifecycle hooks.
+func (f *AutoScaling) DeleteAutoScalingGroup(input *autoscaling.DeleteAutoScalingGroupInput) (
+	*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	if err := f.call("DeleteAutoScalingGroup", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.AutoScalingGroupName)
+	if _, has := f.groups[name]; !has {
+		return nil, validationError("AutoScalingGroup name not found - AutoScalingGroup '%s' not found", name)
+	}
+	delete(f.groups, name)
+	delete(f.lifecycleHooks, name)
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+// PutLifecycleHook creates or updates a lifecycle hook of a group.
+func (f *AutoScaling) PutLifecycleHook(input *autoscaling.PutLifecycleHookInput) (
+	*autoscaling.PutLifecycleHookOutput, error) {
+	if err := f.call("PutLifecycleHook", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.AutoScalingGroupName)
+	hooks, has := f.lifecycleHooks[name]
+	if !has {
+		return nil, validationError("Group %s not found", name)
+	}
+	switch aws.StringValue(input.LifecycleTransition) {
+	case "autoscaling:EC2_INSTANCE_LAUNCHING", "autoscaling:EC2_INSTANCE_TERMINATING":
+	default:
+		if _, has := hooks[aws.StringValue(input.LifecycleHookName)]; !has {
+			return nil, validationError("Invalid Lifecycle Transition name %s",
+				aws.StringValue(input.LifecycleTransition))
+		}
+	}
+
+	hook, has := hooks[aws.StringValue(input.LifecycleHookName)]
+	if !has {
+		hook = &autoscaling.LifecycleHook{
+			AutoScalingGroupName: aws.String(name),
+			DefaultResult:        aws.String("ABANDON"),
+			GlobalTimeout:        aws.Int64(172800),
+			HeartbeatTimeout:     aws.Int64(3600),
+			LifecycleHookName:    input.LifecycleHookName,
+		}
+		hooks[*input.LifecycleHookName] = hook
+	}
+	if input.DefaultResult != nil {
+		hook.DefaultResult = input.DefaultResult
+	}
+	if input.HeartbeatTimeout != nil {
+		hook.HeartbeatTimeout = input.HeartbeatTimeout
+	}
+	if input.LifecycleTransition != nil {
+		hook.LifecycleTransition = input.LifecycleTransition
+	}
+	if input.NotificationMetadata != nil {
+		hook.NotificationMetadata = input.NotificationMetadata
+	}
+	if input.NotificationTargetARN != nil {
+		hook.NotificationTargetARN = input.NotificationTargetARN
+	}
+	if input.RoleARN != nil {
+		hook.RoleARN = input.RoleARN
+	}
+	return &autoscaling.PutLifecycleHookOutput{}, nil
+}
+
+// DescribeLifecycleHooks describes the named lifecycle hooks of a group, or all of them.
+func (f *AutoScaling) DescribeLifecycleHooks(input *autoscaling.DescribeLifecycleHooksInput) (
+	*autoscaling.DescribeLifecycleHooksOutput, error) {
+	if err := f.call("DescribeLifecycleHooks", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.AutoScalingGroupName)
+	hooks, has := f.lifecycleHooks[name]
+	if !has {
+		return nil, validationError("Group %s not found", name)
+	}
+	output := &autoscaling.DescribeLifecycleHooksOutput{LifecycleHooks: []*autoscaling.LifecycleHook{}}
+	for _, hookName := range namesOf(hooks, input.LifecycleHookNames) {
+		output.LifecycleHooks = append(output.LifecycleHooks,
+			awsutil.CopyOf(hooks[hookName]).(*autoscaling.LifecycleHook))
+	}
+	return output, nil
+}
+
+// CreateLaunchConfiguration creates a launch configuration with a unique name.
+func (f *AutoScaling) CreateLaunchConfiguration(input *autoscaling.CreateLaunchConfigurationInput) (
+	*autoscaling.CreateLaunchConfigurationOutput, error) {
+	if err := f.call("CreateLaunchConfiguration", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LaunchConfigurationName)
+	if _, has := f.launchConfigurations[name]; has {
+		return nil, newError(400, "AlreadyExists", "Launch Configuration by this name already exists - A launch "+
+			"configuration already exists with the name %s", name)
+	}
+	if input.ImageId == nil || input.InstanceType == nil {
+		return nil, validationError("Both ImageId and InstanceType are required unless InstanceId is set")
+	}
+	monitoring := input.InstanceMonitoring
+	if monitoring == nil {
+		monitoring = &autoscaling.InstanceMonitoring{Enabled: aws.Bool(true)}
+	}
+	f.launchConfigurations[name] = awsutil.CopyOf(&autoscaling.LaunchConfiguration{
+		AssociatePublicIpAddress:     input.AssociatePublicIpAddress,
+		BlockDeviceMappings:          input.BlockDeviceMappings,
+		ClassicLinkVPCId:             input.ClassicLinkVPCId,
+		ClassicLinkVPCSecurityGroups: input.ClassicLinkVPCSecurityGroups,
+		CreatedTime:                  aws.Time(time.Now()),
+		EbsOptimized:                 aws.Bool(aws.BoolValue(input.EbsOptimized)),
+		IamInstanceProfile:           input.IamInstanceProfile,
+		ImageId:                      input.ImageId,
+		InstanceMonitoring:           monitoring,
+		InstanceType:                 input.InstanceType,
+		KernelId:                     aws.String(aws.StringValue(input.KernelId)),
+		KeyName:                      aws.String(aws.StringValue(input.KeyName)),
+		LaunchConfigurationARN:       aws.String(autoScalingARN("launchConfiguration", name)),
+		LaunchConfigurationName:      aws.String(name),
+		PlacementTenancy:             input.PlacementTenancy,
+		RamdiskId:                    aws.String(aws.StringValue(input.RamdiskId)),
+		SecurityGroups:               input.SecurityGroups,
+		SpotPrice:                    input.SpotPrice,
+		UserData:                     aws.String(aws.StringValue(input.UserData)),
+	}).(*autoscaling.LaunchConfiguration)
+	return &autoscaling.CreateLaunchConfigurationOutput{}, nil
+}
+
+// DescribeLaunchConfigurations describes the named launch configurations, or all of them, in pages of MaxRecords.
+// Launch configurations not found are ignored.
+func (f *AutoScaling) DescribeLaunchConfigurations(input *autoscaling.DescribeLaunchConfigurationsInput) (
+	*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	if err := f.call("DescribeLaunchConfigurations", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := namesOf(f.launchConfigurations, input.LaunchConfigurationNames)
+	start, end, next, err := page(len(names), input.NextToken, pageSize(input.MaxRecords, autoScalingPageSize))
+	if err != nil {
+		return nil, err
+	}
+	output := &autoscaling.DescribeLaunchConfigurationsOutput{
+		LaunchConfigurations: []*autoscaling.LaunchConfiguration{},
+		NextToken:            next,
+	}
+	for _, name := range names[start:end] {
+		output.LaunchConfigurations = append(output.LaunchConfigurations,
+			awsutil.CopyOf(f.launchConfigurations[name]).(*autoscaling.LaunchConfiguration))
+	}
+	return output, nil
+}
+
+// DeleteLaunchConfiguration deletes a launch configuration that no group uses.
+func (f *AutoScaling) DeleteLaunchConfiguration(input *autoscaling.DeleteLaunchConfigurationInput) (
+	*autoscaling.DeleteLaunchConfigurationOutput, error) {
+	if err := f.call("DeleteLaunchConfiguration", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LaunchConfigurationName)
+	if _, has := f.launchConfigurations[name]; !has {
+		return nil, validationError("Launch configuration name not found - Launch configuration %s not found", name)
+	}
+	for _, group := range f.groups {
+		if aws.StringValue(group.LaunchConfigurationName) == name {
+			return nil, newError(400, "ResourceInUse", "Cannot delete launch configuration %s because it is "+
+				"attached to AutoScalingGroup %s", name, aws.StringValue(group.AutoScalingGroupName))
+		}
+	}
+	delete(f.launchConfigurations, name)
+	return &autoscaling.DeleteLaunchConfigurationOutput{}, nil
+}
+
+// waitForGroups waits until the requested groups exist, or not.
+func (f *AutoScaling) waitForGroups(input *autoscaling.DescribeAutoScalingGroupsInput, exist bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, name := range input.AutoScalingGroupNames {
+		if _, has := f.groups[aws.StringValue(name)]; has != exist {
+			return notReady(aws.StringValue(name))
+		}
+	}
+	return nil
+}
+
+// WaitUntilGroupExists waits until the groups exist.
+func (f *AutoScaling) WaitUntilGroupExists(input *autoscaling.DescribeAutoScalingGroupsInput) error {
+	return f.waitForGroups(input, true)
+}
+
+// WaitUntilGroupInService waits until the groups are in service, as soon as they exist since groups launch no
+// instances.
+func (f *AutoScaling) WaitUntilGroupInService(input *autoscaling.DescribeAutoScalingGroupsInput) error {
+	return f.waitForGroups(input, true)
+}
+
+// WaitUntilGroupNotExists waits until the groups are deleted.
+func (f *AutoScaling) WaitUntilGroupNotExists(input *autoscaling.DescribeAutoScalingGroupsInput) error {
+	return f.waitForGroups(input, false)
+}
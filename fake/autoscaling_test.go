@@ -0,0 +1,156 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ autoscalingiface.AutoScalingAPI       = NewAutoScaling()
+	_ cloudformationiface.CloudFormationAPI = NewCloudFormation()
+	_ cloudwatchlogsiface.CloudWatchLogsAPI = NewCloudWatchLogs()
+)
+
+func TestAutoScalingGroupsAndLaunchConfigurations(t *testing.T) {
+	f := NewAutoScaling()
+
+	group := &autoscaling.CreateAutoScalingGroupInput{
+		AutoScalingGroupName:    aws.String("workers"),
+		LaunchConfigurationName: aws.String("workers"),
+		MinSize:                 aws.Int64(1),
+		MaxSize:                 aws.Int64(3),
+		VPCZoneIdentifier:       aws.String(DefaultSubnetID),
+	}
+	_, err := f.CreateAutoScalingGroup(group)
+	requireCode(t, "ValidationError", err)
+
+	_, err = f.CreateLaunchConfiguration(&autoscaling.CreateLaunchConfigurationInput{
+		LaunchConfigurationName: aws.String("workers"),
+		ImageId:                 aws.String(DefaultImageID),
+		InstanceType:            aws.String("t2.micro"),
+	})
+	require.NoError(t, err)
+	_, err = f.CreateAutoScalingGroup(group)
+	require.NoError(t, err)
+	_, err = f.CreateAutoScalingGroup(group)
+	requireCode(t, "AlreadyExists", err)
+
+	_, err = f.PutLifecycleHook(&autoscaling.PutLifecycleHookInput{
+		AutoScalingGroupName: aws.String("workers"),
+		LifecycleHookName:    aws.String("workers_hook_0"),
+		LifecycleTransition:  aws.String("autoscaling:EC2_INSTANCE_TERMINATING"),
+	})
+	require.NoError(t, err)
+
+	groups, err := f.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String("workers"), aws.String("missing")},
+	})
+	require.NoError(t, err)
+	require.Len(t, groups.AutoScalingGroups, 1)
+	require.Equal(t, int64(1), *groups.AutoScalingGroups[0].DesiredCapacity)
+
+	_, err = f.DeleteLaunchConfiguration(&autoscaling.DeleteLaunchConfigurationInput{
+		LaunchConfigurationName: aws.String("workers"),
+	})
+	requireCode(t, "ResourceInUse", err)
+
+	_, err = f.DeleteAutoScalingGroup(&autoscaling.DeleteAutoScalingGroupInput{
+		AutoScalingGroupName: aws.String("workers"),
+		ForceDelete:          aws.Bool(true),
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.WaitUntilGroupNotExists(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String("workers")},
+	}))
+	_, err = f.DeleteLaunchConfiguration(&autoscaling.DeleteLaunchConfigurationInput{
+		LaunchConfigurationName: aws.String("workers"),
+	})
+	require.NoError(t, err)
+}
+
+func TestLogGroups(t *testing.T) {
+	f := NewCloudWatchLogs()
+	for _, name := range []string{"/infrakit/a", "/infrakit/b", "/other"} {
+		_, err := f.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String(name)})
+		require.NoError(t, err)
+	}
+	_, err := f.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String("/other")})
+	requireCode(t, "ResourceAlreadyExistsException", err)
+
+	_, err = f.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    aws.String("/infrakit/a"),
+		RetentionInDays: aws.Int64(2),
+	})
+	requireCode(t, "InvalidParameterException", err)
+	_, err = f.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    aws.String("/infrakit/a"),
+		RetentionInDays: aws.Int64(7),
+	})
+	require.NoError(t, err)
+
+	output, err := f.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String("/infrakit/"),
+		Limit:              aws.Int64(1),
+	})
+	require.NoError(t, err)
+	require.Len(t, output.LogGroups, 1)
+	require.Equal(t, int64(7), *output.LogGroups[0].RetentionInDays)
+	require.NotNil(t, output.NextToken)
+
+	output, err = f.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String("/infrakit/"),
+		NextToken:          output.NextToken,
+	})
+	require.NoError(t, err)
+	require.Len(t, output.LogGroups, 1)
+	require.Equal(t, "/infrakit/b", *output.LogGroups[0].LogGroupName)
+	require.Nil(t, output.NextToken)
+
+	_, err = f.DeleteLogGroup(&cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String("/missing")})
+	requireCode(t, "ResourceNotFoundException", err)
+}
+
+func TestStacks(t *testing.T) {
+	f := NewCloudFormation()
+	id := f.AddStack("cluster", cloudformation.StackResource{
+		LogicalResourceId:  aws.String("Vpc"),
+		PhysicalResourceId: aws.String(DefaultVpcID),
+		ResourceType:       aws.String("AWS::EC2::VPC"),
+	})
+
+	stacks, err := f.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String("cluster")})
+	require.NoError(t, err)
+	require.Equal(t, id, *stacks.Stacks[0].StackId)
+
+	resources, err := f.DescribeStackResources(&cloudformation.DescribeStackResourcesInput{
+		PhysicalResourceId: aws.String(DefaultVpcID),
+	})
+	require.NoError(t, err)
+	require.Len(t, resources.StackResources, 1)
+	require.Equal(t, "cluster", *resources.StackResources[0].StackName)
+
+	_, err = f.CreateStack(&cloudformation.CreateStackInput{
+		StackName:    aws.String("cluster"),
+		TemplateBody: aws.String("{}"),
+	})
+	requireCode(t, "AlreadyExistsException", err)
+	created, err := f.CreateStack(&cloudformation.CreateStackInput{
+		StackName:    aws.String("other"),
+		TemplateBody: aws.String("{}"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.WaitUntilStackCreateComplete(&cloudformation.DescribeStacksInput{StackName: created.StackId}))
+
+	_, err = f.DeleteStack(&cloudformation.DeleteStackInput{StackName: aws.String("cluster")})
+	require.NoError(t, err)
+	_, err = f.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String("cluster")})
+	requireCode(t, "ValidationError", err)
+}
@@ -0,0 +1,213 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+// CloudFormation is a fake of the CloudFormation API, which supports describing stacks and their resources.  Stacks
+// are created complete and provision nothing: their resources are seeded by AddStack.
+type CloudFormation struct {
+	cloudformationiface.CloudFormationAPI
+	Failures
+
+	lock      sync.Mutex
+	stacks    map[string]*cloudformation.Stack
+	resources map[string][]*cloudformation.StackResource
+}
+
+// NewCloudFormation returns a fake of the CloudFormation API.
+func NewCloudFormation() *CloudFormation {
+	return &CloudFormation{
+		stacks:    map[string]*cloudformation.Stack{},
+		resources: map[string][]*cloudformation.StackResource{},
+	}
+}
+
+// stackNotFound returns the error of a missing stack.
+func stackNotFound(name string) error {
+	return newError(400, "ValidationError", "Stack with id %s does not exist", name)
+}
+
+// stackNamed returns the live stack of a name or ID.
+func (f *CloudFormation) stackNamed(name string) *cloudformation.Stack {
+	for _, stack := range f.stacks {
+		if name == aws.StringValue(stack.StackName) || name == aws.StringValue(stack.StackId) {
+			return stack
+		}
+	}
+	return nil
+}
+
+// AddStack adds a complete stack with resources of logical IDs, types, and physical IDs, and returns its ID.
+func (f *CloudFormation) AddStack(name string, resources ...cloudformation.StackResource) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := f.addStack(name, nil, nil)
+	for _, resource := range resources {
+		resource := resource
+		resource.StackId = aws.String(id)
+		resource.StackName = aws.String(name)
+		if resource.ResourceStatus == nil {
+			resource.ResourceStatus = aws.String(cloudformation.ResourceStatusCreateComplete)
+		}
+		if resource.Timestamp == nil {
+			resource.Timestamp = aws.Time(time.Now())
+		}
+		f.resources[id] = append(f.resources[id], &resource)
+	}
+	return id
+}
+
+// addStack adds a complete stack, and returns its ID.
+func (f *CloudFormation) addStack(name string, parameters []*cloudformation.Parameter,
+	tags []*cloudformation.Tag) string {
+	id := fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%s", Region, Account, name, requestIDs.next("stack"))
+	f.stacks[id] = &cloudformation.Stack{
+		CreationTime:    aws.Time(time.Now()),
+		DisableRollback: aws.Bool(false),
+		Outputs:         []*cloudformation.Output{},
+		Parameters:      parameters,
+		StackId:         aws.String(id),
+		StackName:       aws.String(name),
+		StackStatus:     aws.String(cloudformation.StackStatusCreateComplete),
+		Tags:            tags,
+	}
+	f.resources[id] = []*cloudformation.StackResource{}
+	return id
+}
+
+// CreateStack creates a complete stack without resources.
+func (f *CloudFormation) CreateStack(input *cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput,
+	error) {
+	if err := f.call("CreateStack", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.StackName)
+	if f.stackNamed(name) != nil {
+		return nil, newError(400, "AlreadyExistsException", "Stack [%s] already exists", name)
+	}
+	if input.TemplateBody == nil && input.TemplateURL == nil {
+		return nil, newError(400, "ValidationError", "Either Template URL or Template Body must be specified.")
+	}
+	copied := awsutil.CopyOf(input).(*cloudformation.CreateStackInput)
+	id := f.addStack(name, copied.Parameters, copied.Tags)
+	return &cloudformation.CreateStackOutput{StackId: aws.String(id)}, nil
+}
+
+// DescribeStacks describes the stack of a name or ID, or all the live stacks.
+func (f *CloudFormation) DescribeStacks(input *cloudformation.DescribeStacksInput) (
+	*cloudformation.DescribeStacksOutput, error) {
+	if err := f.call("DescribeStacks", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	output := &cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{}}
+	if input.StackName != nil {
+		stack := f.stackNamed(*input.StackName)
+		if stack == nil {
+			return nil, stackNotFound(*input.StackName)
+		}
+		output.Stacks = append(output.Stacks, awsutil.CopyOf(stack).(*cloudformation.Stack))
+		return output, nil
+	}
+	for _, id := range sortedIDs(f.stacks) {
+		output.Stacks = append(output.Stacks, awsutil.CopyOf(f.stacks[id]).(*cloudformation.Stack))
+	}
+	return output, nil
+}
+
+// DescribeStackResources describes the resources of a stack, or the stack of a physical ID, optionally only that of
+// a logical ID.
+func (f *CloudFormation) DescribeStackResources(input *cloudformation.DescribeStackResourcesInput) (
+	*cloudformation.DescribeStackResourcesOutput, error) {
+	if err := f.call("DescribeStackResources", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var stack *cloudformation.Stack
+	switch {
+	case input.StackName != nil:
+		if stack = f.stackNamed(*input.StackName); stack == nil {
+			return nil, stackNotFound(*input.StackName)
+		}
+	case input.PhysicalResourceId != nil:
+		for id, resources := range f.resources {
+			for _, resource := range resources {
+				if aws.StringValue(resource.PhysicalResourceId) == *input.PhysicalResourceId {
+					stack = f.stacks[id]
+				}
+			}
+		}
+		if stack == nil {
+			return nil, newError(400, "ValidationError", "Stack for %s does not exist", *input.PhysicalResourceId)
+		}
+	default:
+		return nil, newError(400, "ValidationError", "Either StackName or PhysicalResourceId must be specified.")
+	}
+
+	output := &cloudformation.DescribeStackResourcesOutput{StackResources: []*cloudformation.StackResource{}}
+	for _, resource := range f.resources[*stack.StackId] {
+		if input.LogicalResourceId == nil || *input.LogicalResourceId == aws.StringValue(resource.LogicalResourceId) {
+			output.StackResources = append(output.StackResources,
+				awsutil.CopyOf(resource).(*cloudformation.StackResource))
+		}
+	}
+	return output, nil
+}
+
+// DeleteStack deletes a stack, with its resources.  Deleting a missing stack succeeds, as in AWS.
+func (f *CloudFormation) DeleteStack(input *cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput,
+	error) {
+	if err := f.call("DeleteStack", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if stack := f.stackNamed(aws.StringValue(input.StackName)); stack != nil {
+		delete(f.stacks, *stack.StackId)
+		delete(f.resources, *stack.StackId)
+	}
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+// waitForStack waits until the stack of the input exists, or not.
+func (f *CloudFormation) waitForStack(input *cloudformation.DescribeStacksInput, exist bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if (f.stackNamed(aws.StringValue(input.StackName)) != nil) != exist {
+		return notReady(aws.StringValue(input.StackName))
+	}
+	return nil
+}
+
+// WaitUntilStackExists waits until the stack exists.
+func (f *CloudFormation) WaitUntilStackExists(input *cloudformation.DescribeStacksInput) error {
+	return f.waitForStack(input, true)
+}
+
+// WaitUntilStackCreateComplete waits until the stack is created, as soon as it exists.
+func (f *CloudFormation) WaitUntilStackCreateComplete(input *cloudformation.DescribeStacksInput) error {
+	return f.waitForStack(input, true)
+}
+
+// WaitUntilStackDeleteComplete waits until the stack is deleted.
+func (f *CloudFormation) WaitUntilStackDeleteComplete(input *cloudformation.DescribeStacksInput) error {
+	return f.waitForStack(input, false)
+}
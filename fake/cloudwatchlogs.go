@@ -0,0 +1,145 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// logGroupPageSize is the default number of log groups of a page.
+const logGroupPageSize = 50
+
+// retentionDays are the valid retention periods of log groups.
+var retentionDays = map[int64]bool{
+	1: true, 3: true, 5: true, 7: true, 14: true, 30: true, 60: true, 90: true, 120: true, 150: true, 180: true,
+	365: true, 400: true, 545: true, 731: true, 1827: true, 3653: true,
+}
+
+// CloudWatchLogs is a fake of the CloudWatch Logs API, which supports log groups and their retention policies.
+type CloudWatchLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	Failures
+
+	lock      sync.Mutex
+	logGroups map[string]*cloudwatchlogs.LogGroup
+}
+
+// NewCloudWatchLogs returns a fake of the CloudWatch Logs API.
+func NewCloudWatchLogs() *CloudWatchLogs {
+	return &CloudWatchLogs{logGroups: map[string]*cloudwatchlogs.LogGroup{}}
+}
+
+// logGroupNotFound returns the error of a missing log group.
+func logGroupNotFound() error {
+	return newError(400, "ResourceNotFoundException", "The specified log group does not exist.")
+}
+
+// CreateLogGroup creates a log group with a unique name.
+func (f *CloudWatchLogs) CreateLogGroup(input *cloudwatchlogs.CreateLogGroupInput) (
+	*cloudwatchlogs.CreateLogGroupOutput, error) {
+	if err := f.call("CreateLogGroup", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LogGroupName)
+	if _, has := f.logGroups[name]; has {
+		return nil, newError(400, "ResourceAlreadyExistsException", "The specified log group already exists")
+	}
+	f.logGroups[name] = &cloudwatchlogs.LogGroup{
+		Arn:               aws.String(fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s:*", Region, Account, name)),
+		CreationTime:      aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
+		LogGroupName:      aws.String(name),
+		MetricFilterCount: aws.Int64(0),
+		StoredBytes:       aws.Int64(0),
+	}
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+// DescribeLogGroups describes the log groups of a name prefix, or all of them, in pages of Limit.
+func (f *CloudWatchLogs) DescribeLogGroups(input *cloudwatchlogs.DescribeLogGroupsInput) (
+	*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	if err := f.call("DescribeLogGroups", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := []string{}
+	for _, name := range sortedIDs(f.logGroups) {
+		if strings.HasPrefix(name, aws.StringValue(input.LogGroupNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	start, end, next, err := page(len(names), input.NextToken, pageSize(input.Limit, logGroupPageSize))
+	if err != nil {
+		return nil, err
+	}
+	output := &cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: []*cloudwatchlogs.LogGroup{}, NextToken: next}
+	for _, name := range names[start:end] {
+		output.LogGroups = append(output.LogGroups, awsutil.CopyOf(f.logGroups[name]).(*cloudwatchlogs.LogGroup))
+	}
+	return output, nil
+}
+
+// DeleteLogGroup deletes a log group.
+func (f *CloudWatchLogs) DeleteLogGroup(input *cloudwatchlogs.DeleteLogGroupInput) (
+	*cloudwatchlogs.DeleteLogGroupOutput, error) {
+	if err := f.call("DeleteLogGroup", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, has := f.logGroups[aws.StringValue(input.LogGroupName)]; !has {
+		return nil, logGroupNotFound()
+	}
+	delete(f.logGroups, aws.StringValue(input.LogGroupName))
+	return &cloudwatchlogs.DeleteLogGroupOutput{}, nil
+}
+
+// PutRetentionPolicy sets the retention period of a log group, which must be one of the periods AWS allows.
+func (f *CloudWatchLogs) PutRetentionPolicy(input *cloudwatchlogs.PutRetentionPolicyInput) (
+	*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	if err := f.call("PutRetentionPolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	logGroup, has := f.logGroups[aws.StringValue(input.LogGroupName)]
+	if !has {
+		return nil, logGroupNotFound()
+	}
+	if !retentionDays[aws.Int64Value(input.RetentionInDays)] {
+		return nil, newError(400, "InvalidParameterException", "1 validation error detected: Value '%d' at "+
+			"'retentionInDays' failed to satisfy constraint: Member must satisfy enum value set",
+			aws.Int64Value(input.RetentionInDays))
+	}
+	logGroup.RetentionInDays = aws.Int64(*input.RetentionInDays)
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+// DeleteRetentionPolicy removes the retention period of a log group, whose events then never expire.
+func (f *CloudWatchLogs) DeleteRetentionPolicy(input *cloudwatchlogs.DeleteRetentionPolicyInput) (
+	*cloudwatchlogs.DeleteRetentionPolicyOutput, error) {
+	if err := f.call("DeleteRetentionPolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	logGroup, has := f.logGroups[aws.StringValue(input.LogGroupName)]
+	if !has {
+		return nil, logGroupNotFound()
+	}
+	logGroup.RetentionInDays = nil
+	return &cloudwatchlogs.DeleteRetentionPolicyOutput{}, nil
+}
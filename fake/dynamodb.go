@@ -0,0 +1,184 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// dynamoDBPageSize is the default number of table names of a page.
+const dynamoDBPageSize = 100
+
+// DynamoDB is a fake of the DynamoDB API, which supports tables without items.
+type DynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	Failures
+
+	lock   sync.Mutex
+	tables map[string]*dynamodb.TableDescription
+}
+
+// NewDynamoDB returns a fake of the DynamoDB API.
+func NewDynamoDB() *DynamoDB {
+	return &DynamoDB{tables: map[string]*dynamodb.TableDescription{}}
+}
+
+// tableNotFound returns the error of a table not being found.
+func tableNotFound(name string) error {
+	return newError(400, "ResourceNotFoundException", "Requested resource not found: Table: %s not found", name)
+}
+
+// throughput returns the description of provisioned throughput.
+func throughput(throughput *dynamodb.ProvisionedThroughput) *dynamodb.ProvisionedThroughputDescription {
+	return &dynamodb.ProvisionedThroughputDescription{
+		NumberOfDecreasesToday: aws.Int64(0),
+		ReadCapacityUnits:      throughput.ReadCapacityUnits,
+		WriteCapacityUnits:     throughput.WriteCapacityUnits,
+	}
+}
+
+// CreateTable creates a table with a unique name, whose key attributes must be defined.  The table is active at
+// once but returned creating.
+func (f *DynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	if err := f.call("CreateTable", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, has := f.tables[name]; has {
+		return nil, newError(400, "ResourceInUseException", "Table already exists: %s", name)
+	}
+	defined := map[string]bool{}
+	for _, definition := range input.AttributeDefinitions {
+		defined[aws.StringValue(definition.AttributeName)] = true
+	}
+	for _, key := range input.KeySchema {
+		if !defined[aws.StringValue(key.AttributeName)] {
+			return nil, newError(400, "ValidationException", "One or more parameter values were invalid: Some index "+
+				"key attributes are not defined in AttributeDefinitions.")
+		}
+	}
+
+	arn := fmt.Sprintf("arn:aws:dynamodb:%s:%s:table/%s", Region, Account, name)
+	table := &dynamodb.TableDescription{
+		AttributeDefinitions:  input.AttributeDefinitions,
+		CreationDateTime:      aws.Time(time.Now()),
+		ItemCount:             aws.Int64(0),
+		KeySchema:             input.KeySchema,
+		ProvisionedThroughput: throughput(input.ProvisionedThroughput),
+		StreamSpecification:   input.StreamSpecification,
+		TableArn:              aws.String(arn),
+		TableName:             aws.String(name),
+		TableSizeBytes:        aws.Int64(0),
+		TableStatus:           aws.String(dynamodb.TableStatusActive),
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexArn:              aws.String(fmt.Sprintf("%s/index/%s", arn, aws.StringValue(index.IndexName))),
+			IndexName:             index.IndexName,
+			IndexSizeBytes:        aws.Int64(0),
+			IndexStatus:           aws.String(dynamodb.IndexStatusActive),
+			ItemCount:             aws.Int64(0),
+			KeySchema:             index.KeySchema,
+			Projection:            index.Projection,
+			ProvisionedThroughput: throughput(index.ProvisionedThroughput),
+		})
+	}
+	for _, index := range input.LocalSecondaryIndexes {
+		table.LocalSecondaryIndexes = append(table.LocalSecondaryIndexes, &dynamodb.LocalSecondaryIndexDescription{
+			IndexArn:       aws.String(fmt.Sprintf("%s/index/%s", arn, aws.StringValue(index.IndexName))),
+			IndexName:      index.IndexName,
+			IndexSizeBytes: aws.Int64(0),
+			ItemCount:      aws.Int64(0),
+			KeySchema:      index.KeySchema,
+			Projection:     index.Projection,
+		})
+	}
+	f.tables[name] = awsutil.CopyOf(table).(*dynamodb.TableDescription)
+
+	table.TableStatus = aws.String(dynamodb.TableStatusCreating)
+	return &dynamodb.CreateTableOutput{TableDescription: table}, nil
+}
+
+// DescribeTable describes a table.
+func (f *DynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	if err := f.call("DescribeTable", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	table, has := f.tables[aws.StringValue(input.TableName)]
+	if !has {
+		return nil, tableNotFound(aws.StringValue(input.TableName))
+	}
+	return &dynamodb.DescribeTableOutput{Table: awsutil.CopyOf(table).(*dynamodb.TableDescription)}, nil
+}
+
+// ListTables lists the names of the tables, in pages of Limit names after ExclusiveStartTableName.
+func (f *DynamoDB) ListTables(input *dynamodb.ListTablesInput) (*dynamodb.ListTablesOutput, error) {
+	if err := f.call("ListTables", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := []string{}
+	for name := range f.tables {
+		if name > aws.StringValue(input.ExclusiveStartTableName) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	output := &dynamodb.ListTablesOutput{}
+	if limit := pageSize(input.Limit, dynamoDBPageSize); len(names) > limit {
+		names = names[:limit]
+		output.LastEvaluatedTableName = aws.String(names[limit-1])
+	}
+	output.TableNames = aws.StringSlice(names)
+	return output, nil
+}
+
+// DeleteTable deletes a table, which is gone at once but returned deleting.
+func (f *DynamoDB) DeleteTable(input *dynamodb.DeleteTableInput) (*dynamodb.DeleteTableOutput, error) {
+	if err := f.call("DeleteTable", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	table, has := f.tables[name]
+	if !has {
+		return nil, tableNotFound(name)
+	}
+	delete(f.tables, name)
+
+	table.TableStatus = aws.String(dynamodb.TableStatusDeleting)
+	return &dynamodb.DeleteTableOutput{TableDescription: table}, nil
+}
+
+// WaitUntilTableExists waits until a table exists and is active.
+func (f *DynamoDB) WaitUntilTableExists(input *dynamodb.DescribeTableInput) error {
+	output, err := f.DescribeTable(input)
+	if err != nil || aws.StringValue(output.Table.TableStatus) != dynamodb.TableStatusActive {
+		return notReady(aws.StringValue(input.TableName))
+	}
+	return nil
+}
+
+// WaitUntilTableNotExists waits until a table does not exist.
+func (f *DynamoDB) WaitUntilTableNotExists(input *dynamodb.DescribeTableInput) error {
+	if _, err := f.DescribeTable(input); err == nil {
+		return notReady(aws.StringValue(input.TableName))
+	}
+	return nil
+}
@@ -0,0 +1,974 @@
+package fake
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+const (
+	// DefaultVpcID is the ID of the default VPC.
+	DefaultVpcID = "vpc-00000000"
+
+	// DefaultSubnetID is the ID of the default subnet of the default VPC.
+	DefaultSubnetID = "subnet-00000000"
+
+	// DefaultSecurityGroupID is the ID of the default security group of the default VPC.
+	DefaultSecurityGroupID = "sg-00000000"
+
+	// DefaultImageID is the ID of an image to run instances of, with an EBS root device /dev/xvda.
+	DefaultImageID = "ami-00000000"
+
+	// DefaultAvailabilityZone is the availability zone of the default subnet.
+	DefaultAvailabilityZone = Region + "a"
+)
+
+// ec2Info is the client info of the requests of the fake.
+var ec2Info = metadata.ClientInfo{ServiceName: ec2.ServiceName, APIVersion: "2016-09-15"}
+
+// The states of instances, and their codes.
+var instanceStateCodes = map[string]int64{
+	ec2.InstanceStateNamePending:      0,
+	ec2.InstanceStateNameRunning:      16,
+	ec2.InstanceStateNameShuttingDown: 32,
+	ec2.InstanceStateNameTerminated:   48,
+	ec2.InstanceStateNameStopping:     64,
+	ec2.InstanceStateNameStopped:      80,
+}
+
+// notFoundCodes are the error codes of resources not found, by the prefix of their IDs.
+var notFoundCodes = map[string]string{
+	"i":        "InvalidInstanceID.NotFound",
+	"vol":      "InvalidVolume.NotFound",
+	"snap":     "InvalidSnapshot.NotFound",
+	"ami":      "InvalidAMIID.NotFound",
+	"vpc":      "InvalidVpcID.NotFound",
+	"subnet":   "InvalidSubnetID.NotFound",
+	"sg":       "InvalidGroup.NotFound",
+	"igw":      "InvalidInternetGatewayID.NotFound",
+	"rtb":      "InvalidRouteTableID.NotFound",
+	"rtbassoc": "InvalidAssociationID.NotFound",
+	"eni":      "InvalidNetworkInterfaceID.NotFound",
+	"eipalloc": "InvalidAllocationID.NotFound",
+}
+
+// notFound returns the error of the resource with the ID not being found.
+func notFound(id string) error {
+	if code, has := notFoundCodes[strings.SplitN(id, "-", 2)[0]]; has {
+		return newError(400, code, "The ID '%s' does not exist", id)
+	}
+	return newError(400, "InvalidID", "The ID '%s' is not valid", id)
+}
+
+// EC2 is a fake of the EC2 API.  It starts with a default VPC, subnet and security group, and an image.
+type EC2 struct {
+	ec2iface.EC2API
+	Failures
+
+	lock sync.Mutex
+	ids  ids
+
+	// tags are the tags of the resources, by resource ID.
+	tags map[string]map[string]string
+
+	instances         map[string]*ec2.Instance
+	reservations      map[string]string
+	userData          map[string]string
+	noTermination     map[string]bool
+	volumes           map[string]*ec2.Volume
+	snapshots         map[string]*ec2.Snapshot
+	images            map[string]*ec2.Image
+	vpcs              map[string]*ec2.Vpc
+	vpcAttributes     map[string]*ec2.DescribeVpcAttributeOutput
+	subnets           map[string]*ec2.Subnet
+	securityGroups    map[string]*ec2.SecurityGroup
+	internetGateways  map[string]*ec2.InternetGateway
+	routeTables       map[string]*ec2.RouteTable
+	networkInterfaces map[string]*ec2.NetworkInterface
+	addresses         map[string]*ec2.Address
+	keyPairs          map[string]*ec2.KeyPairInfo
+}
+
+// NewEC2 returns a fake of the EC2 API.
+func NewEC2() *EC2 {
+	f := &EC2{
+		tags:              map[string]map[string]string{},
+		instances:         map[string]*ec2.Instance{},
+		reservations:      map[string]string{},
+		userData:          map[string]string{},
+		noTermination:     map[string]bool{},
+		volumes:           map[string]*ec2.Volume{},
+		snapshots:         map[string]*ec2.Snapshot{},
+		images:            map[string]*ec2.Image{},
+		vpcs:              map[string]*ec2.Vpc{},
+		vpcAttributes:     map[string]*ec2.DescribeVpcAttributeOutput{},
+		subnets:           map[string]*ec2.Subnet{},
+		securityGroups:    map[string]*ec2.SecurityGroup{},
+		internetGateways:  map[string]*ec2.InternetGateway{},
+		routeTables:       map[string]*ec2.RouteTable{},
+		networkInterfaces: map[string]*ec2.NetworkInterface{},
+		addresses:         map[string]*ec2.Address{},
+		keyPairs:          map[string]*ec2.KeyPairInfo{},
+	}
+
+	f.newVpc(DefaultVpcID, "172.31.0.0/16", true)
+	f.subnets[DefaultSubnetID] = &ec2.Subnet{
+		AvailabilityZone:        aws.String(DefaultAvailabilityZone),
+		AvailableIpAddressCount: aws.Int64(4091),
+		CidrBlock:               aws.String("172.31.0.0/20"),
+		DefaultForAz:            aws.Bool(true),
+		MapPublicIpOnLaunch:     aws.Bool(true),
+		State:                   aws.String(ec2.SubnetStateAvailable),
+		SubnetId:                aws.String(DefaultSubnetID),
+		VpcId:                   aws.String(DefaultVpcID),
+	}
+	f.images[DefaultImageID] = &ec2.Image{
+		Architecture: aws.String(ec2.ArchitectureValuesX8664),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs: &ec2.EbsBlockDevice{
+					DeleteOnTermination: aws.Bool(true),
+					VolumeSize:          aws.Int64(8),
+					VolumeType:          aws.String(ec2.VolumeTypeGp2),
+				},
+			},
+		},
+		ImageId:            aws.String(DefaultImageID),
+		ImageType:          aws.String(ec2.ImageTypeValuesMachine),
+		Name:               aws.String("default"),
+		OwnerId:            aws.String(Account),
+		Public:             aws.Bool(false),
+		RootDeviceName:     aws.String("/dev/xvda"),
+		RootDeviceType:     aws.String(ec2.DeviceTypeEbs),
+		State:              aws.String(ec2.ImageStateAvailable),
+		VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+	}
+	return f
+}
+
+// exists returns true if the resource with the ID exists.  Terminated instances exist, as they remain visible.
+func (f *EC2) exists(id string) bool {
+	prefix := strings.SplitN(id, "-", 2)[0]
+	found := false
+	switch prefix {
+	case "i":
+		_, found = f.instances[id]
+	case "vol":
+		_, found = f.volumes[id]
+	case "snap":
+		_, found = f.snapshots[id]
+	case "ami":
+		_, found = f.images[id]
+	case "vpc":
+		_, found = f.vpcs[id]
+	case "subnet":
+		_, found = f.subnets[id]
+	case "sg":
+		_, found = f.securityGroups[id]
+	case "igw":
+		_, found = f.internetGateways[id]
+	case "rtb":
+		_, found = f.routeTables[id]
+	case "eni":
+		_, found = f.networkInterfaces[id]
+	}
+	return found
+}
+
+// resourceType returns the type of the resource with the ID, as returned by DescribeTags.
+func resourceType(id string) string {
+	switch strings.SplitN(id, "-", 2)[0] {
+	case "i":
+		return ec2.ResourceTypeInstance
+	case "vol":
+		return ec2.ResourceTypeVolume
+	case "snap":
+		return ec2.ResourceTypeSnapshot
+	case "ami":
+		return ec2.ResourceTypeImage
+	case "vpc":
+		return ec2.ResourceTypeVpc
+	case "subnet":
+		return ec2.ResourceTypeSubnet
+	case "sg":
+		return ec2.ResourceTypeSecurityGroup
+	case "igw":
+		return ec2.ResourceTypeInternetGateway
+	case "rtb":
+		return ec2.ResourceTypeRouteTable
+	case "eni":
+		return ec2.ResourceTypeNetworkInterface
+	}
+	return ""
+}
+
+// tagsOf returns the tags of the resource.
+func (f *EC2) tagsOf(id string) []*ec2.Tag {
+	tags := f.tags[id]
+	if len(tags) == 0 {
+		return nil
+	}
+	result := []*ec2.Tag{}
+	for _, key := range sortedKeys(tags) {
+		result = append(result, &ec2.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return result
+}
+
+// removeTags removes the tags of a deleted resource.
+func (f *EC2) removeTags(id string) {
+	delete(f.tags, id)
+}
+
+// describeIDs returns the IDs of the resources of the map, a map of resources by ID, that are either those requested,
+// which must exist, or all the resources, sorted.
+func describeIDs(m interface{}, requested []*string) ([]string, error) {
+	all := map[string]bool{}
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		all[key.String()] = true
+	}
+
+	ids := []string{}
+	if len(requested) == 0 {
+		for id := range all {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return ids, nil
+	}
+	for _, id := range requested {
+		if !all[aws.StringValue(id)] {
+			return nil, notFound(aws.StringValue(id))
+		}
+		ids = append(ids, aws.StringValue(id))
+	}
+	return ids, nil
+}
+
+// sortedIDs returns the IDs of the resources of the map, a map of resources by ID, sorted.
+func sortedIDs(m interface{}) []string {
+	ids, _ := describeIDs(m, nil)
+	return ids
+}
+
+// notReady returns the error of a waiter for the resource with the ID.
+func notReady(id string) error {
+	return awserr.New("ResourceNotReady", fmt.Sprintf("failed waiting for successful resource state of %s", id), nil)
+}
+
+// CreateTags adds or overwrites tags of resources.
+func (f *EC2) CreateTags(input *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	if err := f.call("CreateTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, id := range input.Resources {
+		if !f.exists(aws.StringValue(id)) {
+			return nil, notFound(aws.StringValue(id))
+		}
+	}
+	for _, tag := range input.Tags {
+		if strings.HasPrefix(aws.StringValue(tag.Key), "aws:") {
+			return nil, newError(400, "InvalidParameterValue", "Tag keys starting with 'aws:' are reserved")
+		}
+	}
+	for _, id := range input.Resources {
+		tags, has := f.tags[*id]
+		if !has {
+			tags = map[string]string{}
+			f.tags[*id] = tags
+		}
+		for _, tag := range input.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DeleteTags deletes tags of resources, those with the values if set.
+func (f *EC2) DeleteTags(input *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	if err := f.call("DeleteTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, id := range input.Resources {
+		if !f.exists(aws.StringValue(id)) {
+			return nil, notFound(aws.StringValue(id))
+		}
+	}
+	for _, id := range input.Resources {
+		tags := f.tags[*id]
+		if len(input.Tags) == 0 {
+			delete(f.tags, *id)
+			continue
+		}
+		for _, tag := range input.Tags {
+			if tag.Value == nil || tags[aws.StringValue(tag.Key)] == *tag.Value {
+				delete(tags, aws.StringValue(tag.Key))
+			}
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+// DescribeTags describes the tags of resources, filtered by resource-id, resource-type, key and value.
+func (f *EC2) DescribeTags(input *ec2.DescribeTagsInput) (*ec2.DescribeTagsOutput, error) {
+	if err := f.call("DescribeTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	resources := []string{}
+	for id := range f.tags {
+		resources = append(resources, id)
+	}
+	sort.Strings(resources)
+
+	tags := []*ec2.TagDescription{}
+	for _, id := range resources {
+		for _, key := range sortedKeys(f.tags[id]) {
+			tag := &ec2.TagDescription{
+				Key:          aws.String(key),
+				ResourceId:   aws.String(id),
+				ResourceType: aws.String(resourceType(id)),
+				Value:        aws.String(f.tags[id][key]),
+			}
+			values := newFilterValues().
+				add("resource-id", tag.ResourceId).
+				add("resource-type", tag.ResourceType).
+				add("key", tag.Key).
+				add("value", tag.Value)
+			if match, err := matches(input.Filters, values, nil); err != nil {
+				return nil, err
+			} else if match {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	start, end, next, err := page(len(tags), input.NextToken, pageSize(input.MaxResults, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeTagsOutput{Tags: tags[start:end], NextToken: next}, nil
+}
+
+// newState returns the instance state of the name.
+func newState(name string) *ec2.InstanceState {
+	return &ec2.InstanceState{Code: aws.Int64(instanceStateCodes[name]), Name: aws.String(name)}
+}
+
+// stateName returns the name of the state of the instance.
+func stateName(instance *ec2.Instance) string {
+	return aws.StringValue(instance.State.Name)
+}
+
+// nextIP returns a new private IP address in the CIDR block, which the fake treats as a /16.
+func (f *EC2) nextIP(cidr string) string {
+	octets := strings.SplitN(cidr, ".", 3)
+	n := len(f.instances) + len(f.networkInterfaces) + 10
+	return fmt.Sprintf("%s.%s.%d.%d", octets[0], octets[1], n/250, n%250+4)
+}
+
+// RunInstances runs instances of an existing image, in an existing subnet, or the default subnet, which are running
+// at once but returned pending.  Instances have a primary network interface, which is created unless an existing
+// one is given, and an EBS root volume for an EBS root device of their image.
+func (f *EC2) RunInstances(input *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	if err := f.call("RunInstances", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	image, has := f.images[aws.StringValue(input.ImageId)]
+	if !has {
+		return nil, newError(400, "InvalidAMIID.NotFound", "The image id '[%s]' does not exist",
+			aws.StringValue(input.ImageId))
+	}
+	if aws.Int64Value(input.MinCount) < 1 || aws.Int64Value(input.MaxCount) < aws.Int64Value(input.MinCount) {
+		return nil, newError(400, "InvalidParameterValue", "Invalid count %d-%d",
+			aws.Int64Value(input.MinCount), aws.Int64Value(input.MaxCount))
+	}
+	if input.KeyName != nil {
+		if _, has := f.keyPairs[*input.KeyName]; !has {
+			return nil, newError(400, "InvalidKeyPair.NotFound", "The key pair '%s' does not exist", *input.KeyName)
+		}
+	}
+
+	var attach *ec2.NetworkInterface
+	subnetID := aws.StringValue(input.SubnetId)
+	for _, spec := range input.NetworkInterfaces {
+		if aws.Int64Value(spec.DeviceIndex) != 0 {
+			continue
+		}
+		if spec.NetworkInterfaceId != nil {
+			if attach = f.networkInterfaces[*spec.NetworkInterfaceId]; attach == nil {
+				return nil, notFound(*spec.NetworkInterfaceId)
+			}
+			if attach.Attachment != nil {
+				return nil, newError(400, "InvalidNetworkInterface.InUse", "Interface %s in use",
+					*spec.NetworkInterfaceId)
+			}
+			subnetID = aws.StringValue(attach.SubnetId)
+		} else if spec.SubnetId != nil {
+			subnetID = *spec.SubnetId
+		}
+	}
+	if subnetID == "" {
+		subnetID = DefaultSubnetID
+	}
+	subnet, has := f.subnets[subnetID]
+	if !has {
+		return nil, newError(400, "InvalidSubnetID.NotFound", "The subnet ID '%s' does not exist", subnetID)
+	}
+	if zone := input.Placement; zone != nil && zone.AvailabilityZone != nil &&
+		*zone.AvailabilityZone != aws.StringValue(subnet.AvailabilityZone) {
+		return nil, newError(400, "InvalidParameterValue", "The subnet %s is not in %s", subnetID,
+			*zone.AvailabilityZone)
+	}
+
+	groups := []*ec2.GroupIdentifier{}
+	for _, id := range input.SecurityGroupIds {
+		group, has := f.securityGroups[aws.StringValue(id)]
+		if !has {
+			return nil, notFound(aws.StringValue(id))
+		}
+		groups = append(groups, &ec2.GroupIdentifier{GroupId: group.GroupId, GroupName: group.GroupName})
+	}
+	for _, name := range input.SecurityGroups {
+		group := f.securityGroupNamed(aws.StringValue(name), aws.StringValue(subnet.VpcId))
+		if group == nil {
+			return nil, newError(400, "InvalidGroup.NotFound", "The security group '%s' does not exist",
+				aws.StringValue(name))
+		}
+		groups = append(groups, &ec2.GroupIdentifier{GroupId: group.GroupId, GroupName: group.GroupName})
+	}
+	if len(groups) == 0 {
+		if group := f.securityGroupNamed("default", aws.StringValue(subnet.VpcId)); group != nil {
+			groups = append(groups, &ec2.GroupIdentifier{GroupId: group.GroupId, GroupName: group.GroupName})
+		}
+	}
+
+	instanceType := aws.StringValue(input.InstanceType)
+	if instanceType == "" {
+		instanceType = ec2.InstanceTypeM1Small
+	}
+	var profile *ec2.IamInstanceProfile
+	if input.IamInstanceProfile != nil {
+		arn := aws.StringValue(input.IamInstanceProfile.Arn)
+		if arn == "" {
+			arn = fmt.Sprintf("arn:aws:iam::%s:instance-profile/%s", Account,
+				aws.StringValue(input.IamInstanceProfile.Name))
+		}
+		profile = &ec2.IamInstanceProfile{Arn: aws.String(arn), Id: aws.String(f.ids.next("AIPA"))}
+	}
+
+	reservation := &ec2.Reservation{
+		Groups:        []*ec2.GroupIdentifier{},
+		Instances:     []*ec2.Instance{},
+		OwnerId:       aws.String(Account),
+		ReservationId: aws.String(f.ids.next("r")),
+	}
+	now := time.Now()
+	for i := int64(0); i < aws.Int64Value(input.MaxCount); i++ {
+		id := f.ids.next("i")
+
+		eni := attach
+		if eni == nil || i > 0 {
+			eni = f.newNetworkInterface(subnet, groups, nil, "Primary network interface")
+			eni.Attachment = &ec2.NetworkInterfaceAttachment{DeleteOnTermination: aws.Bool(true)}
+		} else {
+			eni.Attachment = &ec2.NetworkInterfaceAttachment{DeleteOnTermination: aws.Bool(false)}
+		}
+		eni.Attachment.AttachTime = aws.Time(now)
+		eni.Attachment.AttachmentId = aws.String(f.ids.next("eni-attach"))
+		eni.Attachment.DeviceIndex = aws.Int64(0)
+		eni.Attachment.InstanceId = aws.String(id)
+		eni.Attachment.InstanceOwnerId = aws.String(Account)
+		eni.Attachment.Status = aws.String(ec2.AttachmentStatusAttached)
+		eni.Status = aws.String(ec2.NetworkInterfaceStatusInUse)
+		if input.PrivateIpAddress != nil && i == 0 {
+			eni.PrivateIpAddress = input.PrivateIpAddress
+		}
+
+		instance := &ec2.Instance{
+			AmiLaunchIndex:     aws.Int64(i),
+			Architecture:       image.Architecture,
+			EbsOptimized:       aws.Bool(aws.BoolValue(input.EbsOptimized)),
+			Hypervisor:         aws.String(ec2.HypervisorTypeXen),
+			IamInstanceProfile: profile,
+			ImageId:            image.ImageId,
+			InstanceId:         aws.String(id),
+			InstanceType:       aws.String(instanceType),
+			KeyName:            input.KeyName,
+			LaunchTime:         aws.Time(now),
+			Monitoring:         &ec2.Monitoring{State: aws.String(ec2.MonitoringStateDisabled)},
+			Placement: &ec2.Placement{
+				AvailabilityZone: subnet.AvailabilityZone,
+				Tenancy:          aws.String(ec2.TenancyDefault),
+			},
+			PrivateDnsName:   eni.PrivateDnsName,
+			PrivateIpAddress: eni.PrivateIpAddress,
+			RootDeviceName:   image.RootDeviceName,
+			RootDeviceType:   image.RootDeviceType,
+			SecurityGroups:   eni.Groups,
+			SourceDestCheck:  aws.Bool(true),
+			State:            newState(ec2.InstanceStateNameRunning),
+			SubnetId:         eni.SubnetId,
+			VpcId:            eni.VpcId,
+		}
+		if input.Placement != nil && input.Placement.GroupName != nil {
+			instance.Placement.GroupName = input.Placement.GroupName
+		}
+
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs == nil || aws.StringValue(mapping.DeviceName) != aws.StringValue(image.RootDeviceName) {
+				continue
+			}
+			volume := f.newVolume(&ec2.CreateVolumeInput{
+				AvailabilityZone: subnet.AvailabilityZone,
+				Size:             mapping.Ebs.VolumeSize,
+				SnapshotId:       mapping.Ebs.SnapshotId,
+				VolumeType:       mapping.Ebs.VolumeType,
+			})
+			volume.State = aws.String(ec2.VolumeStateInUse)
+			volume.Attachments = []*ec2.VolumeAttachment{
+				{
+					AttachTime:          aws.Time(now),
+					DeleteOnTermination: aws.Bool(aws.BoolValue(mapping.Ebs.DeleteOnTermination)),
+					Device:              mapping.DeviceName,
+					InstanceId:          aws.String(id),
+					State:               aws.String(ec2.VolumeAttachmentStateAttached),
+					VolumeId:            volume.VolumeId,
+				},
+			}
+		}
+
+		f.instances[id] = instance
+		f.reservations[id] = *reservation.ReservationId
+		if input.UserData != nil {
+			f.userData[id] = *input.UserData
+		}
+		f.noTermination[id] = aws.BoolValue(input.DisableApiTermination)
+
+		output := f.describeInstance(id)
+		output.State = newState(ec2.InstanceStateNamePending)
+		reservation.Instances = append(reservation.Instances, output)
+	}
+	return reservation, nil
+}
+
+// describeInstance returns a copy of the instance, with its tags, network interfaces and volumes.
+func (f *EC2) describeInstance(id string) *ec2.Instance {
+	instance := awsutil.CopyOf(f.instances[id]).(*ec2.Instance)
+	instance.Tags = f.tagsOf(id)
+
+	instance.NetworkInterfaces = []*ec2.InstanceNetworkInterface{}
+	for _, eniID := range sortedIDs(f.networkInterfaces) {
+		eni := f.networkInterfaces[eniID]
+		if eni.Attachment == nil || aws.StringValue(eni.Attachment.InstanceId) != id {
+			continue
+		}
+		instance.NetworkInterfaces = append(instance.NetworkInterfaces, &ec2.InstanceNetworkInterface{
+			Attachment: &ec2.InstanceNetworkInterfaceAttachment{
+				AttachTime:          eni.Attachment.AttachTime,
+				AttachmentId:        eni.Attachment.AttachmentId,
+				DeleteOnTermination: eni.Attachment.DeleteOnTermination,
+				DeviceIndex:         eni.Attachment.DeviceIndex,
+				Status:              eni.Attachment.Status,
+			},
+			Description:        eni.Description,
+			Groups:             eni.Groups,
+			MacAddress:         eni.MacAddress,
+			NetworkInterfaceId: eni.NetworkInterfaceId,
+			OwnerId:            eni.OwnerId,
+			PrivateDnsName:     eni.PrivateDnsName,
+			PrivateIpAddress:   eni.PrivateIpAddress,
+			SourceDestCheck:    eni.SourceDestCheck,
+			Status:             eni.Status,
+			SubnetId:           eni.SubnetId,
+			VpcId:              eni.VpcId,
+		})
+	}
+
+	instance.BlockDeviceMappings = []*ec2.InstanceBlockDeviceMapping{}
+	for _, volumeID := range sortedIDs(f.volumes) {
+		for _, attachment := range f.volumes[volumeID].Attachments {
+			if aws.StringValue(attachment.InstanceId) != id {
+				continue
+			}
+			instance.BlockDeviceMappings = append(instance.BlockDeviceMappings, &ec2.InstanceBlockDeviceMapping{
+				DeviceName: attachment.Device,
+				Ebs: &ec2.EbsInstanceBlockDevice{
+					AttachTime:          attachment.AttachTime,
+					DeleteOnTermination: attachment.DeleteOnTermination,
+					Status:              attachment.State,
+					VolumeId:            attachment.VolumeId,
+				},
+			})
+		}
+	}
+
+	for _, address := range f.addresses {
+		if aws.StringValue(address.InstanceId) == id {
+			instance.PublicIpAddress = address.PublicIp
+		}
+	}
+	return awsutil.CopyOf(instance).(*ec2.Instance)
+}
+
+// DescribeInstances describes instances, filtered by instance-id, instance-state-name, instance-type, image-id,
+// subnet-id, vpc-id, availability-zone, reservation-id, private-ip-address and tags, in pages of MaxResults.
+func (f *EC2) DescribeInstances(input *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	if err := f.call("DescribeInstances", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ids, err := describeIDs(f.instances, input.InstanceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []*ec2.Instance{}
+	for _, id := range ids {
+		instance := f.instances[id]
+		values := newFilterValues().
+			add("instance-id", instance.InstanceId).
+			add("instance-state-name", instance.State.Name).
+			add("instance-type", instance.InstanceType).
+			add("image-id", instance.ImageId).
+			add("subnet-id", instance.SubnetId).
+			add("vpc-id", instance.VpcId).
+			add("availability-zone", instance.Placement.AvailabilityZone).
+			add("reservation-id", aws.String(f.reservations[id])).
+			add("private-ip-address", instance.PrivateIpAddress)
+		if match, err := matches(input.Filters, values, f.tags[id]); err != nil {
+			return nil, err
+		} else if match {
+			instances = append(instances, f.describeInstance(id))
+		}
+	}
+
+	start, end, next, err := page(len(instances), input.NextToken, pageSize(input.MaxResults, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{}, NextToken: next}
+	for _, instance := range instances[start:end] {
+		id := f.reservations[*instance.InstanceId]
+		last := len(output.Reservations) - 1
+		if last < 0 || *output.Reservations[last].ReservationId != id {
+			output.Reservations = append(output.Reservations, &ec2.Reservation{
+				Groups:        []*ec2.GroupIdentifier{},
+				OwnerId:       aws.String(Account),
+				ReservationId: aws.String(id),
+			})
+			last++
+		}
+		output.Reservations[last].Instances = append(output.Reservations[last].Instances, instance)
+	}
+	return output, nil
+}
+
+// instancesOf returns the instances with the IDs, which must exist.
+func (f *EC2) instancesOf(ids []*string) ([]*ec2.Instance, error) {
+	instances := []*ec2.Instance{}
+	for _, id := range ids {
+		instance, has := f.instances[aws.StringValue(id)]
+		if !has {
+			return nil, notFound(aws.StringValue(id))
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// changeState changes the state of the instance to the final state, returning the change to the transient state.
+func changeState(instance *ec2.Instance, transient, final string) *ec2.InstanceStateChange {
+	change := &ec2.InstanceStateChange{
+		CurrentState:  newState(transient),
+		InstanceId:    instance.InstanceId,
+		PreviousState: newState(stateName(instance)),
+	}
+	if stateName(instance) == final {
+		change.CurrentState = newState(final)
+	}
+	instance.State = newState(final)
+	return change
+}
+
+// TerminateInstances terminates instances, which remain visible as terminated.  Their network interfaces and
+// volumes that are deleted on termination are deleted, and the others detached.  Instances with API termination
+// disabled can't be terminated.
+func (f *EC2) TerminateInstances(input *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	if err := f.call("TerminateInstances", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	instances, err := f.instancesOf(input.InstanceIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		if f.noTermination[*instance.InstanceId] {
+			return nil, newError(400, "OperationNotPermitted", "The instance '%s' may not be terminated. "+
+				"Modify its 'disableApiTermination' instance attribute and try again.", *instance.InstanceId)
+		}
+	}
+
+	output := &ec2.TerminateInstancesOutput{TerminatingInstances: []*ec2.InstanceStateChange{}}
+	for _, instance := range instances {
+		if stateName(instance) != ec2.InstanceStateNameTerminated {
+			f.release(*instance.InstanceId)
+		}
+		output.TerminatingInstances = append(output.TerminatingInstances,
+			changeState(instance, ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameTerminated))
+	}
+	return output, nil
+}
+
+// release deletes or detaches the network interfaces, volumes and addresses of a terminated instance.
+func (f *EC2) release(id string) {
+	for eniID, eni := range f.networkInterfaces {
+		if eni.Attachment == nil || aws.StringValue(eni.Attachment.InstanceId) != id {
+			continue
+		}
+		if aws.BoolValue(eni.Attachment.DeleteOnTermination) {
+			delete(f.networkInterfaces, eniID)
+			f.removeTags(eniID)
+		} else {
+			eni.Attachment = nil
+			eni.Status = aws.String(ec2.NetworkInterfaceStatusAvailable)
+		}
+	}
+	for volumeID, volume := range f.volumes {
+		for _, attachment := range volume.Attachments {
+			if aws.StringValue(attachment.InstanceId) != id {
+				continue
+			}
+			if aws.BoolValue(attachment.DeleteOnTermination) {
+				delete(f.volumes, volumeID)
+				f.removeTags(volumeID)
+			} else {
+				volume.Attachments = []*ec2.VolumeAttachment{}
+				volume.State = aws.String(ec2.VolumeStateAvailable)
+			}
+		}
+	}
+	for _, address := range f.addresses {
+		if aws.StringValue(address.InstanceId) == id {
+			address.AssociationId, address.InstanceId = nil, nil
+			address.NetworkInterfaceId, address.NetworkInterfaceOwnerId, address.PrivateIpAddress = nil, nil, nil
+		}
+	}
+}
+
+// StopInstances stops running instances.
+func (f *EC2) StopInstances(input *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+	if err := f.call("StopInstances", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	instances, err := f.instancesOf(input.InstanceIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		switch stateName(instance) {
+		case ec2.InstanceStateNameTerminated, ec2.InstanceStateNameShuttingDown:
+			return nil, newError(400, "IncorrectInstanceState", "The instance '%s' is not in a state from which "+
+				"it can be stopped.", *instance.InstanceId)
+		}
+	}
+
+	output := &ec2.StopInstancesOutput{StoppingInstances: []*ec2.InstanceStateChange{}}
+	for _, instance := range instances {
+		output.StoppingInstances = append(output.StoppingInstances,
+			changeState(instance, ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped))
+	}
+	return output, nil
+}
+
+// StopInstancesRequest returns a request stopping instances, which is sent to the fake rather than to AWS.  The
+// request is built as by the SDK, so that handlers can be added to it, but the fake ignores what they build, e.g. it
+// stops instances being hibernated.
+func (f *EC2) StopInstancesRequest(input *ec2.StopInstancesInput) (*request.Request, *ec2.StopInstancesOutput) {
+	output := &ec2.StopInstancesOutput{}
+	operation := &request.Operation{Name: "StopInstances", HTTPMethod: "POST", HTTPPath: "/"}
+	r := request.New(aws.Config{}, ec2Info, request.Handlers{}, nil, operation, input, output)
+	r.Handlers.Send.PushBack(func(r *request.Request) {
+		result, err := f.StopInstances(r.Params.(*ec2.StopInstancesInput))
+		if err != nil {
+			r.Error = err
+			return
+		}
+		*r.Data.(*ec2.StopInstancesOutput) = *result
+	})
+	return r, output
+}
+
+// StartInstances starts stopped instances.
+func (f *EC2) StartInstances(input *ec2.StartInstancesInput) (*ec2.StartInstancesOutput, error) {
+	if err := f.call("StartInstances", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	instances, err := f.instancesOf(input.InstanceIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		switch stateName(instance) {
+		case ec2.InstanceStateNameTerminated, ec2.InstanceStateNameShuttingDown:
+			return nil, newError(400, "IncorrectInstanceState", "The instance '%s' is not in a state from which "+
+				"it can be started.", *instance.InstanceId)
+		}
+	}
+
+	output := &ec2.StartInstancesOutput{StartingInstances: []*ec2.InstanceStateChange{}}
+	for _, instance := range instances {
+		output.StartingInstances = append(output.StartingInstances,
+			changeState(instance, ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning))
+	}
+	return output, nil
+}
+
+// ModifyInstanceAttribute modifies the user data and the instance type of stopped instances, and whether instances
+// can be terminated.
+func (f *EC2) ModifyInstanceAttribute(input *ec2.ModifyInstanceAttributeInput) (*ec2.ModifyInstanceAttributeOutput,
+	error) {
+	if err := f.call("ModifyInstanceAttribute", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.InstanceId)
+	instance, has := f.instances[id]
+	if !has {
+		return nil, notFound(id)
+	}
+	if input.UserData != nil || input.InstanceType != nil {
+		if stateName(instance) != ec2.InstanceStateNameStopped {
+			return nil, newError(400, "IncorrectInstanceState", "The instance '%s' is not in the 'stopped' state.", id)
+		}
+	}
+	if input.UserData != nil {
+		f.userData[id] = string(input.UserData.Value)
+	}
+	if input.InstanceType != nil {
+		instance.InstanceType = input.InstanceType.Value
+	}
+	if input.DisableApiTermination != nil {
+		f.noTermination[id] = aws.BoolValue(input.DisableApiTermination.Value)
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+// DescribeInstanceAttribute describes the userData, disableApiTermination or instanceType attribute of an instance.
+func (f *EC2) DescribeInstanceAttribute(input *ec2.DescribeInstanceAttributeInput) (
+	*ec2.DescribeInstanceAttributeOutput, error) {
+	if err := f.call("DescribeInstanceAttribute", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.InstanceId)
+	instance, has := f.instances[id]
+	if !has {
+		return nil, notFound(id)
+	}
+	output := &ec2.DescribeInstanceAttributeOutput{InstanceId: aws.String(id)}
+	switch aws.StringValue(input.Attribute) {
+	case ec2.InstanceAttributeNameUserData:
+		output.UserData = &ec2.AttributeValue{}
+		if userData, has := f.userData[id]; has {
+			output.UserData.Value = aws.String(userData)
+		}
+	case ec2.InstanceAttributeNameDisableApiTermination:
+		output.DisableApiTermination = &ec2.AttributeBooleanValue{Value: aws.Bool(f.noTermination[id])}
+	case ec2.InstanceAttributeNameInstanceType:
+		output.InstanceType = &ec2.AttributeValue{Value: instance.InstanceType}
+	default:
+		return nil, newError(400, "InvalidParameterValue", "Value (%s) for parameter attribute is invalid.",
+			aws.StringValue(input.Attribute))
+	}
+	return output, nil
+}
+
+// GetConsoleOutput returns the console output of an instance, which the fake makes up.
+func (f *EC2) GetConsoleOutput(input *ec2.GetConsoleOutputInput) (*ec2.GetConsoleOutputOutput, error) {
+	if err := f.call("GetConsoleOutput", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.InstanceId)
+	if _, has := f.instances[id]; !has {
+		return nil, notFound(id)
+	}
+	return &ec2.GetConsoleOutputOutput{
+		InstanceId: aws.String(id),
+		Output:     aws.String(fmt.Sprintf("Booting instance %s\n", id)),
+		Timestamp:  aws.Time(time.Now()),
+	}, nil
+}
+
+// waitForInstances waits until the described instances are all in the state, which they are at once or never.
+func (f *EC2) waitForInstances(input *ec2.DescribeInstancesInput, state string) error {
+	output, err := f.DescribeInstances(input)
+	if err != nil {
+		return err
+	}
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			if stateName(instance) != state {
+				return notReady(aws.StringValue(instance.InstanceId))
+			}
+		}
+	}
+	return nil
+}
+
+// WaitUntilInstanceRunning waits until the instances are running.
+func (f *EC2) WaitUntilInstanceRunning(input *ec2.DescribeInstancesInput) error {
+	return f.waitForInstances(input, ec2.InstanceStateNameRunning)
+}
+
+// WaitUntilInstanceStopped waits until the instances are stopped.
+func (f *EC2) WaitUntilInstanceStopped(input *ec2.DescribeInstancesInput) error {
+	return f.waitForInstances(input, ec2.InstanceStateNameStopped)
+}
+
+// WaitUntilInstanceTerminated waits until the instances are terminated.
+func (f *EC2) WaitUntilInstanceTerminated(input *ec2.DescribeInstancesInput) error {
+	return f.waitForInstances(input, ec2.InstanceStateNameTerminated)
+}
@@ -0,0 +1,211 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/stretchr/testify/require"
+)
+
+var _ ec2iface.EC2API = NewEC2()
+
+func requireCode(t *testing.T, code string, err error) {
+	require.Error(t, err)
+	awsErr, is := err.(awserr.Error)
+	require.True(t, is, "%v", err)
+	require.Equal(t, code, awsErr.Code())
+}
+
+func runInstance(t *testing.T, f *EC2, tags map[string]string) string {
+	reservation, err := f.RunInstances(&ec2.RunInstancesInput{
+		ImageId:      aws.String(DefaultImageID),
+		InstanceType: aws.String("t2.micro"),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+	})
+	require.NoError(t, err)
+	require.Len(t, reservation.Instances, 1)
+	id := *reservation.Instances[0].InstanceId
+
+	input := &ec2.CreateTagsInput{Resources: []*string{aws.String(id)}}
+	for k, v := range tags {
+		input.Tags = append(input.Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	if len(tags) > 0 {
+		_, err = f.CreateTags(input)
+		require.NoError(t, err)
+	}
+	return id
+}
+
+func TestRunDescribeAndTerminateInstances(t *testing.T) {
+	f := NewEC2()
+	id := runInstance(t, f, map[string]string{"group": "workers"})
+	runInstance(t, f, map[string]string{"group": "managers"})
+
+	output, err := f.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:group"), Values: []*string{aws.String("work*")}},
+			{Name: aws.String("instance-state-name"), Values: []*string{aws.String("pending"), aws.String("running")}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, output.Reservations, 1)
+	instance := output.Reservations[0].Instances[0]
+	require.Equal(t, id, *instance.InstanceId)
+	require.Equal(t, "running", *instance.State.Name)
+	require.Equal(t, DefaultSubnetID, *instance.SubnetId)
+	require.Equal(t, DefaultVpcID, *instance.VpcId)
+	require.Len(t, instance.NetworkInterfaces, 1)
+	require.Len(t, instance.BlockDeviceMappings, 1)
+
+	_, err = f.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String(id)}})
+	require.NoError(t, err)
+	require.NoError(t, f.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{InstanceIds: []*string{&id}}))
+
+	volumes, err := f.DescribeVolumes(&ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("attachment.instance-id"), Values: []*string{aws.String(id)}}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, volumes.Volumes)
+}
+
+func TestDescribeInstancesErrors(t *testing.T) {
+	f := NewEC2()
+	runInstance(t, f, nil)
+
+	_, err := f.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String("i-missing")}})
+	requireCode(t, "InvalidInstanceID.NotFound", err)
+
+	_, err = f.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("no-such-filter"), Values: []*string{aws.String("x")}}},
+	})
+	requireCode(t, "InvalidParameterValue", err)
+}
+
+func TestDescribeInstancesPages(t *testing.T) {
+	f := NewEC2()
+	for i := 0; i < 5; i++ {
+		runInstance(t, f, nil)
+	}
+
+	input := &ec2.DescribeInstancesInput{MaxResults: aws.Int64(2)}
+	count := 0
+	for {
+		output, err := f.DescribeInstances(input)
+		require.NoError(t, err)
+		for _, reservation := range output.Reservations {
+			count += len(reservation.Instances)
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	require.Equal(t, 5, count)
+}
+
+func TestInjectedFailuresAndDryRun(t *testing.T) {
+	f := NewEC2()
+	f.Fail("RunInstances", Error("InsufficientInstanceCapacity", "no capacity"))
+
+	input := &ec2.RunInstancesInput{
+		ImageId:      aws.String(DefaultImageID),
+		InstanceType: aws.String("t2.micro"),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+	}
+	_, err := f.RunInstances(input)
+	requireCode(t, "InsufficientInstanceCapacity", err)
+
+	input.DryRun = aws.Bool(true)
+	_, err = f.RunInstances(input)
+	requireCode(t, "DryRunOperation", err)
+
+	input.DryRun = nil
+	_, err = f.RunInstances(input)
+	require.NoError(t, err)
+
+	_, err = f.RunInstances(&ec2.RunInstancesInput{ImageId: aws.String(DefaultImageID)})
+	requireCode(t, "InvalidParameter", err)
+}
+
+func TestStopAndStartInstances(t *testing.T) {
+	f := NewEC2()
+	id := runInstance(t, f, nil)
+
+	_, err := f.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId:   aws.String(id),
+		InstanceType: &ec2.AttributeValue{Value: aws.String("m4.large")},
+	})
+	requireCode(t, "IncorrectInstanceState", err)
+
+	req, _ := f.StopInstancesRequest(&ec2.StopInstancesInput{InstanceIds: []*string{aws.String(id)}})
+	require.NoError(t, req.Send())
+	require.NoError(t, f.WaitUntilInstanceStopped(&ec2.DescribeInstancesInput{InstanceIds: []*string{&id}}))
+
+	_, err = f.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId:   aws.String(id),
+		InstanceType: &ec2.AttributeValue{Value: aws.String("m4.large")},
+	})
+	require.NoError(t, err)
+
+	_, err = f.StartInstances(&ec2.StartInstancesInput{InstanceIds: []*string{aws.String(id)}})
+	require.NoError(t, err)
+	output, err := f.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(id)}})
+	require.NoError(t, err)
+	require.Equal(t, "m4.large", *output.Reservations[0].Instances[0].InstanceType)
+	require.Equal(t, "running", *output.Reservations[0].Instances[0].State.Name)
+}
+
+func TestNetworkDependencies(t *testing.T) {
+	f := NewEC2()
+	vpc, err := f.CreateVpc(&ec2.CreateVpcInput{CidrBlock: aws.String("10.0.0.0/16")})
+	require.NoError(t, err)
+	subnet, err := f.CreateSubnet(&ec2.CreateSubnetInput{
+		VpcId:     vpc.Vpc.VpcId,
+		CidrBlock: aws.String("10.0.1.0/24"),
+	})
+	require.NoError(t, err)
+
+	_, err = f.DeleteVpc(&ec2.DeleteVpcInput{VpcId: vpc.Vpc.VpcId})
+	requireCode(t, "DependencyViolation", err)
+
+	_, err = f.DeleteSubnet(&ec2.DeleteSubnetInput{SubnetId: subnet.Subnet.SubnetId})
+	require.NoError(t, err)
+	_, err = f.DeleteVpc(&ec2.DeleteVpcInput{VpcId: vpc.Vpc.VpcId})
+	require.NoError(t, err)
+
+	_, err = f.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: []*string{vpc.Vpc.VpcId}})
+	requireCode(t, "InvalidVpcID.NotFound", err)
+}
+
+func TestVolumeAttachment(t *testing.T) {
+	f := NewEC2()
+	id := runInstance(t, f, nil)
+
+	volume, err := f.CreateVolume(&ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(DefaultAvailabilityZone),
+		Size:             aws.Int64(10),
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}}))
+
+	_, err = f.AttachVolume(&ec2.AttachVolumeInput{
+		Device:     aws.String("/dev/sdf"),
+		InstanceId: aws.String(id),
+		VolumeId:   volume.VolumeId,
+	})
+	require.NoError(t, err)
+
+	_, err = f.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: volume.VolumeId})
+	requireCode(t, "VolumeInUse", err)
+
+	_, err = f.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String(id)}})
+	require.NoError(t, err)
+	_, err = f.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: volume.VolumeId})
+	require.NoError(t, err)
+}
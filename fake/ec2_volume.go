@@ -0,0 +1,430 @@
+package fake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// newVolume creates an available volume, of the size of its snapshot unless set.
+func (f *EC2) newVolume(input *ec2.CreateVolumeInput) *ec2.Volume {
+	id := f.ids.next("vol")
+	size := aws.Int64Value(input.Size)
+	if snapshot, has := f.snapshots[aws.StringValue(input.SnapshotId)]; has && size == 0 {
+		size = aws.Int64Value(snapshot.VolumeSize)
+	}
+	volumeType := aws.StringValue(input.VolumeType)
+	if volumeType == "" {
+		volumeType = ec2.VolumeTypeStandard
+	}
+	volume := &ec2.Volume{
+		Attachments:      []*ec2.VolumeAttachment{},
+		AvailabilityZone: input.AvailabilityZone,
+		CreateTime:       aws.Time(time.Now()),
+		Encrypted:        aws.Bool(aws.BoolValue(input.Encrypted)),
+		Iops:             input.Iops,
+		KmsKeyId:         input.KmsKeyId,
+		Size:             aws.Int64(size),
+		SnapshotId:       aws.String(aws.StringValue(input.SnapshotId)),
+		State:            aws.String(ec2.VolumeStateAvailable),
+		VolumeId:         aws.String(id),
+		VolumeType:       aws.String(volumeType),
+	}
+	f.volumes[id] = volume
+	return volume
+}
+
+// CreateVolume creates a volume of a size or from a snapshot, which is available at once but returned creating.
+func (f *EC2) CreateVolume(input *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+	if err := f.call("CreateVolume", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if input.Size == nil && input.SnapshotId == nil {
+		return nil, newError(400, "MissingParameter", "The request must contain the parameter size or snapshotId")
+	}
+	if input.SnapshotId != nil {
+		if _, has := f.snapshots[*input.SnapshotId]; !has {
+			return nil, notFound(*input.SnapshotId)
+		}
+	}
+	if aws.StringValue(input.VolumeType) == ec2.VolumeTypeIo1 && input.Iops == nil {
+		return nil, newError(400, "InvalidParameterCombination", "The parameter iops must be specified for io1 "+
+			"volumes.")
+	}
+	volume := awsutil.CopyOf(f.newVolume(input)).(*ec2.Volume)
+	volume.State = aws.String(ec2.VolumeStateCreating)
+	return volume, nil
+}
+
+// DescribeVolumes describes volumes, filtered by volume-id, status, availability-zone, size, snapshot-id,
+// volume-type, attachment.instance-id, attachment.device, attachment.status and tags, in pages of MaxResults.
+func (f *EC2) DescribeVolumes(input *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	if err := f.call("DescribeVolumes", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ids, err := describeIDs(f.volumes, input.VolumeIds)
+	if err != nil {
+		return nil, err
+	}
+	volumes := []*ec2.Volume{}
+	for _, id := range ids {
+		volume := f.volumes[id]
+		values := newFilterValues("attachment.instance-id", "attachment.device", "attachment.status").
+			add("volume-id", volume.VolumeId).
+			add("status", volume.State).
+			add("availability-zone", volume.AvailabilityZone).
+			add("size", aws.String(fmt.Sprint(aws.Int64Value(volume.Size)))).
+			add("snapshot-id", volume.SnapshotId).
+			add("volume-type", volume.VolumeType)
+		for _, attachment := range volume.Attachments {
+			values.add("attachment.instance-id", attachment.InstanceId).
+				add("attachment.device", attachment.Device).
+				add("attachment.status", attachment.State)
+		}
+		if match, err := matches(input.Filters, values, f.tags[id]); err != nil {
+			return nil, err
+		} else if match {
+			volume = awsutil.CopyOf(volume).(*ec2.Volume)
+			volume.Tags = f.tagsOf(id)
+			volumes = append(volumes, volume)
+		}
+	}
+
+	start, end, next, err := page(len(volumes), input.NextToken, pageSize(input.MaxResults, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: volumes[start:end], NextToken: next}, nil
+}
+
+// DeleteVolume deletes an available volume.
+func (f *EC2) DeleteVolume(input *ec2.DeleteVolumeInput) (*ec2.DeleteVolumeOutput, error) {
+	if err := f.call("DeleteVolume", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.VolumeId)
+	volume, has := f.volumes[id]
+	if !has {
+		return nil, notFound(id)
+	}
+	if len(volume.Attachments) > 0 {
+		return nil, newError(400, "VolumeInUse", "Volume %s is currently attached to %s", id,
+			aws.StringValue(volume.Attachments[0].InstanceId))
+	}
+	delete(f.volumes, id)
+	f.removeTags(id)
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+// AttachVolume attaches an available volume to an instance, which is not terminated, in its availability zone, as a
+// device that is not in use.
+func (f *EC2) AttachVolume(input *ec2.AttachVolumeInput) (*ec2.VolumeAttachment, error) {
+	if err := f.call("AttachVolume", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id, instanceID := aws.StringValue(input.VolumeId), aws.StringValue(input.InstanceId)
+	volume, has := f.volumes[id]
+	if !has {
+		return nil, notFound(id)
+	}
+	instance, has := f.instances[instanceID]
+	if !has {
+		return nil, notFound(instanceID)
+	}
+	switch stateName(instance) {
+	case ec2.InstanceStateNameTerminated, ec2.InstanceStateNameShuttingDown:
+		return nil, newError(400, "IncorrectState", "Instance '%s' is not 'running'.", instanceID)
+	}
+	if len(volume.Attachments) > 0 {
+		return nil, newError(400, "VolumeInUse", "%s is already attached to an instance", id)
+	}
+	if aws.StringValue(volume.AvailabilityZone) != aws.StringValue(instance.Placement.AvailabilityZone) {
+		return nil, newError(400, "InvalidVolume.ZoneMismatch", "The volume '%s' is not in the same availability "+
+			"zone as instance '%s'", id, instanceID)
+	}
+	for _, other := range f.volumes {
+		for _, attachment := range other.Attachments {
+			if aws.StringValue(attachment.InstanceId) == instanceID &&
+				aws.StringValue(attachment.Device) == aws.StringValue(input.Device) {
+				return nil, newError(400, "InvalidParameterValue", "Invalid value '%s' for unixDevice. Attachment "+
+					"point %s is already in use", aws.StringValue(input.Device), aws.StringValue(input.Device))
+			}
+		}
+	}
+
+	attachment := &ec2.VolumeAttachment{
+		AttachTime:          aws.Time(time.Now()),
+		DeleteOnTermination: aws.Bool(false),
+		Device:              input.Device,
+		InstanceId:          aws.String(instanceID),
+		State:               aws.String(ec2.VolumeAttachmentStateAttached),
+		VolumeId:            aws.String(id),
+	}
+	volume.Attachments = []*ec2.VolumeAttachment{attachment}
+	volume.State = aws.String(ec2.VolumeStateInUse)
+
+	attachment = awsutil.CopyOf(attachment).(*ec2.VolumeAttachment)
+	attachment.State = aws.String(ec2.VolumeAttachmentStateAttaching)
+	return attachment, nil
+}
+
+// DetachVolume detaches a volume from its instance, which must be the one of the request if set.
+func (f *EC2) DetachVolume(input *ec2.DetachVolumeInput) (*ec2.VolumeAttachment, error) {
+	if err := f.call("DetachVolume", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.VolumeId)
+	volume, has := f.volumes[id]
+	if !has {
+		return nil, notFound(id)
+	}
+	if len(volume.Attachments) == 0 {
+		return nil, newError(400, "IncorrectState", "Volume '%s' is in the 'available' state.", id)
+	}
+	attachment := volume.Attachments[0]
+	if input.InstanceId != nil && *input.InstanceId != aws.StringValue(attachment.InstanceId) {
+		return nil, newError(400, "InvalidAttachment.NotFound", "The volume %s is not attached to %s", id,
+			*input.InstanceId)
+	}
+	volume.Attachments = []*ec2.VolumeAttachment{}
+	volume.State = aws.String(ec2.VolumeStateAvailable)
+
+	attachment = awsutil.CopyOf(attachment).(*ec2.VolumeAttachment)
+	attachment.State = aws.String(ec2.VolumeAttachmentStateDetaching)
+	return attachment, nil
+}
+
+// waitForVolumes waits until the described volumes are all in the state.
+func (f *EC2) waitForVolumes(input *ec2.DescribeVolumesInput, state string) error {
+	output, err := f.DescribeVolumes(input)
+	if err != nil {
+		return err
+	}
+	for _, volume := range output.Volumes {
+		if aws.StringValue(volume.State) != state {
+			return notReady(aws.StringValue(volume.VolumeId))
+		}
+	}
+	return nil
+}
+
+// WaitUntilVolumeAvailable waits until the volumes are available.
+func (f *EC2) WaitUntilVolumeAvailable(input *ec2.DescribeVolumesInput) error {
+	return f.waitForVolumes(input, ec2.VolumeStateAvailable)
+}
+
+// WaitUntilVolumeInUse waits until the volumes are in use.
+func (f *EC2) WaitUntilVolumeInUse(input *ec2.DescribeVolumesInput) error {
+	return f.waitForVolumes(input, ec2.VolumeStateInUse)
+}
+
+// CreateSnapshot creates a snapshot of a volume, which is completed at once but returned pending.
+func (f *EC2) CreateSnapshot(input *ec2.CreateSnapshotInput) (*ec2.Snapshot, error) {
+	if err := f.call("CreateSnapshot", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	volume, has := f.volumes[aws.StringValue(input.VolumeId)]
+	if !has {
+		return nil, notFound(aws.StringValue(input.VolumeId))
+	}
+	id := f.ids.next("snap")
+	snapshot := &ec2.Snapshot{
+		Description: input.Description,
+		Encrypted:   volume.Encrypted,
+		KmsKeyId:    volume.KmsKeyId,
+		OwnerId:     aws.String(Account),
+		Progress:    aws.String("100%"),
+		SnapshotId:  aws.String(id),
+		StartTime:   aws.Time(time.Now()),
+		State:       aws.String(ec2.SnapshotStateCompleted),
+		VolumeId:    volume.VolumeId,
+		VolumeSize:  volume.Size,
+	}
+	f.snapshots[id] = snapshot
+
+	snapshot = awsutil.CopyOf(snapshot).(*ec2.Snapshot)
+	snapshot.Progress = aws.String("")
+	snapshot.State = aws.String(ec2.SnapshotStatePending)
+	return snapshot, nil
+}
+
+// DescribeSnapshots describes snapshots, filtered by snapshot-id, volume-id, status, owner-id, description and
+// tags, in pages of MaxResults.
+func (f *EC2) DescribeSnapshots(input *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	if err := f.call("DescribeSnapshots", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ids, err := describeIDs(f.snapshots, input.SnapshotIds)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []*ec2.Snapshot{}
+	for _, id := range ids {
+		snapshot := f.snapshots[id]
+		values := newFilterValues().
+			add("snapshot-id", snapshot.SnapshotId).
+			add("volume-id", snapshot.VolumeId).
+			add("status", snapshot.State).
+			add("owner-id", snapshot.OwnerId).
+			add("description", snapshot.Description)
+		if match, err := matches(input.Filters, values, f.tags[id]); err != nil {
+			return nil, err
+		} else if match {
+			snapshot = awsutil.CopyOf(snapshot).(*ec2.Snapshot)
+			snapshot.Tags = f.tagsOf(id)
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	start, end, next, err := page(len(snapshots), input.NextToken, pageSize(input.MaxResults, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSnapshotsOutput{Snapshots: snapshots[start:end], NextToken: next}, nil
+}
+
+// DeleteSnapshot deletes a snapshot that is not used by an image.
+func (f *EC2) DeleteSnapshot(input *ec2.DeleteSnapshotInput) (*ec2.DeleteSnapshotOutput, error) {
+	if err := f.call("DeleteSnapshot", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := aws.StringValue(input.SnapshotId)
+	if _, has := f.snapshots[id]; !has {
+		return nil, notFound(id)
+	}
+	for _, image := range f.images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && aws.StringValue(mapping.Ebs.SnapshotId) == id {
+				return nil, newError(400, "InvalidSnapshot.InUse", "The snapshot %s is currently in use by %s", id,
+					aws.StringValue(image.ImageId))
+			}
+		}
+	}
+	delete(f.snapshots, id)
+	f.removeTags(id)
+	return &ec2.DeleteSnapshotOutput{}, nil
+}
+
+// WaitUntilSnapshotCompleted waits until the snapshots are completed.
+func (f *EC2) WaitUntilSnapshotCompleted(input *ec2.DescribeSnapshotsInput) error {
+	output, err := f.DescribeSnapshots(input)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range output.Snapshots {
+		if aws.StringValue(snapshot.State) != ec2.SnapshotStateCompleted {
+			return notReady(aws.StringValue(snapshot.SnapshotId))
+		}
+	}
+	return nil
+}
+
+// RegisterImage registers an image whose EBS block devices are snapshots.
+func (f *EC2) RegisterImage(input *ec2.RegisterImageInput) (*ec2.RegisterImageOutput, error) {
+	if err := f.call("RegisterImage", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, image := range f.images {
+		if aws.StringValue(image.Name) == aws.StringValue(input.Name) {
+			return nil, newError(400, "InvalidAMIName.Duplicate", "AMI name %s is already in use by AMI %s",
+				aws.StringValue(input.Name), aws.StringValue(image.ImageId))
+		}
+	}
+	for _, mapping := range input.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+			if _, has := f.snapshots[*mapping.Ebs.SnapshotId]; !has {
+				return nil, notFound(*mapping.Ebs.SnapshotId)
+			}
+		}
+	}
+
+	id := f.ids.next("ami")
+	rootDeviceType := ec2.DeviceTypeInstanceStore
+	for _, mapping := range input.BlockDeviceMappings {
+		if mapping.Ebs != nil && aws.StringValue(mapping.DeviceName) == aws.StringValue(input.RootDeviceName) {
+			rootDeviceType = ec2.DeviceTypeEbs
+		}
+	}
+	architecture := aws.StringValue(input.Architecture)
+	if architecture == "" {
+		architecture = ec2.ArchitectureValuesX8664
+	}
+	f.images[id] = &ec2.Image{
+		Architecture:        aws.String(architecture),
+		BlockDeviceMappings: awsutil.CopyOf(input).(*ec2.RegisterImageInput).BlockDeviceMappings,
+		CreationDate:        aws.String(time.Now().UTC().Format(time.RFC3339)),
+		Description:         input.Description,
+		ImageId:             aws.String(id),
+		ImageType:           aws.String(ec2.ImageTypeValuesMachine),
+		Name:                input.Name,
+		OwnerId:             aws.String(Account),
+		Public:              aws.Bool(false),
+		RootDeviceName:      input.RootDeviceName,
+		RootDeviceType:      aws.String(rootDeviceType),
+		State:               aws.String(ec2.ImageStateAvailable),
+		VirtualizationType:  input.VirtualizationType,
+	}
+	return &ec2.RegisterImageOutput{ImageId: aws.String(id)}, nil
+}
+
+// DescribeImages describes images, filtered by image-id, name, state, owner-id, architecture and tags.
+func (f *EC2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	if err := f.call("DescribeImages", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	ids, err := describeIDs(f.images, input.ImageIds)
+	if err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeImagesOutput{Images: []*ec2.Image{}}
+	for _, id := range ids {
+		image := f.images[id]
+		values := newFilterValues().
+			add("image-id", image.ImageId).
+			add("name", image.Name).
+			add("state", image.State).
+			add("owner-id", image.OwnerId).
+			add("architecture", image.Architecture)
+		if match, err := matches(input.Filters, values, f.tags[id]); err != nil {
+			return nil, err
+		} else if match {
+			image = awsutil.CopyOf(image).(*ec2.Image)
+			image.Tags = f.tagsOf(id)
+			output.Images = append(output.Images, image)
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,368 @@
+package fake
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+)
+
+const (
+	// elbPageSize is the default number of load balancers of a page.
+	elbPageSize = 400
+
+	// elbMaxTags is the maximum number of tags of a load balancer.
+	elbMaxTags = 10
+)
+
+// ELB is a fake of the ELB API, which supports classic load balancers, their tags, health checks and attributes.
+type ELB struct {
+	elbiface.ELBAPI
+	Failures
+
+	lock          sync.Mutex
+	loadBalancers map[string]*elb.LoadBalancerDescription
+	attributes    map[string]*elb.LoadBalancerAttributes
+	tags          map[string]map[string]string
+}
+
+// NewELB returns a fake of the ELB API.
+func NewELB() *ELB {
+	return &ELB{
+		loadBalancers: map[string]*elb.LoadBalancerDescription{},
+		attributes:    map[string]*elb.LoadBalancerAttributes{},
+		tags:          map[string]map[string]string{},
+	}
+}
+
+// loadBalancerNotFound returns the error of a load balancer not being found.
+func loadBalancerNotFound(name string) error {
+	return newError(400, "LoadBalancerNotFound", "There is no ACTIVE Load Balancer named '%s'", name)
+}
+
+// addTags adds or overwrites tags of a load balancer, failing if it would have too many.
+func (f *ELB) addTags(name string, tags []*elb.Tag) error {
+	merged := map[string]string{}
+	for key, value := range f.tags[name] {
+		merged[key] = value
+	}
+	for _, tag := range tags {
+		merged[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if len(merged) > elbMaxTags {
+		return newError(400, "TooManyTags", "Adding tags would exceed the limit of %d tags for %s", elbMaxTags,
+			name)
+	}
+	f.tags[name] = merged
+	return nil
+}
+
+// CreateLoadBalancer creates a load balancer in availability zones or subnets, with listeners and tags.  Creating a
+// load balancer that exists with the same listeners returns it.
+func (f *ELB) CreateLoadBalancer(input *elb.CreateLoadBalancerInput) (*elb.CreateLoadBalancerOutput, error) {
+	if err := f.call("CreateLoadBalancer", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LoadBalancerName)
+	if existing, has := f.loadBalancers[name]; has {
+		listeners := []*elb.Listener{}
+		for _, description := range existing.ListenerDescriptions {
+			listeners = append(listeners, description.Listener)
+		}
+		if awsutil.Prettify(listeners) != awsutil.Prettify(input.Listeners) {
+			return nil, newError(400, "DuplicateLoadBalancerName", "Load Balancer named '%s' already exists and it "+
+				"is configured with different parameters.", name)
+		}
+		return &elb.CreateLoadBalancerOutput{DNSName: existing.DNSName}, nil
+	}
+	if len(input.AvailabilityZones) == 0 && len(input.Subnets) == 0 {
+		return nil, newError(400, "ValidationError", "Either AvailabilityZones or SubnetIds must be specified")
+	}
+	if len(name) > 32 {
+		return nil, newError(400, "ValidationError", "LoadBalancerName '%s' must be at most 32 characters", name)
+	}
+
+	scheme := aws.StringValue(input.Scheme)
+	if scheme == "" {
+		scheme = "internet-facing"
+	}
+	dnsName := fmt.Sprintf("%s-%d.%s.elb.amazonaws.com", name, crc32.ChecksumIEEE([]byte(name)), Region)
+	if scheme == "internal" {
+		dnsName = "internal-" + dnsName
+	}
+	zones := input.AvailabilityZones
+	if len(zones) == 0 {
+		zones = []*string{aws.String(DefaultAvailabilityZone)}
+	}
+	loadBalancer := &elb.LoadBalancerDescription{
+		AvailabilityZones:         zones,
+		BackendServerDescriptions: []*elb.BackendServerDescription{},
+		CanonicalHostedZoneName:   aws.String(dnsName),
+		CanonicalHostedZoneNameID: aws.String("Z35SXDOTRQ7X7K"),
+		CreatedTime:               aws.Time(time.Now()),
+		DNSName:                   aws.String(dnsName),
+		HealthCheck: &elb.HealthCheck{
+			HealthyThreshold:   aws.Int64(10),
+			Interval:           aws.Int64(30),
+			Target:             aws.String("TCP:80"),
+			Timeout:            aws.Int64(5),
+			UnhealthyThreshold: aws.Int64(2),
+		},
+		Instances:            []*elb.Instance{},
+		ListenerDescriptions: []*elb.ListenerDescription{},
+		LoadBalancerName:     aws.String(name),
+		Policies:             &elb.Policies{},
+		Scheme:               aws.String(scheme),
+		SecurityGroups:       input.SecurityGroups,
+		SourceSecurityGroup:  &elb.SourceSecurityGroup{GroupName: aws.String("default"), OwnerAlias: aws.String(Account)},
+		Subnets:              input.Subnets,
+	}
+	if len(input.Subnets) > 0 {
+		loadBalancer.VPCId = aws.String(DefaultVpcID)
+	}
+	for _, listener := range input.Listeners {
+		loadBalancer.ListenerDescriptions = append(loadBalancer.ListenerDescriptions, &elb.ListenerDescription{
+			Listener:    listener,
+			PolicyNames: []*string{},
+		})
+	}
+	if err := f.addTags(name, input.Tags); err != nil {
+		return nil, err
+	}
+	f.loadBalancers[name] = awsutil.CopyOf(loadBalancer).(*elb.LoadBalancerDescription)
+	f.attributes[name] = &elb.LoadBalancerAttributes{
+		AccessLog:              &elb.AccessLog{Enabled: aws.Bool(false)},
+		AdditionalAttributes:   []*elb.AdditionalAttribute{},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: aws.Bool(false), Timeout: aws.Int64(300)},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: aws.Int64(60)},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: aws.Bool(false)},
+	}
+	return &elb.CreateLoadBalancerOutput{DNSName: aws.String(dnsName)}, nil
+}
+
+// DescribeLoadBalancers describes the named load balancers, which must exist, or all of them, in pages of PageSize.
+func (f *ELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	if err := f.call("DescribeLoadBalancers", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := []string{}
+	for name := range f.loadBalancers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(input.LoadBalancerNames) > 0 {
+		names = []string{}
+		for _, name := range input.LoadBalancerNames {
+			if _, has := f.loadBalancers[aws.StringValue(name)]; !has {
+				return nil, loadBalancerNotFound(aws.StringValue(name))
+			}
+			names = append(names, aws.StringValue(name))
+		}
+	}
+
+	start, end, next, err := page(len(names), input.Marker, pageSize(input.PageSize, elbPageSize))
+	if err != nil {
+		return nil, err
+	}
+	output := &elb.DescribeLoadBalancersOutput{LoadBalancerDescriptions: []*elb.LoadBalancerDescription{},
+		NextMarker: next}
+	for _, name := range names[start:end] {
+		output.LoadBalancerDescriptions = append(output.LoadBalancerDescriptions,
+			awsutil.CopyOf(f.loadBalancers[name]).(*elb.LoadBalancerDescription))
+	}
+	return output, nil
+}
+
+// DeleteLoadBalancer deletes a load balancer.  As in AWS, deleting a load balancer that does not exist succeeds.
+func (f *ELB) DeleteLoadBalancer(input *elb.DeleteLoadBalancerInput) (*elb.DeleteLoadBalancerOutput, error) {
+	if err := f.call("DeleteLoadBalancer", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LoadBalancerName)
+	delete(f.loadBalancers, name)
+	delete(f.attributes, name)
+	delete(f.tags, name)
+	return &elb.DeleteLoadBalancerOutput{}, nil
+}
+
+// RegisterInstancesWithLoadBalancer registers instances with a load balancer.  Registering an instance that is
+// registered already succeeds.
+func (f *ELB) RegisterInstancesWithLoadBalancer(input *elb.RegisterInstancesWithLoadBalancerInput) (
+	*elb.RegisterInstancesWithLoadBalancerOutput, error) {
+	if err := f.call("RegisterInstancesWithLoadBalancer", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LoadBalancerName)
+	loadBalancer, has := f.loadBalancers[name]
+	if !has {
+		return nil, loadBalancerNotFound(name)
+	}
+	registered := map[string]bool{}
+	for _, instance := range loadBalancer.Instances {
+		registered[aws.StringValue(instance.InstanceId)] = true
+	}
+	for _, instance := range input.Instances {
+		if id := aws.StringValue(instance.InstanceId); !registered[id] {
+			loadBalancer.Instances = append(loadBalancer.Instances, &elb.Instance{InstanceId: aws.String(id)})
+			registered[id] = true
+		}
+	}
+	output := &elb.RegisterInstancesWithLoadBalancerOutput{Instances: []*elb.Instance{}}
+	for _, instance := range loadBalancer.Instances {
+		output.Instances = append(output.Instances, &elb.Instance{InstanceId: instance.InstanceId})
+	}
+	return output, nil
+}
+
+// AddTags adds or overwrites tags of load balancers.
+func (f *ELB) AddTags(input *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
+	if err := f.call("AddTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, name := range input.LoadBalancerNames {
+		if _, has := f.loadBalancers[aws.StringValue(name)]; !has {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+	}
+	for _, name := range input.LoadBalancerNames {
+		if err := f.addTags(*name, input.Tags); err != nil {
+			return nil, err
+		}
+	}
+	return &elb.AddTagsOutput{}, nil
+}
+
+// RemoveTags removes tags of load balancers.
+func (f *ELB) RemoveTags(input *elb.RemoveTagsInput) (*elb.RemoveTagsOutput, error) {
+	if err := f.call("RemoveTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, name := range input.LoadBalancerNames {
+		if _, has := f.loadBalancers[aws.StringValue(name)]; !has {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+	}
+	for _, name := range input.LoadBalancerNames {
+		for _, tag := range input.Tags {
+			delete(f.tags[*name], aws.StringValue(tag.Key))
+		}
+	}
+	return &elb.RemoveTagsOutput{}, nil
+}
+
+// DescribeTags describes the tags of at most 20 load balancers.
+func (f *ELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	if err := f.call("DescribeTags", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if len(input.LoadBalancerNames) > 20 {
+		return nil, newError(400, "ValidationError", "Member must have length less than or equal to 20")
+	}
+	output := &elb.DescribeTagsOutput{TagDescriptions: []*elb.TagDescription{}}
+	for _, name := range input.LoadBalancerNames {
+		if _, has := f.loadBalancers[aws.StringValue(name)]; !has {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+		description := &elb.TagDescription{LoadBalancerName: aws.String(*name), Tags: []*elb.Tag{}}
+		for _, key := range sortedKeys(f.tags[*name]) {
+			description.Tags = append(description.Tags, &elb.Tag{Key: aws.String(key),
+				Value: aws.String(f.tags[*name][key])})
+		}
+		output.TagDescriptions = append(output.TagDescriptions, description)
+	}
+	return output, nil
+}
+
+// ConfigureHealthCheck configures the health check of a load balancer.
+func (f *ELB) ConfigureHealthCheck(input *elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
+	if err := f.call("ConfigureHealthCheck", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	loadBalancer, has := f.loadBalancers[aws.StringValue(input.LoadBalancerName)]
+	if !has {
+		return nil, loadBalancerNotFound(aws.StringValue(input.LoadBalancerName))
+	}
+	loadBalancer.HealthCheck = awsutil.CopyOf(input.HealthCheck).(*elb.HealthCheck)
+	return &elb.ConfigureHealthCheckOutput{HealthCheck: awsutil.CopyOf(input.HealthCheck).(*elb.HealthCheck)}, nil
+}
+
+// ModifyLoadBalancerAttributes modifies the attributes of a load balancer that are set.
+func (f *ELB) ModifyLoadBalancerAttributes(input *elb.ModifyLoadBalancerAttributesInput) (
+	*elb.ModifyLoadBalancerAttributesOutput, error) {
+	if err := f.call("ModifyLoadBalancerAttributes", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.LoadBalancerName)
+	attributes, has := f.attributes[name]
+	if !has {
+		return nil, loadBalancerNotFound(name)
+	}
+	modified := awsutil.CopyOf(input.LoadBalancerAttributes).(*elb.LoadBalancerAttributes)
+	if modified.AccessLog != nil {
+		attributes.AccessLog = modified.AccessLog
+	}
+	if modified.ConnectionDraining != nil {
+		attributes.ConnectionDraining = modified.ConnectionDraining
+	}
+	if modified.ConnectionSettings != nil {
+		attributes.ConnectionSettings = modified.ConnectionSettings
+	}
+	if modified.CrossZoneLoadBalancing != nil {
+		attributes.CrossZoneLoadBalancing = modified.CrossZoneLoadBalancing
+	}
+	return &elb.ModifyLoadBalancerAttributesOutput{
+		LoadBalancerAttributes: awsutil.CopyOf(attributes).(*elb.LoadBalancerAttributes),
+		LoadBalancerName:       aws.String(name),
+	}, nil
+}
+
+// DescribeLoadBalancerAttributes describes the attributes of a load balancer.
+func (f *ELB) DescribeLoadBalancerAttributes(input *elb.DescribeLoadBalancerAttributesInput) (
+	*elb.DescribeLoadBalancerAttributesOutput, error) {
+	if err := f.call("DescribeLoadBalancerAttributes", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	attributes, has := f.attributes[aws.StringValue(input.LoadBalancerName)]
+	if !has {
+		return nil, loadBalancerNotFound(aws.StringValue(input.LoadBalancerName))
+	}
+	return &elb.DescribeLoadBalancerAttributesOutput{
+		LoadBalancerAttributes: awsutil.CopyOf(attributes).(*elb.LoadBalancerAttributes),
+	}, nil
+}
@@ -0,0 +1,211 @@
+// Package fake implements stateful in-memory fakes of the AWS APIs used by the plugins, so that their workflows can
+// be tested offline.  The fakes track resources, their tags and states, support the filters and pagination of their
+// describe operations, and fail with the error codes of AWS.  State changes are immediate: resources are created
+// available or running, and waiters return at once, or fail if the resources are not in the awaited state.
+//
+// Each fake embeds the interface of its API, so that it implements it, but only the operations used by the plugins
+// are faked: the others panic.
+package fake
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// Account is the ID of the account of the resources.
+	Account = "123456789012"
+
+	// Region is the region of the resources.
+	Region = "us-east-1"
+)
+
+// ids generates sequential IDs, which sort in the order they were generated.
+type ids struct {
+	lock sync.Mutex
+	last int
+}
+
+func (i *ids) next(prefix string) string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.last++
+	return fmt.Sprintf("%s-%08x", prefix, i.last)
+}
+
+// requestIDs generates the request IDs of errors.
+var requestIDs ids
+
+// newError returns the error of a failed request with the code and message, as returned by the SDK.
+func newError(status int, code, format string, args ...interface{}) error {
+	return awserr.NewRequestFailure(awserr.New(code, fmt.Sprintf(format, args...), nil), status,
+		requestIDs.next("request"))
+}
+
+// Failures are errors to fail the next calls of operations with.  The fakes embed them.
+type Failures struct {
+	lock     sync.Mutex
+	failures map[string][]error
+}
+
+// Fail fails the next call of the operation, e.g. RunInstances, with the error, after the errors it already is to fail
+// with.  Errors can be created with Error.
+func (f *Failures) Fail(operation string, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failures == nil {
+		f.failures = map[string][]error{}
+	}
+	f.failures[operation] = append(f.failures[operation], err)
+}
+
+// failure returns the error to fail the call of the operation with, if any.
+func (f *Failures) failure(operation string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	errs := f.failures[operation]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.failures[operation] = errs[1:]
+	return errs[0]
+}
+
+// call fails the call of the operation with its next error if any, or if the input is invalid, or with a
+// DryRunOperation error if the input sets DryRun, as the SDK and AWS would.
+func (f *Failures) call(operation string, input interface{}) error {
+	if err := f.failure(operation); err != nil {
+		return err
+	}
+	if validator, is := input.(request.Validator); is {
+		if err := validator.Validate(); err != nil {
+			return err
+		}
+	}
+	if v := reflect.ValueOf(input); v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		if dryRun := v.Elem().FieldByName("DryRun"); dryRun.IsValid() && !dryRun.IsNil() && dryRun.Elem().Bool() {
+			return newError(412, "DryRunOperation", "Request would have succeeded, but DryRun flag is set.")
+		}
+	}
+	return nil
+}
+
+// Error returns an error with the code, as returned by the SDK, e.g. to fail operations with.
+func Error(code, message string) error {
+	return newError(400, code, "%s", message)
+}
+
+// sortedKeys returns the keys of the map, sorted.
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// page returns the bounds of the page of the n items starting at the token, of at most max items if max is positive,
+// and the token of the next page if any.
+func page(n int, token *string, max int) (int, int, *string, error) {
+	start := 0
+	if token != nil && *token != "" {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start < 0 || start > n {
+			return 0, 0, nil, newError(400, "InvalidParameterValue", "Invalid token %s", *token)
+		}
+	}
+	if max <= 0 || start+max >= n {
+		return start, n, nil, nil
+	}
+	return start, start + max, aws.String(strconv.Itoa(start + max)), nil
+}
+
+// pageSize returns the size of pages, that of the request if set, or the default one.
+func pageSize(request *int64, defaultSize int) int {
+	if request != nil && *request > 0 {
+		return int(*request)
+	}
+	return defaultSize
+}
+
+// glob returns the regular expression of a filter value, in which * matches any characters, and ? any character.
+func glob(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+// filterValues are the values of the filters of a resource, by filter name.
+type filterValues map[string][]string
+
+// add adds the values of the filter, ignoring nil and empty values.
+func (v filterValues) add(name string, values ...*string) filterValues {
+	for _, value := range values {
+		if value != nil && *value != "" {
+			v[name] = append(v[name], *value)
+		}
+	}
+	return v
+}
+
+// matches returns true if the resource with the filter values and tags matches all the filters, i.e. it has a value
+// that matches one of the values of each filter.  Filters on tags are supported for all resources.  Unknown filters
+// fail, as in AWS.
+func matches(filters []*ec2.Filter, values filterValues, tags map[string]string) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+
+		actual := []string{}
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			if value, has := tags[strings.TrimPrefix(name, "tag:")]; has {
+				actual = append(actual, value)
+			}
+		case name == "tag-key":
+			actual = append(actual, sortedKeys(tags)...)
+		case name == "tag-value":
+			for _, k := range sortedKeys(tags) {
+				actual = append(actual, tags[k])
+			}
+		default:
+			known := false
+			if actual, known = values[name]; !known {
+				return false, newError(400, "InvalidParameterValue", "The filter '%s' is invalid", name)
+			}
+		}
+
+		matched := false
+		for _, expected := range filter.Values {
+			for _, value := range actual {
+				if glob(aws.StringValue(expected)).MatchString(value) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// newFilterValues returns the values of the filters of a resource, which supports the named filters.
+func newFilterValues(names ...string) filterValues {
+	values := filterValues{}
+	for _, name := range names {
+		values[name] = []string{}
+	}
+	return values
+}
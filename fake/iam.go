@@ -0,0 +1,597 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+)
+
+// iamPageSize is the default number of items of a page of IAM entities.
+const iamPageSize = 100
+
+// IAM is a fake of the IAM API, which supports roles with inline and managed policies, and instance profiles.
+type IAM struct {
+	iamiface.IAMAPI
+	Failures
+
+	lock sync.Mutex
+	ids  ids
+
+	roles            map[string]*iam.Role
+	rolePolicies     map[string]map[string]string
+	attachedPolicies map[string][]string
+	instanceProfiles map[string]*iam.InstanceProfile
+	policies         map[string]*iam.Policy
+}
+
+// NewIAM returns a fake of the IAM API.
+func NewIAM() *IAM {
+	return &IAM{
+		roles:            map[string]*iam.Role{},
+		rolePolicies:     map[string]map[string]string{},
+		attachedPolicies: map[string][]string{},
+		instanceProfiles: map[string]*iam.InstanceProfile{},
+		policies:         map[string]*iam.Policy{},
+	}
+}
+
+// noSuchEntity returns the error of an entity not being found.
+func noSuchEntity(kind, name string) error {
+	return newError(404, "NoSuchEntity", "The %s with name %s cannot be found.", kind, name)
+}
+
+// validatePath fails if the path of an entity is not a valid one, which starts and ends with a slash.
+func validatePath(path *string) error {
+	if path != nil && (!strings.HasPrefix(*path, "/") || !strings.HasSuffix(*path, "/")) {
+		return newError(400, "ValidationError", "The specified value for path is invalid. It must begin and end "+
+			"with / and contain only alphanumeric characters and/or / characters.")
+	}
+	return nil
+}
+
+// validateDocument fails if a policy document is not JSON.
+func validateDocument(document *string) error {
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(aws.StringValue(document)), &value); err != nil {
+		return newError(400, "MalformedPolicyDocument", "Syntax errors in policy.")
+	}
+	return nil
+}
+
+// pathOf returns the path of an entity, the root path unless set.
+func pathOf(path *string) string {
+	if path == nil {
+		return "/"
+	}
+	return *path
+}
+
+// pathNames returns the names of the entities of the map, a map of entities by name, with the path prefix, sorted.
+func pathNames(entities map[string]*string, prefix *string) []string {
+	names := []string{}
+	for name, path := range entities {
+		if strings.HasPrefix(aws.StringValue(path), pathOf(prefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// iamPage returns the bounds of a page of the n entities starting at the marker, and whether the list is
+// truncated with the marker of the next page.
+func iamPage(n int, marker *string, maxItems *int64) (int, int, *bool, *string, error) {
+	start, end, next, err := page(n, marker, pageSize(maxItems, iamPageSize))
+	return start, end, aws.Bool(next != nil), next, err
+}
+
+// CreateRole creates a role with a unique name, under a path.
+func (f *IAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	if err := f.call("CreateRole", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	for existing := range f.roles {
+		if strings.EqualFold(existing, name) {
+			return nil, newError(409, "EntityAlreadyExists", "Role with name %s already exists.", name)
+		}
+	}
+	if err := validatePath(input.Path); err != nil {
+		return nil, err
+	}
+	if err := validateDocument(input.AssumeRolePolicyDocument); err != nil {
+		return nil, err
+	}
+	arn := fmt.Sprintf("arn:aws:iam::%s:role%s%s", Account, pathOf(input.Path), name)
+	role := &iam.Role{
+		Arn:                      aws.String(arn),
+		AssumeRolePolicyDocument: input.AssumeRolePolicyDocument,
+		CreateDate:               aws.Time(time.Now()),
+		Path:                     aws.String(pathOf(input.Path)),
+		RoleId:                   aws.String(strings.ToUpper(strings.Replace(f.ids.next("AROA"), "-", "", -1))),
+		RoleName:                 aws.String(name),
+	}
+	f.roles[name] = role
+	f.rolePolicies[name] = map[string]string{}
+	return &iam.CreateRoleOutput{Role: awsutil.CopyOf(role).(*iam.Role)}, nil
+}
+
+// GetRole returns a role.
+func (f *IAM) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	if err := f.call("GetRole", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	role, has := f.roles[aws.StringValue(input.RoleName)]
+	if !has {
+		return nil, noSuchEntity("role", aws.StringValue(input.RoleName))
+	}
+	return &iam.GetRoleOutput{Role: awsutil.CopyOf(role).(*iam.Role)}, nil
+}
+
+// ListRoles lists the roles with the path prefix, in pages of MaxItems.
+func (f *IAM) ListRoles(input *iam.ListRolesInput) (*iam.ListRolesOutput, error) {
+	if err := f.call("ListRoles", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	paths := map[string]*string{}
+	for name, role := range f.roles {
+		paths[name] = role.Path
+	}
+	names := pathNames(paths, input.PathPrefix)
+	start, end, truncated, marker, err := iamPage(len(names), input.Marker, input.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	output := &iam.ListRolesOutput{Roles: []*iam.Role{}, IsTruncated: truncated, Marker: marker}
+	for _, name := range names[start:end] {
+		output.Roles = append(output.Roles, awsutil.CopyOf(f.roles[name]).(*iam.Role))
+	}
+	return output, nil
+}
+
+// DeleteRole deletes a role, which must have no policies nor instance profiles.
+func (f *IAM) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	if err := f.call("DeleteRole", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	if len(f.rolePolicies[name]) > 0 || len(f.attachedPolicies[name]) > 0 {
+		return nil, newError(409, "DeleteConflict", "Cannot delete entity, must delete policies first.")
+	}
+	for _, profile := range f.instanceProfiles {
+		for _, role := range profile.Roles {
+			if aws.StringValue(role.RoleName) == name {
+				return nil, newError(409, "DeleteConflict", "Cannot delete entity, must remove roles "+
+					"from instance profile first.")
+			}
+		}
+	}
+	delete(f.roles, name)
+	delete(f.rolePolicies, name)
+	delete(f.attachedPolicies, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+// PutRolePolicy adds or updates an inline policy of a role.
+func (f *IAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	if err := f.call("PutRolePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	if err := validateDocument(input.PolicyDocument); err != nil {
+		return nil, err
+	}
+	f.rolePolicies[name][aws.StringValue(input.PolicyName)] = aws.StringValue(input.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+// GetRolePolicy returns an inline policy of a role.
+func (f *IAM) GetRolePolicy(input *iam.GetRolePolicyInput) (*iam.GetRolePolicyOutput, error) {
+	if err := f.call("GetRolePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, policyName := aws.StringValue(input.RoleName), aws.StringValue(input.PolicyName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	document, has := f.rolePolicies[name][policyName]
+	if !has {
+		return nil, noSuchEntity("role policy", policyName)
+	}
+	return &iam.GetRolePolicyOutput{
+		PolicyDocument: aws.String(document),
+		PolicyName:     aws.String(policyName),
+		RoleName:       aws.String(name),
+	}, nil
+}
+
+// ListRolePolicies lists the names of the inline policies of a role, in pages of MaxItems.
+func (f *IAM) ListRolePolicies(input *iam.ListRolePoliciesInput) (*iam.ListRolePoliciesOutput, error) {
+	if err := f.call("ListRolePolicies", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	names := sortedKeys(f.rolePolicies[name])
+	start, end, truncated, marker, err := iamPage(len(names), input.Marker, input.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	return &iam.ListRolePoliciesOutput{
+		IsTruncated: truncated,
+		Marker:      marker,
+		PolicyNames: aws.StringSlice(names[start:end]),
+	}, nil
+}
+
+// DeleteRolePolicy deletes an inline policy of a role.
+func (f *IAM) DeleteRolePolicy(input *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	if err := f.call("DeleteRolePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, policyName := aws.StringValue(input.RoleName), aws.StringValue(input.PolicyName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	if _, has := f.rolePolicies[name][policyName]; !has {
+		return nil, noSuchEntity("role policy", policyName)
+	}
+	delete(f.rolePolicies[name], policyName)
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+// CreatePolicy creates a managed policy with a unique name, under a path.
+func (f *IAM) CreatePolicy(input *iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error) {
+	if err := f.call("CreatePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := validatePath(input.Path); err != nil {
+		return nil, err
+	}
+	if err := validateDocument(input.PolicyDocument); err != nil {
+		return nil, err
+	}
+	arn := fmt.Sprintf("arn:aws:iam::%s:policy%s%s", Account, pathOf(input.Path), aws.StringValue(input.PolicyName))
+	for _, policy := range f.policies {
+		if aws.StringValue(policy.PolicyName) == aws.StringValue(input.PolicyName) {
+			return nil, newError(409, "EntityAlreadyExists", "A policy called %s already exists.",
+				aws.StringValue(input.PolicyName))
+		}
+	}
+	now := time.Now()
+	policy := &iam.Policy{
+		Arn:              aws.String(arn),
+		AttachmentCount:  aws.Int64(0),
+		CreateDate:       aws.Time(now),
+		DefaultVersionId: aws.String("v1"),
+		Description:      input.Description,
+		IsAttachable:     aws.Bool(true),
+		Path:             aws.String(pathOf(input.Path)),
+		PolicyId:         aws.String(strings.ToUpper(strings.Replace(f.ids.next("ANPA"), "-", "", -1))),
+		PolicyName:       input.PolicyName,
+		UpdateDate:       aws.Time(now),
+	}
+	f.policies[arn] = policy
+	return &iam.CreatePolicyOutput{Policy: awsutil.CopyOf(policy).(*iam.Policy)}, nil
+}
+
+// ListPolicies lists the managed policies with the path prefix, only attached ones if requested, in pages of
+// MaxItems.
+func (f *IAM) ListPolicies(input *iam.ListPoliciesInput) (*iam.ListPoliciesOutput, error) {
+	if err := f.call("ListPolicies", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	paths := map[string]*string{}
+	for arn, policy := range f.policies {
+		if !aws.BoolValue(input.OnlyAttached) || aws.Int64Value(policy.AttachmentCount) > 0 {
+			paths[arn] = policy.Path
+		}
+	}
+	arns := pathNames(paths, input.PathPrefix)
+	start, end, truncated, marker, err := iamPage(len(arns), input.Marker, input.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	output := &iam.ListPoliciesOutput{Policies: []*iam.Policy{}, IsTruncated: truncated, Marker: marker}
+	for _, arn := range arns[start:end] {
+		output.Policies = append(output.Policies, awsutil.CopyOf(f.policies[arn]).(*iam.Policy))
+	}
+	return output, nil
+}
+
+// DeletePolicy deletes a managed policy that is not attached.
+func (f *IAM) DeletePolicy(input *iam.DeletePolicyInput) (*iam.DeletePolicyOutput, error) {
+	if err := f.call("DeletePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	arn := aws.StringValue(input.PolicyArn)
+	policy, has := f.policies[arn]
+	if !has {
+		return nil, newError(404, "NoSuchEntity", "Policy %s was not found.", arn)
+	}
+	if aws.Int64Value(policy.AttachmentCount) > 0 {
+		return nil, newError(409, "DeleteConflict", "Cannot delete a policy attached to entities.")
+	}
+	delete(f.policies, arn)
+	return &iam.DeletePolicyOutput{}, nil
+}
+
+// AttachRolePolicy attaches a managed policy to a role.
+func (f *IAM) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	if err := f.call("AttachRolePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, arn := aws.StringValue(input.RoleName), aws.StringValue(input.PolicyArn)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	policy, has := f.policies[arn]
+	if !has {
+		return nil, newError(404, "NoSuchEntity", "Policy %s was not found.", arn)
+	}
+	for _, attached := range f.attachedPolicies[name] {
+		if attached == arn {
+			return &iam.AttachRolePolicyOutput{}, nil
+		}
+	}
+	f.attachedPolicies[name] = append(f.attachedPolicies[name], arn)
+	policy.AttachmentCount = aws.Int64(aws.Int64Value(policy.AttachmentCount) + 1)
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+// ListAttachedRolePolicies lists the managed policies attached to a role, in pages of MaxItems.
+func (f *IAM) ListAttachedRolePolicies(input *iam.ListAttachedRolePoliciesInput) (
+	*iam.ListAttachedRolePoliciesOutput, error) {
+	if err := f.call("ListAttachedRolePolicies", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.RoleName)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	policies := []*iam.AttachedPolicy{}
+	for _, arn := range f.attachedPolicies[name] {
+		policy := f.policies[arn]
+		if strings.HasPrefix(aws.StringValue(policy.Path), pathOf(input.PathPrefix)) {
+			policies = append(policies, &iam.AttachedPolicy{PolicyArn: policy.Arn, PolicyName: policy.PolicyName})
+		}
+	}
+	start, end, truncated, marker, err := iamPage(len(policies), input.Marker, input.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	return &iam.ListAttachedRolePoliciesOutput{
+		AttachedPolicies: policies[start:end],
+		IsTruncated:      truncated,
+		Marker:           marker,
+	}, nil
+}
+
+// DetachRolePolicy detaches a managed policy from a role.
+func (f *IAM) DetachRolePolicy(input *iam.DetachRolePolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	if err := f.call("DetachRolePolicy", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, arn := aws.StringValue(input.RoleName), aws.StringValue(input.PolicyArn)
+	if _, has := f.roles[name]; !has {
+		return nil, noSuchEntity("role", name)
+	}
+	for i, attached := range f.attachedPolicies[name] {
+		if attached == arn {
+			f.attachedPolicies[name] = append(f.attachedPolicies[name][:i], f.attachedPolicies[name][i+1:]...)
+			policy := f.policies[arn]
+			policy.AttachmentCount = aws.Int64(aws.Int64Value(policy.AttachmentCount) - 1)
+			return &iam.DetachRolePolicyOutput{}, nil
+		}
+	}
+	return nil, newError(404, "NoSuchEntity", "Policy %s was not found.", arn)
+}
+
+// CreateInstanceProfile creates an instance profile with a unique name, under a path.
+func (f *IAM) CreateInstanceProfile(input *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput,
+	error) {
+	if err := f.call("CreateInstanceProfile", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.InstanceProfileName)
+	if _, has := f.instanceProfiles[name]; has {
+		return nil, newError(409, "EntityAlreadyExists", "Instance Profile %s already exists.",
+			name)
+	}
+	if err := validatePath(input.Path); err != nil {
+		return nil, err
+	}
+	arn := fmt.Sprintf("arn:aws:iam::%s:instance-profile%s%s", Account, pathOf(input.Path), name)
+	profile := &iam.InstanceProfile{
+		Arn:                 aws.String(arn),
+		CreateDate:          aws.Time(time.Now()),
+		InstanceProfileId:   aws.String(strings.ToUpper(strings.Replace(f.ids.next("AIPA"), "-", "", -1))),
+		InstanceProfileName: aws.String(name),
+		Path:                aws.String(pathOf(input.Path)),
+		Roles:               []*iam.Role{},
+	}
+	f.instanceProfiles[name] = profile
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: awsutil.CopyOf(profile).(*iam.InstanceProfile)}, nil
+}
+
+// GetInstanceProfile returns an instance profile, with its roles.
+func (f *IAM) GetInstanceProfile(input *iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error) {
+	if err := f.call("GetInstanceProfile", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	profile, has := f.instanceProfiles[aws.StringValue(input.InstanceProfileName)]
+	if !has {
+		return nil, noSuchEntity("instance profile", aws.StringValue(input.InstanceProfileName))
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: awsutil.CopyOf(profile).(*iam.InstanceProfile)}, nil
+}
+
+// WaitUntilInstanceProfileExists waits until an instance profile exists.
+func (f *IAM) WaitUntilInstanceProfileExists(input *iam.GetInstanceProfileInput) error {
+	if _, err := f.GetInstanceProfile(input); err != nil {
+		return notReady(aws.StringValue(input.InstanceProfileName))
+	}
+	return nil
+}
+
+// ListInstanceProfiles lists the instance profiles with the path prefix, in pages of MaxItems.
+func (f *IAM) ListInstanceProfiles(input *iam.ListInstanceProfilesInput) (*iam.ListInstanceProfilesOutput, error) {
+	if err := f.call("ListInstanceProfiles", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	paths := map[string]*string{}
+	for name, profile := range f.instanceProfiles {
+		paths[name] = profile.Path
+	}
+	names := pathNames(paths, input.PathPrefix)
+	start, end, truncated, marker, err := iamPage(len(names), input.Marker, input.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	output := &iam.ListInstanceProfilesOutput{
+		InstanceProfiles: []*iam.InstanceProfile{},
+		IsTruncated:      truncated,
+		Marker:           marker,
+	}
+	for _, name := range names[start:end] {
+		output.InstanceProfiles = append(output.InstanceProfiles,
+			awsutil.CopyOf(f.instanceProfiles[name]).(*iam.InstanceProfile))
+	}
+	return output, nil
+}
+
+// DeleteInstanceProfile deletes an instance profile without roles.
+func (f *IAM) DeleteInstanceProfile(input *iam.DeleteInstanceProfileInput) (*iam.DeleteInstanceProfileOutput,
+	error) {
+	if err := f.call("DeleteInstanceProfile", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.InstanceProfileName)
+	profile, has := f.instanceProfiles[name]
+	if !has {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	if len(profile.Roles) > 0 {
+		return nil, newError(409, "DeleteConflict", "Cannot delete entity, must remove roles "+
+			"from instance profile first.")
+	}
+	delete(f.instanceProfiles, name)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+// AddRoleToInstanceProfile adds a role to an instance profile, which can have only one.
+func (f *IAM) AddRoleToInstanceProfile(input *iam.AddRoleToInstanceProfileInput) (
+	*iam.AddRoleToInstanceProfileOutput, error) {
+	if err := f.call("AddRoleToInstanceProfile", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, roleName := aws.StringValue(input.InstanceProfileName), aws.StringValue(input.RoleName)
+	profile, has := f.instanceProfiles[name]
+	if !has {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	role, has := f.roles[roleName]
+	if !has {
+		return nil, noSuchEntity("role", roleName)
+	}
+	if len(profile.Roles) > 0 {
+		return nil, newError(409, "LimitExceeded", "Cannot exceed quota for "+
+			"InstanceSessionsPerInstanceProfile: 1")
+	}
+	profile.Roles = []*iam.Role{role}
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+// RemoveRoleFromInstanceProfile removes the role of an instance profile.
+func (f *IAM) RemoveRoleFromInstanceProfile(input *iam.RemoveRoleFromInstanceProfileInput) (
+	*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	if err := f.call("RemoveRoleFromInstanceProfile", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, roleName := aws.StringValue(input.InstanceProfileName), aws.StringValue(input.RoleName)
+	profile, has := f.instanceProfiles[name]
+	if !has {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	if len(profile.Roles) == 0 || aws.StringValue(profile.Roles[0].RoleName) != roleName {
+		return nil, noSuchEntity("role", roleName)
+	}
+	profile.Roles = []*iam.Role{}
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
@@ -0,0 +1,89 @@
+package fake
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/stretchr/testify/require"
+)
+
+var _ iamiface.IAMAPI = NewIAM()
+
+const assumeRolePolicy = `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", ` +
+	`"Principal": {"Service": "ec2.amazonaws.com"}, "Action": "sts:AssumeRole"}]}`
+
+func TestRolesAndInstanceProfiles(t *testing.T) {
+	f := NewIAM()
+
+	role, err := f.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 aws.String("workers"),
+		Path:                     aws.String("/infrakit/"),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "arn:aws:iam::"+Account+":role/infrakit/workers", *role.Role.Arn)
+
+	_, err = f.CreateRole(&iam.CreateRoleInput{
+		RoleName:                 aws.String("workers"),
+		AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+	})
+	requireCode(t, "EntityAlreadyExists", err)
+
+	_, err = f.PutRolePolicy(&iam.PutRolePolicyInput{
+		RoleName:       aws.String("workers"),
+		PolicyName:     aws.String("broken"),
+		PolicyDocument: aws.String("{"),
+	})
+	requireCode(t, "MalformedPolicyDocument", err)
+
+	_, err = f.CreateInstanceProfile(&iam.CreateInstanceProfileInput{InstanceProfileName: aws.String("workers")})
+	require.NoError(t, err)
+	_, err = f.AddRoleToInstanceProfile(&iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String("workers"),
+		RoleName:            aws.String("workers"),
+	})
+	require.NoError(t, err)
+
+	_, err = f.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String("workers")})
+	requireCode(t, "DeleteConflict", err)
+
+	profile, err := f.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: aws.String("workers")})
+	require.NoError(t, err)
+	require.Len(t, profile.InstanceProfile.Roles, 1)
+	require.Equal(t, "workers", *profile.InstanceProfile.Roles[0].RoleName)
+
+	_, err = f.RemoveRoleFromInstanceProfile(&iam.RemoveRoleFromInstanceProfileInput{
+		InstanceProfileName: aws.String("workers"),
+		RoleName:            aws.String("workers"),
+	})
+	require.NoError(t, err)
+	_, err = f.DeleteRole(&iam.DeleteRoleInput{RoleName: aws.String("workers")})
+	require.NoError(t, err)
+
+	_, err = f.GetRole(&iam.GetRoleInput{RoleName: aws.String("workers")})
+	requireCode(t, "NoSuchEntity", err)
+}
+
+func TestListRolesPages(t *testing.T) {
+	f := NewIAM()
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := f.CreateRole(&iam.CreateRoleInput{
+			RoleName:                 aws.String(name),
+			AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+		})
+		require.NoError(t, err)
+	}
+
+	output, err := f.ListRoles(&iam.ListRolesInput{MaxItems: aws.Int64(2)})
+	require.NoError(t, err)
+	require.Len(t, output.Roles, 2)
+	require.True(t, *output.IsTruncated)
+
+	output, err = f.ListRoles(&iam.ListRolesInput{Marker: output.Marker})
+	require.NoError(t, err)
+	require.Len(t, output.Roles, 1)
+	require.Equal(t, "c", *output.Roles[0].RoleName)
+	require.False(t, *output.IsTruncated)
+}
@@ -0,0 +1,198 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// SQS is a fake of the SQS API, which supports queues and their attributes.
+type SQS struct {
+	sqsiface.SQSAPI
+	Failures
+
+	lock sync.Mutex
+
+	// queues are the attributes of the queues, by name.
+	queues map[string]map[string]string
+}
+
+// NewSQS returns a fake of the SQS API.
+func NewSQS() *SQS {
+	return &SQS{queues: map[string]map[string]string{}}
+}
+
+// queueURL returns the URL of the queue with the name.
+func queueURL(name string) string {
+	return fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", Region, Account, name)
+}
+
+// nonExistentQueue returns the error of a queue not existing.
+func nonExistentQueue() error {
+	return newError(400, "AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this "+
+		"wsdl version.")
+}
+
+// queueOf returns the name and attributes of the queue with the URL.
+func (f *SQS) queueOf(url *string) (string, map[string]string, error) {
+	name := aws.StringValue(url)[strings.LastIndex(aws.StringValue(url), "/")+1:]
+	attributes, has := f.queues[name]
+	if !has || queueURL(name) != aws.StringValue(url) {
+		return "", nil, nonExistentQueue()
+	}
+	return name, attributes, nil
+}
+
+// CreateQueue creates a queue with a unique name and attributes, or returns the URL of the existing queue with the
+// name if it has the same attributes.
+func (f *SQS) CreateQueue(input *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	if err := f.call("CreateQueue", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name := aws.StringValue(input.QueueName)
+	if len(name) > 80 || strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+		return nil, newError(400, "InvalidParameterValue", "Can only include alphanumeric characters, hyphens, or "+
+			"underscores. 1 to 80 in length")
+	}
+	if existing, has := f.queues[name]; has {
+		for key, value := range input.Attributes {
+			if existing[key] != aws.StringValue(value) {
+				return nil, newError(400, "QueueAlreadyExists", "A queue already exists with the same name and a "+
+					"different value for attribute %s", key)
+			}
+		}
+		return &sqs.CreateQueueOutput{QueueUrl: aws.String(queueURL(name))}, nil
+	}
+
+	now := fmt.Sprint(time.Now().Unix())
+	attributes := map[string]string{
+		"ApproximateNumberOfMessages":           "0",
+		"ApproximateNumberOfMessagesDelayed":    "0",
+		"ApproximateNumberOfMessagesNotVisible": "0",
+		"CreatedTimestamp":                      now,
+		"DelaySeconds":                          "0",
+		"LastModifiedTimestamp":                 now,
+		"MaximumMessageSize":                    "262144",
+		"MessageRetentionPeriod":                "345600",
+		"QueueArn":                              fmt.Sprintf("arn:aws:sqs:%s:%s:%s", Region, Account, name),
+		"ReceiveMessageWaitTimeSeconds":         "0",
+		"VisibilityTimeout":                     "30",
+	}
+	for key, value := range input.Attributes {
+		attributes[key] = aws.StringValue(value)
+	}
+	f.queues[name] = attributes
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String(queueURL(name))}, nil
+}
+
+// GetQueueUrl returns the URL of the queue with the name.
+func (f *SQS) GetQueueUrl(input *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	if err := f.call("GetQueueUrl", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, has := f.queues[aws.StringValue(input.QueueName)]; !has {
+		return nil, nonExistentQueue()
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(queueURL(*input.QueueName))}, nil
+}
+
+// GetQueueAttributes returns the named attributes of a queue, or all of them.
+func (f *SQS) GetQueueAttributes(input *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	if err := f.call("GetQueueAttributes", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, attributes, err := f.queueOf(input.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	output := &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{}}
+	for _, name := range input.AttributeNames {
+		if aws.StringValue(name) == "All" {
+			for key, value := range attributes {
+				output.Attributes[key] = aws.String(value)
+			}
+			continue
+		}
+		if value, has := attributes[aws.StringValue(name)]; has {
+			output.Attributes[*name] = aws.String(value)
+		} else {
+			return nil, newError(400, "InvalidAttributeName", "Unknown Attribute %s.", *name)
+		}
+	}
+	return output, nil
+}
+
+// SetQueueAttributes sets attributes of a queue.
+func (f *SQS) SetQueueAttributes(input *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	if err := f.call("SetQueueAttributes", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, attributes, err := f.queueOf(input.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range input.Attributes {
+		if key == "QueueArn" || strings.HasPrefix(key, "Approximate") || strings.HasSuffix(key, "Timestamp") {
+			return nil, newError(400, "InvalidAttributeName", "Unknown Attribute %s.", key)
+		}
+		attributes[key] = aws.StringValue(value)
+	}
+	attributes["LastModifiedTimestamp"] = fmt.Sprint(time.Now().Unix())
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+// ListQueues lists the URLs of the queues with the name prefix.
+func (f *SQS) ListQueues(input *sqs.ListQueuesInput) (*sqs.ListQueuesOutput, error) {
+	if err := f.call("ListQueues", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	names := []string{}
+	for name := range f.queues {
+		if strings.HasPrefix(name, aws.StringValue(input.QueueNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	output := &sqs.ListQueuesOutput{QueueUrls: []*string{}}
+	for _, name := range names {
+		output.QueueUrls = append(output.QueueUrls, aws.String(queueURL(name)))
+	}
+	return output, nil
+}
+
+// DeleteQueue deletes a queue.
+func (f *SQS) DeleteQueue(input *sqs.DeleteQueueInput) (*sqs.DeleteQueueOutput, error) {
+	if err := f.call("DeleteQueue", input); err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	name, _, err := f.queueOf(input.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	delete(f.queues, name)
+	return &sqs.DeleteQueueOutput{}, nil
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/stretchr/testify/require"
+)
+
+func tempJournal(t *testing.T, maxSize int64, maxFiles int) (*Journal, string, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "audit.jsonl")
+	journal, err := Open(path, maxSize, maxFiles)
+	require.NoError(t, err)
+	return journal, path, func() {
+		journal.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestJournalRotation(t *testing.T) {
+	journal, path, cleanup := tempJournal(t, 100, 2)
+	defer cleanup()
+
+	for _, operation := range []string{"A", "B", "C", "D", "E"} {
+		require.NoError(t, journal.Append(Record{Plugin: "test", Service: "ec2", Operation: operation}))
+	}
+
+	// Each record fits alone in a file, and only the 2 latest rotated files are kept.
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		_, err := os.Stat(p)
+		require.NoError(t, err)
+	}
+	_, err := os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+
+	records, err := Read(path, 2, nil)
+	require.NoError(t, err)
+	operations := []string{}
+	for _, record := range records {
+		operations = append(operations, record.Operation)
+	}
+	require.Equal(t, []string{"C", "D", "E"}, operations)
+}
+
+func TestJournalRotationFailure(t *testing.T) {
+	journal, path, cleanup := tempJournal(t, 100, 1)
+	defer cleanup()
+
+	// The rotated file can't be replaced by the journal while it is a directory that isn't empty.
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "blocked"), 0700))
+
+	require.NoError(t, journal.Append(Record{Plugin: "test", Service: "ec2", Operation: "A"}))
+	require.Error(t, journal.Append(Record{Plugin: "test", Service: "ec2", Operation: "B"}))
+	require.Error(t, journal.Append(Record{Plugin: "test", Service: "ec2", Operation: "C"}))
+
+	records, err := Read(path, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	// The journal rotates again once the rotated file can be replaced.
+	require.NoError(t, os.RemoveAll(path+".1"))
+	require.NoError(t, journal.Append(Record{Plugin: "test", Service: "ec2", Operation: "D"}))
+	records, err = Read(path, 1, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	records, err = Read(path, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+}
+
+func TestResources(t *testing.T) {
+	require.Equal(t, []string{"i-1", "i-2"},
+		resources(&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String("i-1"), aws.String("i-2")}}))
+	require.Equal(t, []string{"ami-1", "subnet-1"},
+		resources(&ec2.RunInstancesInput{ImageId: aws.String("ami-1"), SubnetId: aws.String("subnet-1")}))
+
+	require.Equal(t, []string{"i-1"}, created("RunInstances", &ec2.Reservation{
+		OwnerId:   aws.String("123"),
+		Groups:    []*ec2.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+		Instances: []*ec2.Instance{{InstanceId: aws.String("i-1"), SubnetId: aws.String("subnet-1")}},
+	}))
+	require.Equal(t, []string{"vol-1"}, created("CreateVolume",
+		&ec2.Volume{VolumeId: aws.String("vol-1"), SnapshotId: aws.String("snap-1")}))
+	require.Equal(t, []string{"vpc-1"}, created("CreateVpc",
+		&ec2.CreateVpcOutput{Vpc: &ec2.Vpc{VpcId: aws.String("vpc-1"), DhcpOptionsId: aws.String("dopt-1")}}))
+}
+
+func TestAuditor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		w.Write([]byte(`<Response></Response>`))
+	}))
+	defer server.Close()
+
+	journal, path, cleanup := tempJournal(t, 0, 0)
+	defer cleanup()
+
+	auditor := journal.Auditor("ec2-instance")
+	client := ec2.New(auditor.Client(session.New(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))))
+
+	// Describes are not journaled.
+	_, err := client.DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+
+	// Operations are journaled with the call whose client makes them, whatever the other calls in progress.
+	call := auditor.Begin("Destroy", "i-1", map[string]string{groupTag: "workers"})
+	other := auditor.Begin("Destroy", "i-3", nil)
+	_, err = call.Service(client).(*ec2.EC2).TerminateInstances(
+		&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String("i-1")}})
+	require.NoError(t, err)
+
+	_, err = client.TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String("i-2")}})
+	require.NoError(t, err)
+
+	_, err = call.Service(other.Service(client)).(*ec2.EC2).CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String("i-1")},
+		Tags:      []*ec2.Tag{{Key: aws.String("key"), Value: aws.String("value")}},
+	})
+	require.NoError(t, err)
+
+	records, err := Read(path, 0, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, "ec2-instance", records[0].Plugin)
+	require.Equal(t, "Destroy", records[0].Method)
+	require.Equal(t, "i-1", records[0].ID)
+	require.Equal(t, "TerminateInstances", records[0].Operation)
+	require.Equal(t, []string{"i-1"}, records[0].Resources)
+	require.Equal(t, "req-1", records[0].RequestID)
+	require.Equal(t, "success", records[0].Outcome)
+	require.Equal(t, "", records[1].Method)
+	require.Equal(t, "CreateTags", records[2].Operation)
+	require.Equal(t, "i-1", records[2].ID)
+
+	// Clients of other kinds, e.g. mocks, are used as they are.
+	mock := struct{ ec2iface.EC2API }{}
+	require.Equal(t, mock, call.Service(mock))
+
+	records, err = Read(path, 0, Filter("i-2", ""))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+}
+
+// callPlugin records the calls of the plugins of its calls.
+type callPlugin struct {
+	instance.Plugin
+	calls *[]*Call
+}
+
+func (p callPlugin) WithCall(call *Call) instance.Plugin {
+	*p.calls = append(*p.calls, call)
+	return p
+}
+
+func (p callPlugin) Destroy(id instance.ID) error {
+	return nil
+}
+
+func TestInstancePluginCalls(t *testing.T) {
+	journal, _, cleanup := tempJournal(t, 0, 0)
+	defer cleanup()
+
+	calls := []*Call{}
+	plugin := journal.Auditor("ec2-instance").InstancePlugin(callPlugin{calls: &calls})
+	require.NoError(t, plugin.Destroy("i-1"))
+	require.NoError(t, plugin.Destroy("i-2"))
+
+	require.Len(t, calls, 2)
+	require.Equal(t, "Destroy", calls[0].method)
+	require.Equal(t, "i-1", calls[0].id)
+	require.Equal(t, "i-2", calls[1].id)
+
+	// Without a journal, the plugin is used as it is.
+	require.Equal(t, callPlugin{calls: &calls}, (*Journal)(nil).Auditor("ec2-instance").InstancePlugin(
+		callPlugin{calls: &calls}))
+}
+
+func TestFilterGroup(t *testing.T) {
+	filter := Filter("", "workers")
+	require.True(t, filter(Record{Tags: map[string]string{groupTag: "workers"}, Created: []string{"i-1"}}))
+	require.False(t, filter(Record{Tags: map[string]string{groupTag: "managers"}, Created: []string{"i-2"}}))
+	require.True(t, filter(Record{Method: "Destroy", ID: "i-1"}))
+	require.False(t, filter(Record{Method: "Destroy", ID: "i-2"}))
+}
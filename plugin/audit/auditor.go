@@ -0,0 +1,275 @@
+package audit
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// mutatingPrefixes are the prefixes of the names of mutating operations.
+var mutatingPrefixes = []string{
+	"Add", "Allocate", "Apply", "Associate", "Attach", "Authorize", "Create", "Delete", "Deregister", "Detach",
+	"Disable", "Disassociate", "Enable", "Modify", "Put", "Reboot", "Register", "Release", "Remove", "Replace",
+	"Revoke", "Run", "Set", "Start", "Stop", "Tag", "Terminate", "Untag", "Update",
+}
+
+func isMutating(operation string) bool {
+	for _, prefix := range mutatingPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Call is a call of a plugin method.  Its operations are journaled with it when they are made with the clients of the
+// call, since the SDK doesn't pass the context of requests.
+type Call struct {
+	auditor *Auditor
+	method  string
+	id      string
+	tags    map[string]string
+}
+
+// Auditor journals the mutating operations of a plugin.  A nil Auditor journals nothing.
+type Auditor struct {
+	journal *Journal
+	plugin  string
+}
+
+// Auditor returns the auditor of the plugin, or nil if the journal is nil.
+func (j *Journal) Auditor(plugin string) *Auditor {
+	if j == nil {
+		return nil
+	}
+	return &Auditor{journal: j, plugin: plugin}
+}
+
+// Begin begins a call of the method, with the ID of the instance and the tags it is called with.
+func (a *Auditor) Begin(method, id string, tags map[string]string) *Call {
+	if a == nil {
+		return nil
+	}
+	return &Call{auditor: a, method: method, id: id, tags: tags}
+}
+
+// Begin begins another call of the plugin of the call, e.g. for work it leaves to be done in the background.
+func (c *Call) Begin(method, id string, tags map[string]string) *Call {
+	if c == nil {
+		return nil
+	}
+	return c.auditor.Begin(method, id, tags)
+}
+
+// Client returns a copy of the session journaling the mutating operations of its clients without a call.
+func (a *Auditor) Client(config client.ConfigProvider) client.ConfigProvider {
+	if a == nil {
+		return config
+	}
+	return a.client(config, nil)
+}
+
+// Client returns a copy of the session journaling the mutating operations of its clients with the call.
+func (c *Call) Client(config client.ConfigProvider) client.ConfigProvider {
+	if c == nil {
+		return config
+	}
+	return c.auditor.client(config, c)
+}
+
+func (a *Auditor) client(config client.ConfigProvider, call *Call) client.ConfigProvider {
+	sess, is := config.(*session.Session)
+	if !is {
+		log.Warnf("Operations of %s are not audited", a.plugin)
+		return config
+	}
+	sess = sess.Copy()
+	a.install(&sess.Handlers, call)
+	return sess
+}
+
+// Service returns a copy of the client of an AWS service, e.g. an *ec2.EC2, journaling its mutating operations with
+// the call.  Other clients, e.g. mocks, are returned as they are.
+func (c *Call) Service(service interface{}) interface{} {
+	if c == nil {
+		return service
+	}
+	v := reflect.ValueOf(service)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return service
+	}
+	field := v.Elem().FieldByName("Client")
+	if !field.IsValid() || field.Type() != reflect.TypeOf(&client.Client{}) || field.IsNil() {
+		return service
+	}
+
+	copied := *field.Interface().(*client.Client)
+	copied.Handlers = copied.Handlers.Copy()
+	c.auditor.install(&copied.Handlers, c)
+
+	serviceCopy := reflect.New(v.Elem().Type())
+	serviceCopy.Elem().Set(v.Elem())
+	serviceCopy.Elem().FieldByName("Client").Set(reflect.ValueOf(&copied))
+	return serviceCopy.Interface()
+}
+
+// Install installs the handlers journaling the mutating operations of the requests without a call.
+func (a *Auditor) Install(handlers *request.Handlers) {
+	if a == nil {
+		return
+	}
+	a.install(handlers, nil)
+}
+
+// Names of the handlers journaling operations, replaced by those of the call of copies of clients.
+const (
+	unmarshalHandler  = "audit.Unmarshal"
+	afterRetryHandler = "audit.AfterRetry"
+)
+
+func (a *Auditor) install(handlers *request.Handlers, call *Call) {
+	// Successful requests end with the unmarshal handlers, and failed ones with the after retry handlers.
+	unmarshal := request.NamedHandler{Name: unmarshalHandler, Fn: func(r *request.Request) {
+		if r.Error == nil {
+			a.record(r, call)
+		}
+	}}
+	afterRetry := request.NamedHandler{Name: afterRetryHandler, Fn: func(r *request.Request) {
+		if r.Error != nil {
+			a.record(r, call)
+		}
+	}}
+	handlers.Unmarshal.Remove(unmarshal)
+	handlers.Unmarshal.PushBackNamed(unmarshal)
+	handlers.AfterRetry.Remove(afterRetry)
+	handlers.AfterRetry.PushBackNamed(afterRetry)
+}
+
+func (a *Auditor) record(r *request.Request, call *Call) {
+	if !isMutating(r.Operation.Name) {
+		return
+	}
+
+	record := Record{
+		Time:      time.Now().UTC(),
+		Plugin:    a.plugin,
+		Service:   r.ClientInfo.ServiceName,
+		Operation: r.Operation.Name,
+		Resources: resources(r.Params),
+		Created:   created(r.Operation.Name, r.Data),
+		RequestID: r.RequestID,
+		Outcome:   "success",
+	}
+	if record.RequestID == "" && r.HTTPResponse != nil {
+		// The SDK doesn't read the request IDs of successful EC2 requests.
+		record.RequestID = r.HTTPResponse.Header.Get("X-Amzn-Requestid")
+	}
+	if call != nil {
+		record.Method, record.ID, record.Tags = call.method, call.id, call.tags
+	}
+	if r.Error != nil {
+		record.Outcome, record.Error = "error", r.Error.Error()
+	}
+
+	if err := a.journal.Append(record); err != nil {
+		log.Warnln("Failed to journal operation:", err)
+	}
+}
+
+// idSuffixes are the suffixes of the names of the fields identifying resources.
+var idSuffixes = []string{"Id", "Ids", "Name", "Names", "Url", "Arn"}
+
+// idField returns the name of the kind of resource the field identifies, e.g. Instance for InstanceId, if it does.
+func idField(name string) (string, bool) {
+	for _, suffix := range idSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), true
+		}
+	}
+	return "", false
+}
+
+// values collects the non-empty strings of a string pointer, or of a list of string pointers.
+type values struct {
+	found []string
+	seen  map[string]bool
+}
+
+func (v *values) add(value reflect.Value) {
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			v.add(value.Index(i))
+		}
+		return
+	}
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.String {
+		return
+	}
+	if s := value.Elem().String(); s != "" && !v.seen[s] {
+		v.seen[s] = true
+		v.found = append(v.found, s)
+	}
+}
+
+func fields(value interface{}, f func(name string, v reflect.Value)) {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Type().Field(i); field.PkgPath == "" {
+			f(field.Name, v.Field(i))
+		}
+	}
+}
+
+// resources returns the IDs and names of the resources in the input of an operation, by the names of its fields.
+func resources(input interface{}) []string {
+	found := &values{seen: map[string]bool{}}
+	fields(input, func(name string, v reflect.Value) {
+		if _, is := idField(name); is || name == "Resources" {
+			found.add(v)
+		}
+	})
+	return found.found
+}
+
+// created returns the IDs and names of the resources in the output of an operation that are of the kind of resource
+// named by the operation, e.g. the VolumeId of CreateVolume, the Vpc.VpcId of CreateVpc, or the InstanceId of the
+// Instances of RunInstances.
+func created(operation string, output interface{}) []string {
+	found := &values{seen: map[string]bool{}}
+	var visit func(name string, v reflect.Value)
+	visit = func(name string, v reflect.Value) {
+		if kind, is := idField(name); is {
+			if kind != "" && strings.Contains(operation, kind) {
+				found.add(v)
+			}
+			return
+		}
+		switch {
+		case v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct:
+			fields(v.Interface(), func(name string, v reflect.Value) {
+				if kind, is := idField(name); is && kind != "" && strings.Contains(operation, kind) {
+					found.add(v)
+				}
+			})
+		case v.Kind() == reflect.Slice && strings.HasSuffix(operation, name):
+			for i := 0; i < v.Len(); i++ {
+				element := reflect.Indirect(v.Index(i))
+				if element.Kind() == reflect.Struct {
+					if id := element.FieldByName(strings.TrimSuffix(name, "s") + "Id"); id.IsValid() {
+						found.add(id)
+					}
+				}
+			}
+		}
+	}
+	fields(output, visit)
+	return found.found
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// groupTag is the tag the group plugin identifies the instances of a group with.
+const groupTag = "infrakit.group"
+
+// Command returns the command printing the records of the journal about a resource or a group.
+func Command() *cobra.Command {
+	options := Options{}
+	var resource, group string
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "print the records of the audit journal about a resource or a group",
+		RunE: func(c *cobra.Command, args []string) error {
+			if options.Path == "" {
+				return fmt.Errorf("--audit-journal must be set")
+			}
+			records, err := Read(options.Path, options.MaxFiles, Filter(resource, group))
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			for _, record := range records {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().AddFlagSet(options.Flags())
+	cmd.Flags().StringVar(&resource, "resource", "", "ID or name of a resource, e.g. an instance ID")
+	cmd.Flags().StringVar(&group, "group", "", "ID of a group")
+	return cmd
+}
+
+// Filter returns the filter of the records about the resource, if not empty, and the group, if not empty.  Records
+// must be filtered oldest first: the records of a group are those with the group tag, and those about the resources
+// created by earlier records of the group, e.g. about an instance destroyed after its group provisioned it.
+func Filter(resource, group string) func(Record) bool {
+	groupResources := map[string]bool{}
+	inGroup := func(record Record) bool {
+		if record.Tags[groupTag] == group {
+			for _, r := range record.Created {
+				groupResources[r] = true
+			}
+			return true
+		}
+		if groupResources[record.ID] {
+			return true
+		}
+		for _, r := range record.Resources {
+			if groupResources[r] {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(record Record) bool {
+		if group != "" && !inGroup(record) {
+			return false
+		}
+		if resource == "" || record.ID == resource {
+			return true
+		}
+		for _, r := range record.Resources {
+			if r == resource {
+				return true
+			}
+		}
+		for _, r := range record.Created {
+			if r == resource {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,214 @@
+// Package audit journals the mutating AWS API operations of the plugins.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Record is the record of a mutating AWS API operation in the journal.
+type Record struct {
+	// Time is when the operation completed.
+	Time time.Time `json:"time"`
+
+	// Plugin is the resource type of the plugin making the operation, or infrakitctl.
+	Plugin string `json:"plugin"`
+
+	// Method is the plugin method the operation was made for, if known.
+	Method string `json:"method,omitempty"`
+
+	// ID is the ID of the instance the method was called with, if any.
+	ID string `json:"id,omitempty"`
+
+	// Tags are the tags of the spec of the method, or the labels of Label.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Service and Operation are the names of the AWS API operation, e.g. ec2 and TerminateInstances.
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+
+	// Resources are the IDs and names of the resources in the request.
+	Resources []string `json:"resources,omitempty"`
+
+	// Created are the IDs and names of the resources created by the operation, as returned in the response.
+	Created []string `json:"created,omitempty"`
+
+	// RequestID is the AWS request ID.
+	RequestID string `json:"request_id,omitempty"`
+
+	// Outcome is success or error.
+	Outcome string `json:"outcome"`
+
+	// Error is the error of the operation, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Options configure the journal.
+type Options struct {
+	// Path is the path of the journal.  Operations are not journaled if it is empty.
+	Path string
+
+	// MaxSize is the size in megabytes the journal is rotated at.
+	MaxSize int
+
+	// MaxFiles is the number of rotated journals kept, as <path>.1 to <path>.<MaxFiles>, the first being the latest.
+	MaxFiles int
+}
+
+// Flags returns the flags of the options.
+func (o *Options) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("audit", pflag.PanicOnError)
+	flags.StringVar(&o.Path, "audit-journal", "", "Path of the JSONL audit journal of mutating AWS API operations")
+	flags.IntVar(&o.MaxSize, "audit-max-size", 100, "Size in megabytes the audit journal is rotated at")
+	flags.IntVar(&o.MaxFiles, "audit-max-files", 5, "Number of rotated audit journals kept")
+	return flags
+}
+
+// Open opens the journal of the options, or returns nil if there is no journal.
+func (o Options) Open() (*Journal, error) {
+	if o.Path == "" {
+		return nil, nil
+	}
+	return Open(o.Path, int64(o.MaxSize)*1024*1024, o.MaxFiles)
+}
+
+// Journal appends records to a JSONL file, rotated at a maximum size.
+type Journal struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens the journal at the path for appending.
+func Open(path string, maxSize int64, maxFiles int) (*Journal, error) {
+	j := &Journal{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to open audit journal: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to open audit journal: %s", err)
+	}
+	j.file, j.size = file, info.Size()
+	return nil
+}
+
+func rotated(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// rotate moves the journal to its first rotated file and opens a new one.  If that fails, the journal keeps
+// appending to the file it has open.
+func (j *Journal) rotate() error {
+	if j.maxFiles > 0 {
+		for i := j.maxFiles - 1; i > 0; i-- {
+			if err := os.Rename(rotated(j.path, i), rotated(j.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// The journal is missing if opening its successor failed after moving it.
+		if err := os.Rename(j.path, rotated(j.path, 1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	file := j.file
+	if err := j.open(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Append appends the record to the journal, first rotating it if it would exceed the maximum size.  The record is
+// appended even if rotating fails, and the failure returned.
+func (j *Journal) Append(record Record) error {
+	buff, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	buff = append(buff, '\n')
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	var rotateErr error
+	if j.maxSize > 0 && j.size > 0 && j.size+int64(len(buff)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			rotateErr = fmt.Errorf("Failed to rotate audit journal: %s", err)
+		}
+	}
+	n, err := j.file.Write(buff)
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// Close closes the journal.
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.file.Close()
+}
+
+// Read reads the records of the journal at the path and of its rotated journals, oldest first, that match the
+// filter.
+func Read(path string, maxFiles int, filter func(Record) bool) ([]Record, error) {
+	paths := []string{}
+	for i := maxFiles; i > 0; i-- {
+		paths = append(paths, rotated(path, i))
+	}
+	paths = append(paths, path)
+
+	records := []Record{}
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			record := Record{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("Invalid record at %s:%d: %s", p, line, err)
+			}
+			if filter == nil || filter(record) {
+				records = append(records, record)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
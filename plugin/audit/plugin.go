@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"github.com/docker/infrakit.aws/plugin/passthrough"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// CallPlugin is an instance plugin that can make the operations of a call with clients of the call, e.g. copied with
+// Call.Service, so that they are journaled with it.  Plugins wrapping others implement it by wrapping the plugin of
+// the call of the wrapped plugin, obtained with WithCall.
+type CallPlugin interface {
+	// WithCall returns the plugin making its operations with the clients of the call.
+	WithCall(call *Call) instance.Plugin
+}
+
+// WithCall returns the plugin making its operations with the clients of the call, or the plugin itself if it is not a
+// CallPlugin or the call is nil.
+func WithCall(plugin instance.Plugin, call *Call) instance.Plugin {
+	if callPlugin, is := plugin.(CallPlugin); is && call != nil {
+		return callPlugin.WithCall(call)
+	}
+	return plugin
+}
+
+// instancePlugin begins a call for each call of the mutating methods of an instance plugin, so that the operations
+// they make are journaled with it.
+type instancePlugin struct {
+	passthrough.Plugin
+	auditor *Auditor
+}
+
+// InstancePlugin returns the instance plugin tracking its calls, or the plugin itself if the auditor is nil.
+func (a *Auditor) InstancePlugin(plugin instance.Plugin) instance.Plugin {
+	if a == nil {
+		return plugin
+	}
+	return &instancePlugin{Plugin: passthrough.Plugin{Plugin: plugin}, auditor: a}
+}
+
+// Provision provisions an instance.
+func (p *instancePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	return WithCall(p.Wrapped(), p.auditor.Begin("Provision", "", spec.Tags)).Provision(spec)
+}
+
+// Label labels the instance.
+func (p *instancePlugin) Label(id instance.ID, labels map[string]string) error {
+	return WithCall(p.Wrapped(), p.auditor.Begin("Label", string(id), labels)).Label(id, labels)
+}
+
+// Destroy destroys the instance.
+func (p *instancePlugin) Destroy(id instance.ID) error {
+	return WithCall(p.Wrapped(), p.auditor.Begin("Destroy", string(id), nil)).Destroy(id)
+}
@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// ConfigEnvPrefix is the prefix of the environment variables setting plugin options.  The option of the flag
+	// --some-flag is set by the variable INFRAKIT_AWS_SOME_FLAG.
+	ConfigEnvPrefix = "INFRAKIT_AWS_"
+
+	// ConfigFlag is the name of the flag locating the config file.  It can also be set by INFRAKIT_AWS_CONFIG.
+	ConfigFlag = "config"
+
+	// PrintConfigFlag is the name of the flag that prints the effective config.
+	PrintConfigFlag = "print-config"
+)
+
+// Sources of options.
+const (
+	sourceDefault = "default"
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+)
+
+// Config is the effective config of a plugin, i.e. the values of its flags, set from the command line, the
+// environment or a config file, in that order of precedence, or defaulted.
+type Config struct {
+	flags   *pflag.FlagSet
+	sources map[string]string
+}
+
+// ConfigFlags returns the flags locating the config file and printing the effective config.
+func ConfigFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("config", pflag.PanicOnError)
+	flags.String(ConfigFlag, "", "Config file (YAML or JSON) of options by flag name")
+	flags.Bool(PrintConfigFlag, false, "Print the effective config and exit")
+	return flags
+}
+
+// LoadConfig sets the flags that were not set on the command line from the environment, or from the config file.
+func LoadConfig(flags *pflag.FlagSet) (*Config, error) {
+	config := &Config{flags: flags, sources: map[string]string{}}
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		config.sources[flag.Name] = sourceDefault
+		if flag.Changed {
+			config.sources[flag.Name] = sourceFlag
+		}
+	})
+
+	if err := config.setFromEnv(flags.Lookup(ConfigFlag)); err != nil {
+		return nil, err
+	}
+
+	file := map[string]interface{}{}
+	if flag := flags.Lookup(ConfigFlag); flag != nil && flag.Value.String() != "" {
+		path := flag.Value.String()
+		buff, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read config file: %s", err)
+		}
+		if err := yaml.Unmarshal(buff, &file); err != nil {
+			return nil, fmt.Errorf("Invalid config file %s: %s", path, err)
+		}
+	}
+
+	for name := range file {
+		if flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("Unknown option in config file: %s", name)
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Name == ConfigFlag {
+			return
+		}
+		if err = config.setFromEnv(flag); err != nil || config.sources[flag.Name] != sourceDefault {
+			return
+		}
+		value, has := file[flag.Name]
+		if !has {
+			return
+		}
+		if err = flags.Set(flag.Name, configValue(value)); err != nil {
+			err = fmt.Errorf("Invalid value of %s in config file: %s", flag.Name, err)
+			return
+		}
+		config.sources[flag.Name] = sourceFile
+	})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// EnvName returns the name of the environment variable setting the option of the flag.
+func EnvName(flag string) string {
+	return ConfigEnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+func (c *Config) setFromEnv(flag *pflag.Flag) error {
+	if flag == nil || c.sources[flag.Name] != sourceDefault {
+		return nil
+	}
+	value, has := os.LookupEnv(EnvName(flag.Name))
+	if !has {
+		return nil
+	}
+	if err := c.flags.Set(flag.Name, value); err != nil {
+		return fmt.Errorf("Invalid value of %s: %s", EnvName(flag.Name), err)
+	}
+	c.sources[flag.Name] = sourceEnv
+	return nil
+}
+
+// configValue returns the config file value as a flag value.  Lists are comma-separated, and maps are
+// comma-separated key=value pairs, as used by namespace tags.
+func configValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		values := []string{}
+		for _, v := range value {
+			values = append(values, configValue(v))
+		}
+		return strings.Join(values, ",")
+	case map[string]interface{}:
+		values := []string{}
+		for k, v := range value {
+			values = append(values, k+"="+configValue(v))
+		}
+		sort.Strings(values)
+		return strings.Join(values, ",")
+	}
+	buff, _ := json.Marshal(value)
+	return string(buff)
+}
+
+// Print prints the effective config if requested by the PrintConfigFlag, and returns true if it was.  Values of
+// secrets are masked.
+func (c *Config) Print(w io.Writer) bool {
+	if flag := c.flags.Lookup(PrintConfigFlag); flag == nil || flag.Value.String() != "true" {
+		return false
+	}
+
+	c.flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == PrintConfigFlag {
+			return
+		}
+		value := flag.Value.String()
+		if value != "" && (strings.Contains(flag.Name, "secret") || strings.Contains(flag.Name, "token")) {
+			value = "********"
+		}
+		fmt.Fprintf(w, "%s: %s  # %s\n", flag.Name, value, c.sources[flag.Name])
+	})
+	return true
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func testFlags() (*pflag.FlagSet, *string, *int, *[]string) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	region := flags.String("region", "", "")
+	retries := flags.Int("retries", 5, "")
+	tags := flags.StringSlice("namespace-tags", []string{}, "")
+	flags.String("secret-access-key", "", "")
+	flags.AddFlagSet(ConfigFlags())
+	return flags, region, retries, tags
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+region: us-west-1
+retries: 3
+namespace-tags:
+  cluster: test
+  owner: ops
+secret-access-key: hush
+`), 0644))
+
+	os.Setenv("INFRAKIT_AWS_CONFIG", path)
+	os.Setenv("INFRAKIT_AWS_RETRIES", "7")
+	defer os.Unsetenv("INFRAKIT_AWS_CONFIG")
+	defer os.Unsetenv("INFRAKIT_AWS_RETRIES")
+
+	// Flags take precedence over the environment, which takes precedence over the config file.
+	flags, region, retries, tags := testFlags()
+	require.NoError(t, flags.Parse([]string{"--region", "eu-west-1", "--print-config"}))
+
+	config, err := LoadConfig(flags)
+	require.NoError(t, err)
+	require.Equal(t, "eu-west-1", *region)
+	require.Equal(t, 7, *retries)
+	require.Equal(t, []string{"cluster=test", "owner=ops"}, *tags)
+
+	buff := bytes.Buffer{}
+	require.True(t, config.Print(&buff))
+	require.Equal(t, `config: `+path+`  # env
+namespace-tags: [cluster=test,owner=ops]  # file
+region: eu-west-1  # flag
+retries: 7  # env
+secret-access-key: ********  # file
+`, buff.String())
+
+	// Unknown options are an error.
+	require.NoError(t, ioutil.WriteFile(path, []byte(`regoin: us-west-1`), 0644))
+	flags, _, _, _ = testFlags()
+	require.NoError(t, flags.Parse([]string{}))
+	_, err = LoadConfig(flags)
+	require.Error(t, err)
+}
@@ -3,10 +3,13 @@ package instance
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -26,14 +29,24 @@ type createAutoScalingGroupRequest struct {
 	PutLifecycleHookInputs      []autoscaling.PutLifecycleHookInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsAutoScalingGroupPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(autoscalingiface.AutoScalingAPI)
+	return p
+}
+
 func (p awsAutoScalingGroupPlugin) Validate(req *types.Any) error {
+	request := createAutoScalingGroupRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsAutoScalingGroupPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createAutoScalingGroupRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	name := newUnrestrictedName(spec.Tags, p.namespaceTags)
@@ -41,7 +54,7 @@ func (p awsAutoScalingGroupPlugin) Provision(spec instance.Spec) (*instance.ID,
 	request.CreateAutoScalingGroupInput.AutoScalingGroupName = aws.String(name)
 	_, err := p.client.CreateAutoScalingGroup(&request.CreateAutoScalingGroupInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateAutoScalingGroup failed: %s", err)
+		return nil, apiError("CreateAutoScalingGroup", err)
 	}
 	id := instance.ID(name)
 
@@ -49,13 +62,18 @@ func (p awsAutoScalingGroupPlugin) Provision(spec instance.Spec) (*instance.ID,
 		input.AutoScalingGroupName = aws.String(name)
 		input.LifecycleHookName = aws.String(fmt.Sprintf("%s_hook_%d", newQueueName(spec.Tags, p.namespaceTags), i))
 		if _, err := p.client.PutLifecycleHook(&input); err != nil {
-			return nil, fmt.Errorf("PutLifecycleHook failed: %s", err)
+			return nil, apiError("PutLifecycleHook", err)
 		}
 	}
 
 	return &id, nil
 }
 
+// inNamespace returns true if the name of the auto scaling group has the values of the namespace tags.
+func (p awsAutoScalingGroupPlugin) inNamespace(id instance.ID) (bool, error) {
+	return namedInNamespace(string(id), newUnrestrictedName, p.namespaceTags), nil
+}
+
 func (p awsAutoScalingGroupPlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
@@ -66,11 +84,22 @@ func (p awsAutoScalingGroupPlugin) Destroy(id instance.ID) error {
 		ForceDelete:          aws.Bool(true),
 	})
 	if err != nil {
-		return fmt.Errorf("DeleteAutoScalingGroup failed: %s", err)
+		return autoscalingDeleteError("DeleteAutoScalingGroup", err)
 	}
 	return nil
 }
 
+// autoscalingDeleteError returns the error of the failed delete operation of Auto Scaling, which reports missing
+// resources with a validation error, classified as not found.
+func autoscalingDeleteError(operation string, err error) *Error {
+	wrapped := apiError(operation, err)
+	if awsErr, is := err.(awserr.Error); is && awsErr.Code() == "ValidationError" &&
+		strings.Contains(awsErr.Message(), "not found") {
+		wrapped.Class = ErrorClassNotFound
+	}
+	return wrapped
+}
+
 func (p awsAutoScalingGroupPlugin) DescribeInstances(tags map[string]string, properties bool) ([]instance.Description, error) {
 	name := newUnrestrictedName(tags, p.namespaceTags)
 
@@ -78,7 +107,7 @@ func (p awsAutoScalingGroupPlugin) DescribeInstances(tags map[string]string, pro
 		AutoScalingGroupNames: []*string{&name},
 	})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeAutoScalingGroups failed: %s", err)
+		return []instance.Description{}, apiError("DescribeAutoScalingGroups", err)
 	}
 
 	descriptions := []instance.Description{}
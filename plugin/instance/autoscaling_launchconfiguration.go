@@ -3,12 +3,12 @@ package instance
 import (
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -27,14 +27,24 @@ type createLaunchConfigurationRequest struct {
 	CreateLaunchConfigurationInput autoscaling.CreateLaunchConfigurationInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsLaunchConfigurationPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(autoscalingiface.AutoScalingAPI)
+	return p
+}
+
 func (p awsLaunchConfigurationPlugin) Validate(req *types.Any) error {
+	request := createLaunchConfigurationRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsLaunchConfigurationPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createLaunchConfigurationRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	if userData := request.CreateLaunchConfigurationInput.UserData; userData != nil {
@@ -51,13 +61,18 @@ func (p awsLaunchConfigurationPlugin) Provision(spec instance.Spec) (*instance.I
 		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("CreateLaunchConfiguration failed: %s", err)
+		return nil, apiError("CreateLaunchConfiguration", err)
 	}
 	id := instance.ID(name)
 
 	return &id, nil
 }
 
+// inNamespace returns true if the name of the launch configuration has the values of the namespace tags.
+func (p awsLaunchConfigurationPlugin) inNamespace(id instance.ID) (bool, error) {
+	return namedInNamespace(string(id), newUnrestrictedName, p.namespaceTags), nil
+}
+
 func (p awsLaunchConfigurationPlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
@@ -67,7 +82,7 @@ func (p awsLaunchConfigurationPlugin) Destroy(id instance.ID) error {
 		LaunchConfigurationName: (*string)(&id),
 	})
 	if err != nil {
-		return fmt.Errorf("DeleteLaunchConfiguration failed: %s", err)
+		return autoscalingDeleteError("DeleteLaunchConfiguration", err)
 	}
 	return nil
 }
@@ -79,7 +94,7 @@ func (p awsLaunchConfigurationPlugin) DescribeInstances(tags map[string]string,
 		LaunchConfigurationNames: []*string{&name},
 	})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeLaunchConfigurations failed: %s", err)
+		return []instance.Description{}, apiError("DescribeLaunchConfigurations", err)
 	}
 
 	descriptions := []instance.Description{}
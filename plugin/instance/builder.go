@@ -1,85 +1,154 @@
 package instance
 
 import (
-	"errors"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
-	"github.com/aws/aws-sdk-go/aws/ec2metadata"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/spf13/pflag"
 	"log"
 	"os"
+	"sync"
 )
 
 type options struct {
-	region          string
-	accessKeyID     string
-	secretAccessKey string
-	sessionToken    string
-	retries         int
+	session SessionOptions
+	regions []string
+	roles   []string
+	plugin  InstancePluginOptions
 }
 
 // Builder is a ProvisionerBuilder that creates an AWS instance provisioner.
 type Builder struct {
 	Config  client.ConfigProvider
 	options options
+
+	// Auditor journals the operations of the instance plugin, if not nil.
+	Auditor *audit.Auditor
+
+	lock     sync.Mutex
+	sessions map[Scope]client.ConfigProvider
 }
 
 // Flags returns the flags required.
 func (b *Builder) Flags() *pflag.FlagSet {
-	flags := pflag.NewFlagSet("aws", pflag.PanicOnError)
-	flags.StringVar(&b.options.region, "region", "", "AWS region")
-	flags.StringVar(&b.options.accessKeyID, "access-key-id", "", "IAM access key ID")
-	flags.StringVar(&b.options.secretAccessKey, "secret-access-key", "", "IAM access key secret")
-	flags.StringVar(&b.options.sessionToken, "session-token", "", "AWS STS token")
-	flags.IntVar(&b.options.retries, "retries", 5, "Number of retries for AWS API operations")
+	flags := b.options.session.Flags()
+	flags.StringSliceVar(&b.options.regions, "regions", []string{},
+		"Additional AWS regions to create ec2-instances in, as set by the Region property")
+	flags.StringSliceVar(&b.options.roles, "roles", []string{},
+		"Additional IAM roles, as '<role ARN>[ <external ID>]', to create ec2-instances with, as set by the Role property")
+	flags.IntVar(&b.options.plugin.CompletionWorkers, "completion-workers",
+		DefaultInstancePluginOptions.CompletionWorkers,
+		"Number of instances to complete provisioning in the background at a time, 0 to complete before returning")
+	flags.DurationVar(&b.options.plugin.CompletionTimeout, "completion-timeout",
+		DefaultInstancePluginOptions.CompletionTimeout,
+		"Timeout waiting for an instance to run when completing provisioning")
+	flags.DurationVar(&b.options.plugin.CapacityFailureTTL, "capacity-failure-ttl",
+		DefaultInstancePluginOptions.CapacityFailureTTL,
+		"How long an instance type and placement without capacity is tried last")
+	flags.BoolVar(&b.options.plugin.CheckTerminationProtection, "check-termination-protection", false,
+		"Refuse to destroy ec2-instances with termination protection, also when stopping them")
+	flags.BoolVar(&b.options.session.DryRun, "dry-run", false,
+		"Simulate the resources of all plugins, only checking the permissions of operations")
 	return flags
 }
 
+// DryRun returns true if the plugins must simulate their resources, with NewDryRunPlugin.
+func (b *Builder) DryRun() bool {
+	return b.options.session.DryRun
+}
+
 // BuildInstancePlugin creates an instance Provisioner configured with the Flags.
 func (b *Builder) BuildInstancePlugin(namespaceTags map[string]string) (instance.Plugin, error) {
 	if b.Config == nil {
-		providers := []credentials.Provider{
-			&ec2rolecreds.EC2RoleProvider{Client: ec2metadata.New(session.New())},
-			&credentials.EnvProvider{},
-			&credentials.SharedCredentialsProvider{},
+		if err := b.options.session.ResolveRegion(); err != nil {
+			return nil, err
 		}
 
-		if (len(b.options.accessKeyID) > 0 && len(b.options.secretAccessKey) > 0) || len(b.options.sessionToken) > 0 {
-			staticCreds := credentials.StaticProvider{
-				Value: credentials.Value{
-					AccessKeyID:     b.options.accessKeyID,
-					SecretAccessKey: b.options.secretAccessKey,
-					SessionToken:    b.options.sessionToken,
-				},
-			}
-			providers = append(providers, &staticCreds)
+		config, err := b.Session(Scope{})
+		if err != nil {
+			return nil, err
 		}
+		b.Config = config
+	}
 
-		if b.options.region == "" {
-			log.Println("region not specified, attempting to discover from EC2 instance metadata")
-			region, err := GetRegion()
-			if err != nil {
-				return nil, errors.New("Unable to determine region")
-			}
+	defaultScope := Scope{Region: b.options.session.Region, Role: b.options.session.Role}
+
+	options := b.options.plugin
+	options.Role = defaultScope.Role.ARN
+	options.Region = defaultScope.Region
+	options.ELB = elb.New(b.Auditor.Client(b.Config))
 
-			log.Printf("Defaulting to local region %s\n", region)
-			b.options.region = region
+	if len(b.options.regions) == 0 && len(b.options.roles) == 0 {
+		return buildInstancePlugin(ec2.New(b.Auditor.Client(b.Config)), namespaceTags, options), nil
+	}
+
+	roles := []Role{}
+	for _, s := range b.options.roles {
+		role, err := ParseRole(s)
+		if err != nil {
+			return nil, err
 		}
+		roles = append(roles, role)
+	}
 
-		b.Config = session.New(aws.NewConfig().
-			WithRegion(b.options.region).
-			WithCredentials(credentials.NewChainCredentials(providers)).
-			WithLogger(GetLogger()).
-			//WithLogLevel(aws.LogDebugWithRequestErrors).
-			WithMaxRetries(b.options.retries))
+	return NewMultiScopeInstancePlugin(defaultScope, b.options.regions, roles,
+		func(scope Scope) (instance.Plugin, error) {
+			config := b.Config
+			if scope != defaultScope {
+				var err error
+				if config, err = b.Session(scope); err != nil {
+					return nil, err
+				}
+			}
+			options := b.options.plugin
+			options.Role = scope.Role.ARN
+			options.Region = scope.Region
+			options.ELB = elb.New(b.Auditor.Client(config))
+			return buildInstancePlugin(ec2.New(b.Auditor.Client(config)), namespaceTags, options), nil
+		})
+}
+
+// buildInstancePlugin creates the plugin of the client, failing the provisions its previous run didn't complete.
+func buildInstancePlugin(
+	client ec2iface.EC2API,
+	namespaceTags map[string]string,
+	options InstancePluginOptions) instance.Plugin {
+
+	plugin := NewInstancePluginWithOptions(client, namespaceTags, options)
+	plugin.(*awsInstancePlugin).failInterruptedProvisions()
+	return plugin
+}
+
+// Session returns the session of the scope, configured with the Flags.  The region and role of the scope default to
+// those of the Flags.  Sessions are cached by scope.
+func (b *Builder) Session(scope Scope) (client.ConfigProvider, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.sessions == nil {
+		b.sessions = map[Scope]client.ConfigProvider{}
+	}
+	if sess, has := b.sessions[scope]; has {
+		return sess, nil
 	}
 
-	return NewInstancePlugin(ec2.New(b.Config), namespaceTags), nil
+	options := b.options.session
+	if scope.Region != "" {
+		options.Region = scope.Region
+	}
+	if scope.Role.ARN != "" {
+		options.Role = scope.Role
+	}
+	sess, err := NewSession(options)
+	if err != nil {
+		return nil, err
+	}
+	b.sessions[scope] = sess
+	return sess, nil
 }
 
 type logger struct {
@@ -0,0 +1,254 @@
+package instance
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+const (
+	// InstanceTypeTag is the AWS tag name recording the instance type chosen from the candidate InstanceTypes.
+	InstanceTypeTag = "infrakit.instance-type"
+
+	// PlacementTag is the AWS tag name recording the placement chosen from the candidate Placements.
+	PlacementTag = "infrakit.placement"
+)
+
+// capacityErrorCodes are the codes of RunInstances errors that another instance type or placement may not hit.
+var capacityErrorCodes = map[string]bool{
+	"InsufficientInstanceCapacity": true,
+	"Unsupported":                  true,
+	"InstanceLimitExceeded":        true,
+}
+
+// Placement is a candidate placement of an instance.
+type Placement struct {
+	// AvailabilityZone is the availability zone to place the instance in.
+	AvailabilityZone string
+
+	// SubnetID is the subnet to place the instance in.
+	SubnetID string
+}
+
+func (p Placement) String() string {
+	switch {
+	case p.SubnetID != "" && p.AvailabilityZone != "":
+		return p.AvailabilityZone + "/" + p.SubnetID
+	case p.SubnetID != "":
+		return p.SubnetID
+	}
+	return p.AvailabilityZone
+}
+
+// candidate is a combination of instance type and placement to run an instance with.
+type candidate struct {
+	instanceType string
+	placement    *Placement
+}
+
+func (c candidate) String() string {
+	if c.placement == nil {
+		return c.instanceType
+	}
+	return c.instanceType + "@" + c.placement.String()
+}
+
+// candidates returns the combinations of the candidate instance types and placements of the request, in order of
+// preference: all placements of the first instance type, then those of the second, and so on.
+func (r CreateInstanceRequest) candidates() []candidate {
+	instanceTypes := r.InstanceTypes
+	if len(instanceTypes) == 0 {
+		instanceTypes = []string{aws.StringValue(r.RunInstancesInput.InstanceType)}
+	}
+
+	candidates := []candidate{}
+	for _, instanceType := range instanceTypes {
+		if len(r.Placements) == 0 {
+			candidates = append(candidates, candidate{instanceType: instanceType})
+			continue
+		}
+		for i := range r.Placements {
+			candidates = append(candidates, candidate{instanceType: instanceType, placement: &r.Placements[i]})
+		}
+	}
+	return candidates
+}
+
+// apply returns the request updated to run the instance with the candidate.
+func (c candidate) apply(request CreateInstanceRequest) CreateInstanceRequest {
+	run := request.RunInstancesInput
+	if c.instanceType != "" {
+		run.InstanceType = aws.String(c.instanceType)
+	}
+
+	if c.placement != nil {
+		if c.placement.AvailabilityZone != "" {
+			placement := ec2.Placement{}
+			if run.Placement != nil {
+				placement = *run.Placement
+			}
+			placement.AvailabilityZone = aws.String(c.placement.AvailabilityZone)
+			run.Placement = &placement
+		}
+		if c.placement.SubnetID != "" {
+			if len(run.NetworkInterfaces) > 0 {
+				networkInterface := *run.NetworkInterfaces[0]
+				networkInterface.SubnetId = aws.String(c.placement.SubnetID)
+				run.NetworkInterfaces = append([]*ec2.InstanceNetworkInterfaceSpecification{&networkInterface},
+					run.NetworkInterfaces[1:]...)
+			} else {
+				run.SubnetId = aws.String(c.placement.SubnetID)
+			}
+		}
+	}
+
+	request.RunInstancesInput = run
+	return request
+}
+
+// hasInstanceType returns true if the instance type is one the request may run an instance with.
+func (r CreateInstanceRequest) hasInstanceType(instanceType string) bool {
+	if len(r.InstanceTypes) == 0 {
+		return r.RunInstancesInput.InstanceType == nil || *r.RunInstancesInput.InstanceType == instanceType
+	}
+	for _, candidate := range r.InstanceTypes {
+		if candidate == instanceType {
+			return true
+		}
+	}
+	return false
+}
+
+// capacityFailures remembers the candidates that recently failed for lack of capacity.
+type capacityFailures struct {
+	ttl    time.Duration
+	lock   sync.Mutex
+	failed map[string]time.Time
+}
+
+func newCapacityFailures(ttl time.Duration) *capacityFailures {
+	return &capacityFailures{ttl: ttl, failed: map[string]time.Time{}}
+}
+
+func (f *capacityFailures) record(c candidate) {
+	if f == nil {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failed[c.String()] = time.Now()
+}
+
+func (f *capacityFailures) recentlyFailed(c candidate) bool {
+	if f == nil {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	at, has := f.failed[c.String()]
+	if has && time.Since(at) > f.ttl {
+		delete(f.failed, c.String())
+		return false
+	}
+	return has
+}
+
+func (r CreateInstanceRequest) validateCandidates() error {
+	if len(r.Subnets) > 0 && len(r.Placements) > 0 {
+		return newError(ErrorClassInvalidSpec, "Only one of Subnets and Placements can be set")
+	}
+	if len(r.Subnets) > 0 && r.LogicalIDStrategy == LogicalIDNetworkInterface {
+		return newError(ErrorClassInvalidSpec, "Subnets cannot be used with the %s logical ID strategy",
+			LogicalIDNetworkInterface)
+	}
+	for _, placement := range r.Placements {
+		if placement.AvailabilityZone == "" && placement.SubnetID == "" {
+			return newError(ErrorClassInvalidSpec, "Placements must have an AvailabilityZone or SubnetID")
+		}
+		if placement.SubnetID != "" && r.LogicalIDStrategy == LogicalIDNetworkInterface {
+			return newError(ErrorClassInvalidSpec, "Placements in subnets cannot be used with the %s logical ID strategy",
+				LogicalIDNetworkInterface)
+		}
+	}
+	return nil
+}
+
+func isCapacityError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return capacityErrorCodes[awsErr.Code()]
+	}
+	return false
+}
+
+// runInstance runs the instance with the first candidate instance type and placement that has capacity.  Candidates
+// that recently failed for lack of capacity are tried last.  Returns the request as run.
+func (p awsInstancePlugin) runInstance(
+	spec instance.Spec,
+	request CreateInstanceRequest,
+	attachments []ebsAttachment) (*ec2.Reservation, CreateInstanceRequest, error) {
+
+	candidates := request.candidates()
+
+	ordered := []candidate{}
+	failed := []candidate{}
+	for _, c := range candidates {
+		if p.capacityFailures.recentlyFailed(c) {
+			failed = append(failed, c)
+		} else {
+			ordered = append(ordered, c)
+		}
+	}
+	ordered = append(ordered, failed...)
+
+	var lastErr error
+	for _, c := range ordered {
+		run := c.apply(request)
+
+		userData, err := p.buildUserData(spec, run, attachments, nil)
+		if err != nil {
+			return nil, run, err
+		}
+		run.RunInstancesInput.UserData = nil
+		if userData != nil {
+			run.RunInstancesInput.UserData = aws.String(base64.StdEncoding.EncodeToString(userData))
+		}
+
+		reservation, err := p.client.RunInstances(&run.RunInstancesInput)
+		if err == nil || !isCapacityError(err) || len(candidates) == 1 {
+			return reservation, run, err
+		}
+
+		log.Warnf("No capacity for %s, trying the next candidate: %s", c, err)
+		p.capacityFailures.record(c)
+		lastErr = err
+	}
+	return nil, request, wrapError(lastErr, "No candidate instance type and placement has capacity: %s", lastErr)
+}
+
+// candidateTags returns the tags recording the instance type and placement the instance was run with, if chosen
+// from candidates.
+func (r CreateInstanceRequest) candidateTags() map[string]string {
+	tags := map[string]string{}
+	if len(r.InstanceTypes) > 0 {
+		tags[InstanceTypeTag] = aws.StringValue(r.RunInstancesInput.InstanceType)
+	}
+	if len(r.Placements) > 0 {
+		placement := Placement{}
+		if r.RunInstancesInput.Placement != nil {
+			placement.AvailabilityZone = aws.StringValue(r.RunInstancesInput.Placement.AvailabilityZone)
+		}
+		if len(r.RunInstancesInput.NetworkInterfaces) > 0 && r.RunInstancesInput.NetworkInterfaces[0].SubnetId != nil {
+			placement.SubnetID = aws.StringValue(r.RunInstancesInput.NetworkInterfaces[0].SubnetId)
+		} else {
+			placement.SubnetID = aws.StringValue(r.RunInstancesInput.SubnetId)
+		}
+		tags[PlacementTag] = placement.String()
+	}
+	return tags
+}
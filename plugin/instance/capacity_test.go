@@ -0,0 +1,96 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionFallsBackOnCapacityErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	instanceID := "test-id"
+	reservation := &ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}
+	noCapacity := awserr.New("InsufficientInstanceCapacity", "no capacity", nil)
+
+	tried := []string{}
+	runInstances := func(input *ec2.RunInstancesInput) {
+		tried = append(tried, *input.InstanceType+"@"+*input.Placement.AvailabilityZone)
+	}
+	var chosen map[string]string
+	createTags := func(input *ec2.CreateTagsInput) {
+		chosen = tagMap(input)
+	}
+
+	gomock.InOrder(
+		clientMock.EXPECT().RunInstances(gomock.Any()).Do(runInstances).Return(nil, noCapacity),
+		clientMock.EXPECT().RunInstances(gomock.Any()).Do(runInstances).Return(reservation, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).Do(createTags).Return(&ec2.CreateTagsOutput{}, nil),
+	)
+
+	pluginImpl := NewInstancePlugin(clientMock, testNamespace)
+	spec := instance.Spec{
+		Properties: types.AnyString(`{
+			"InstanceTypes": ["m5.large", "m4.large"],
+			"Placements": [{"AvailabilityZone": "us-west-2a"}, {"AvailabilityZone": "us-west-2b"}]
+		}`),
+		Tags: tags,
+	}
+	_, err := pluginImpl.Provision(spec)
+	require.NoError(t, err)
+	require.Equal(t, []string{"m5.large@us-west-2a", "m5.large@us-west-2b"}, tried)
+	require.Equal(t, "m5.large", chosen[InstanceTypeTag])
+	require.Equal(t, "us-west-2b", chosen[PlacementTag])
+
+	// The candidate that recently failed is tried last.
+	tried = []string{}
+	gomock.InOrder(
+		clientMock.EXPECT().RunInstances(gomock.Any()).Do(runInstances).Return(nil, noCapacity),
+		clientMock.EXPECT().RunInstances(gomock.Any()).Do(runInstances).Return(reservation, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).Do(createTags).Return(&ec2.CreateTagsOutput{}, nil),
+	)
+	_, err = pluginImpl.Provision(spec)
+	require.NoError(t, err)
+	require.Equal(t, []string{"m5.large@us-west-2b", "m4.large@us-west-2a"}, tried)
+	require.Equal(t, "m4.large", chosen[InstanceTypeTag])
+	require.Equal(t, "us-west-2a", chosen[PlacementTag])
+
+	// Other errors are not retried.
+	tried = []string{}
+	clientMock.EXPECT().RunInstances(gomock.Any()).Do(runInstances).
+		Return(nil, awserr.New("InvalidAMIID.NotFound", "no image", nil))
+	_, err = pluginImpl.Provision(spec)
+	require.Error(t, err)
+	require.Equal(t, []string{"m4.large@us-west-2a"}, tried)
+}
+
+func TestCandidatePlacementInSubnet(t *testing.T) {
+	request := CreateInstanceRequest{
+		RunInstancesInput: ec2.RunInstancesInput{
+			InstanceType: aws.String("t2.micro"),
+			NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{
+				{DeviceIndex: aws.Int64(0), SubnetId: aws.String("subnet-1")},
+			},
+		},
+		Placements: []Placement{{SubnetID: "subnet-2"}},
+	}
+	candidates := request.candidates()
+	require.Len(t, candidates, 1)
+
+	applied := candidates[0].apply(request)
+	require.Equal(t, "subnet-2", *applied.RunInstancesInput.NetworkInterfaces[0].SubnetId)
+	require.Equal(t, "subnet-1", *request.RunInstancesInput.NetworkInterfaces[0].SubnetId)
+	require.Equal(t, map[string]string{PlacementTag: "subnet-2"}, applied.candidateTags())
+
+	request.LogicalIDStrategy = LogicalIDNetworkInterface
+	require.Error(t, request.validate())
+}
@@ -2,11 +2,11 @@ package instance
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -26,41 +26,56 @@ type createLogGroupRequest struct {
 	PutRetentionPolicyInput *cloudwatchlogs.PutRetentionPolicyInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsLogGroupPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(cloudwatchlogsiface.CloudWatchLogsAPI)
+	return p
+}
+
 func (p awsLogGroupPlugin) Validate(req *types.Any) error {
+	request := createLogGroupRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsLogGroupPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createLogGroupRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	name := newQueueName(spec.Tags, p.namespaceTags)
 
 	request.CreateLogGroupInput.LogGroupName = aws.String(name)
 	if _, err := p.client.CreateLogGroup(&request.CreateLogGroupInput); err != nil {
-		return nil, fmt.Errorf("CreateLogGroup failed: %s", err)
+		return nil, apiError("CreateLogGroup", err)
 	}
 	id := instance.ID(name)
 
 	if request.PutRetentionPolicyInput != nil {
 		request.PutRetentionPolicyInput.LogGroupName = request.CreateLogGroupInput.LogGroupName
 		if _, err := p.client.PutRetentionPolicy(request.PutRetentionPolicyInput); err != nil {
-			return &id, fmt.Errorf("PutRetentionPolicy failed: %s", err)
+			return &id, apiError("PutRetentionPolicy", err)
 		}
 	}
 
 	return &id, nil
 }
 
+// inNamespace returns true if the name of the log group has the values of the namespace tags.
+func (p awsLogGroupPlugin) inNamespace(id instance.ID) (bool, error) {
+	return namedInNamespace(string(id), newQueueName, p.namespaceTags), nil
+}
+
 func (p awsLogGroupPlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
 
 func (p awsLogGroupPlugin) Destroy(id instance.ID) error {
 	if _, err := p.client.DeleteLogGroup(&cloudwatchlogs.DeleteLogGroupInput{LogGroupName: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteLogGroup failed: %s", err)
+		return apiError("DeleteLogGroup", err)
 	}
 	return nil
 }
@@ -70,7 +85,7 @@ func (p awsLogGroupPlugin) DescribeInstances(tags map[string]string, properties
 
 	output, err := p.client.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: &name})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("ListLogGroups failed: %s", err)
+		return []instance.Description{}, apiError("ListLogGroups", err)
 	}
 
 	descriptions := []instance.Description{}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"os"
-
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -14,7 +17,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/docker/infrakit.aws/plugin"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit.aws/plugin/instance"
+	"github.com/docker/infrakit.aws/plugin/metrics"
 	"github.com/docker/infrakit/pkg/cli"
 	event_rpc "github.com/docker/infrakit/pkg/rpc/event"
 	instance_rpc "github.com/docker/infrakit/pkg/rpc/instance"
@@ -26,15 +31,30 @@ import (
 func main() {
 
 	builder := &instance.Builder{}
+	auditOptions := audit.Options{}
 
 	var logLevel int
 	var name string
 	var namespaceTags []string
+	var resources []string
+	var metricsListen string
+	var overrideNamespace bool
+	var destroyLimit int
+	var destroyWindow time.Duration
 	cmd := &cobra.Command{
 		Use:   os.Args[0],
 		Short: "AWS instance plugin",
 		Run: func(c *cobra.Command, args []string) {
 
+			config, err := plugin.LoadConfig(c.Flags())
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+			if config.Print(os.Stdout) {
+				return
+			}
+
 			namespace := map[string]string{}
 			for _, tagKV := range namespaceTags {
 				keyAndValue := strings.Split(tagKV, "=")
@@ -46,48 +66,117 @@ func main() {
 				namespace[keyAndValue[0]] = keyAndValue[1]
 			}
 
+			journal, err := auditOptions.Open()
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+
+			// Each plugin has its own clients, so that the journal records the plugin making each operation.
+			auditors := map[string]*audit.Auditor{}
+			audited := func(resource string) client.ConfigProvider {
+				auditors[resource] = journal.Auditor(resource)
+				return auditors[resource].Client(builder.Config)
+			}
+
+			auditors["ec2-instance"] = journal.Auditor("ec2-instance")
+			builder.Auditor = auditors["ec2-instance"]
 			instancePlugin, err := builder.BuildInstancePlugin(namespace)
 			if err != nil {
 				log.Error(err)
 				os.Exit(1)
 			}
 
-			autoscalingClient := autoscaling.New(builder.Config)
-			cloudWatchLogsClient := cloudwatchlogs.New(builder.Config)
-			dynamodbClient := dynamodb.New(builder.Config)
-			ec2Client := ec2.New(builder.Config)
-			elbClient := elb.New(builder.Config)
-			iamClient := iam.New(builder.Config)
-			sqsClient := sqs.New(builder.Config)
+			instancePlugins := map[string]instance_spi.Plugin{
+				"autoscaling-autoscalinggroup": instance.NewAutoScalingGroupPlugin(
+					autoscaling.New(audited("autoscaling-autoscalinggroup")), namespace),
+				"autoscaling-launchconfiguration": instance.NewLaunchConfigurationPlugin(
+					autoscaling.New(audited("autoscaling-launchconfiguration")), namespace),
+				"cloudwatchlogs-loggroup": instance.NewLogGroupPlugin(
+					cloudwatchlogs.New(audited("cloudwatchlogs-loggroup")), namespace),
+				"dynamodb-table": instance.NewTablePlugin(
+					dynamodb.New(audited("dynamodb-table")), namespace),
+				"ec2-instance": instancePlugin,
+				"ec2-internetgateway": instance.NewInternetGatewayPlugin(
+					ec2.New(audited("ec2-internetgateway")), namespace),
+				"ec2-routetable": instance.NewRouteTablePlugin(
+					ec2.New(audited("ec2-routetable")), namespace),
+				"ec2-securitygroup": instance.NewSecurityGroupPlugin(
+					ec2.New(audited("ec2-securitygroup")), namespace),
+				"ec2-subnet": instance.NewSubnetPlugin(
+					ec2.New(audited("ec2-subnet")), namespace),
+				"ec2-volume": instance.NewVolumePlugin(
+					ec2.New(audited("ec2-volume")), namespace),
+				"ec2-vpc": instance.NewVpcPlugin(
+					ec2.New(audited("ec2-vpc")), namespace),
+				"elb-loadbalancer": instance.NewLoadBalancerPlugin(
+					elb.New(audited("elb-loadbalancer")), namespace),
+				"iam-instanceprofile": instance.NewInstanceProfilePlugin(
+					iam.New(audited("iam-instanceprofile")), namespace),
+				"iam-role": instance.NewRolePlugin(
+					iam.New(audited("iam-role")), namespace),
+				"sqs-queue": instance.NewQueuePlugin(
+					sqs.New(audited("sqs-queue")), namespace),
+			}
+			if overrideNamespace {
+				log.Warnln("Resources outside the namespace can be destroyed and labeled")
+			}
+			breaker := instance.NewDestroyBreaker(destroyLimit, destroyWindow)
+			resets := make(chan os.Signal, 1)
+			signal.Notify(resets, syscall.SIGHUP)
+			go func() {
+				for range resets {
+					breaker.Reset()
+				}
+			}()
+			for resource, plugin := range instancePlugins {
+				plugin = instance.NewSafetyPlugin(resource, plugin, breaker)
+				instancePlugins[resource] = instance.NewNamespaceGuardPlugin(plugin, overrideNamespace)
+			}
+			if builder.DryRun() {
+				log.Warnln("Dry run: resources are simulated")
+				for resource, plugin := range instancePlugins {
+					instancePlugins[resource] = instance.NewDryRunPlugin(resource, plugin)
+				}
+			}
+
+			eventPlugins := map[string]event.Plugin{
+				"ec2-instance": (&instance.Monitor{
+					Plugin:       instancePlugins["ec2-instance"],
+					DestroyHalts: breaker.DestroyHalts(),
+				}).Init(),
+			}
+
+			if len(resources) > 0 {
+				served := map[string]bool{}
+				for _, resource := range resources {
+					if _, has := instancePlugins[resource]; !has {
+						log.Errorf("Unknown resource type %s", resource)
+						os.Exit(1)
+					}
+					served[resource] = true
+				}
+				for resource := range instancePlugins {
+					if !served[resource] {
+						delete(instancePlugins, resource)
+						delete(eventPlugins, resource)
+					}
+				}
+			}
+
+			for resource, plugin := range instancePlugins {
+				plugin = auditors[resource].InstancePlugin(instance.NewClassifiedPlugin(plugin))
+				instancePlugins[resource] = metrics.InstancePlugin(resource, plugin)
+			}
 
 			cli.SetLogLevel(logLevel)
+			metrics.Serve(metricsListen)
 			cli.RunPlugin(name,
 				// As event plugin
-				event_rpc.PluginServerWithTypes(
-					map[string]event.Plugin{
-						"ec2-instance": (&instance.Monitor{
-							Plugin: instancePlugin,
-						}).Init(),
-					}),
+				event_rpc.PluginServerWithTypes(eventPlugins),
 
 				// instance plugins
-				instance_rpc.PluginServerWithTypes(map[string]instance_spi.Plugin{
-					"autoscaling-autoscalinggroup":    instance.NewAutoScalingGroupPlugin(autoscalingClient, namespace),
-					"autoscaling-launchconfiguration": instance.NewLaunchConfigurationPlugin(autoscalingClient, namespace),
-					"cloudwatchlogs-loggroup":         instance.NewLogGroupPlugin(cloudWatchLogsClient, namespace),
-					"dynamodb-table":                  instance.NewTablePlugin(dynamodbClient, namespace),
-					"ec2-instance":                    instancePlugin,
-					"ec2-internetgateway":             instance.NewInternetGatewayPlugin(ec2Client, namespace),
-					"ec2-routetable":                  instance.NewRouteTablePlugin(ec2Client, namespace),
-					"ec2-securitygroup":               instance.NewSecurityGroupPlugin(ec2Client, namespace),
-					"ec2-subnet":                      instance.NewSubnetPlugin(ec2Client, namespace),
-					"ec2-volume":                      instance.NewVolumePlugin(ec2Client, namespace),
-					"ec2-vpc":                         instance.NewVpcPlugin(ec2Client, namespace),
-					"elb-loadbalancer":                instance.NewLoadBalancerPlugin(elbClient, namespace),
-					"iam-instanceprofile":             instance.NewInstanceProfilePlugin(iamClient, namespace),
-					"iam-role":                        instance.NewRolePlugin(iamClient, namespace),
-					"sqs-queue":                       instance.NewQueuePlugin(sqsClient, namespace),
-				}))
+				instance_rpc.PluginServerWithTypes(instancePlugins))
 		},
 	}
 
@@ -99,11 +188,39 @@ func main() {
 		[]string{},
 		"A list of key=value resource tags to namespace all resources created")
 
-	// TODO(chungers) - the exposed flags here won't be set in plugins, because plugin install doesn't allow
-	// user to pass in command line args like containers with entrypoint.
+	cmd.Flags().StringSliceVar(
+		&resources,
+		"resources",
+		[]string{},
+		"A list of the resource types to serve, e.g. ec2-instance.  All types are served if empty")
+
+	cmd.Flags().BoolVar(
+		&overrideNamespace,
+		"override-namespace",
+		false,
+		"Destroy and label resources without the namespace tags, e.g. to clean up those of another namespace")
+
+	cmd.Flags().IntVar(
+		&destroyLimit,
+		"destroy-limit",
+		20,
+		"Number of destroys of a resource type in the destroy window that halts its destroys until SIGHUP, 0 for no limit")
+
+	cmd.Flags().DurationVar(
+		&destroyWindow,
+		"destroy-window",
+		time.Minute,
+		"Sliding window of the destroy limit")
+
+	// Plugins installed with plugin install can't be passed command line args, so all flags can also be set with
+	// INFRAKIT_AWS_* environment variables, or in a config file.
 	cmd.Flags().AddFlagSet(builder.Flags())
+	cmd.Flags().AddFlagSet(metrics.Flags(&metricsListen))
+	cmd.Flags().AddFlagSet(auditOptions.Flags())
+	cmd.Flags().AddFlagSet(plugin.ConfigFlags())
 
 	cmd.AddCommand(plugin.VersionCommand())
+	cmd.AddCommand(audit.Command())
 
 	err := cmd.Execute()
 	if err != nil {
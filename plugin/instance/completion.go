@@ -0,0 +1,234 @@
+package instance
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+const (
+	// ProvisionStatusTag is the AWS tag name recording the progress of the background completion of provisioning.
+	ProvisionStatusTag = "infrakit.provision-status"
+
+	// ProvisionErrorTag is the AWS tag name recording why the background completion of provisioning failed.
+	ProvisionErrorTag = "infrakit.provision-error"
+
+	// ProvisionStatusPending is the status of an instance whose provisioning is yet to be completed.
+	ProvisionStatusPending = "pending"
+
+	// ProvisionStatusComplete is the status of an instance whose provisioning completed.
+	ProvisionStatusComplete = "complete"
+
+	// ProvisionStatusFailed is the status of an instance whose provisioning failed to complete.  The instance is left
+	// running, and described, for inspection; it is up to the operator, or to whatever consumes the failures, to
+	// destroy it so that its group replaces it.
+	ProvisionStatusFailed = "failed"
+
+	// maxTagValueLength is the maximum length of the value of an AWS tag.
+	maxTagValueLength = 255
+)
+
+// runningPollInterval is the interval of polling for an instance to enter the running state.
+var runningPollInterval = 10 * time.Second
+
+// ProvisionError is the failure to complete provisioning an instance in the background.
+type ProvisionError struct {
+	ID  instance.ID
+	Err string
+}
+
+func (e ProvisionError) Error() string {
+	return fmt.Sprintf("Provisioning instance %s failed: %s", e.ID, e.Err)
+}
+
+// needsCompletion returns true if provisioning the instance has steps after it is launched.
+func needsCompletion(spec instance.Spec, request CreateInstanceRequest, attachments []ebsAttachment) bool {
+	return len(attachments) > 0 ||
+		len(request.AttachVolumeInputs) > 0 ||
+		len(request.LoadBalancerNames) > 0 ||
+		(spec.LogicalID != nil && request.LogicalIDStrategy == LogicalIDElasticIP)
+}
+
+// completeProvision performs the steps of provisioning after the instance is launched: it migrates, creates and
+// attaches volumes, associates the elastic IP address and registers the instance with its load balancers once the
+// instance is running.
+func (p awsInstancePlugin) completeProvision(
+	ec2Instance *ec2.Instance,
+	spec instance.Spec,
+	request CreateInstanceRequest,
+	attachments []ebsAttachment) error {
+
+	id := instance.ID(*ec2Instance.InstanceId)
+
+	zone := availabilityZone(ec2Instance, request)
+	if err := p.migrateEBSVolumes(attachments, zone, request.EBSAttachment); err != nil {
+		return err
+	}
+	if err := p.createMissingEBSVolumes(attachments, zone, request.EBSAttachment); err != nil {
+		return err
+	}
+
+	associateElasticIP := spec.LogicalID != nil && request.LogicalIDStrategy == LogicalIDElasticIP
+
+	if len(attachments) > 0 || associateElasticIP || len(request.LoadBalancerNames) > 0 {
+		timeout := p.options.CompletionTimeout
+		if timeout == 0 {
+			timeout = DefaultInstancePluginOptions.CompletionTimeout
+		}
+
+		log.Infof("Waiting for instance %s to enter running state", id)
+		if err := p.waitForRunning(ec2Instance.InstanceId, timeout); err != nil {
+			return err
+		}
+	}
+
+	if associateElasticIP {
+		if err := p.associateElasticIP(ec2Instance.InstanceId, *spec.LogicalID); err != nil {
+			return err
+		}
+	}
+
+	for _, attachment := range attachments {
+		_, err := p.client.AttachVolume(&ec2.AttachVolumeInput{
+			InstanceId: ec2Instance.InstanceId,
+			VolumeId:   attachment.Volume.VolumeId,
+			Device:     aws.String(attachment.Device),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(attachments) > 0 {
+		if err := ec2CreateTags(p.client, id, deviceTags(attachments)); err != nil {
+			return err
+		}
+	}
+
+	for _, attachVolumeInput := range request.AttachVolumeInputs {
+		attachVolumeInput.InstanceId = ec2Instance.InstanceId
+		err := retry(30*time.Second, 500*time.Millisecond, func() error {
+			_, err := p.client.AttachVolume(&attachVolumeInput)
+			return err
+		})
+		if err != nil {
+			return apiError("AttachVolume", err)
+		}
+	}
+
+	for _, name := range request.LoadBalancerNames {
+		_, err := p.options.ELB.RegisterInstancesWithLoadBalancer(&elb.RegisterInstancesWithLoadBalancerInput{
+			LoadBalancerName: aws.String(name),
+			Instances:        []*elb.Instance{{InstanceId: ec2Instance.InstanceId}},
+		})
+		if err != nil {
+			return apiError("RegisterInstancesWithLoadBalancer", err)
+		}
+		log.Infof("Registered instance %s with load balancer %s", id, name)
+	}
+
+	return nil
+}
+
+type completion struct {
+	id       instance.ID
+	client   ec2iface.EC2API
+	complete func() error
+}
+
+// completer completes provisioning instances in the background with a bounded number of workers, recording the
+// progress in the ProvisionStatusTag of the instances and reporting failures as ProvisionErrors.
+type completer struct {
+	queue  chan completion
+	errors chan ProvisionError
+}
+
+func newCompleter(workers int) *completer {
+	c := &completer{
+		queue:  make(chan completion, 1024),
+		errors: make(chan ProvisionError, 64),
+	}
+	for i := 0; i < workers; i++ {
+		go c.run()
+	}
+	return c
+}
+
+// schedule schedules the completion of the instance, recording its progress with the client.
+func (c *completer) schedule(id instance.ID, client ec2iface.EC2API, complete func() error) {
+	c.queue <- completion{id: id, client: client, complete: complete}
+}
+
+func (c *completer) run() {
+	for job := range c.queue {
+		log.Infof("Completing provisioning of instance %s", job.id)
+		c.finish(job.id, job.client, c.complete(job))
+	}
+}
+
+// finish records the completion of provisioning the instance with the client, reporting its failure if err isn't
+// nil.
+func (c *completer) finish(id instance.ID, client ec2iface.EC2API, err error) {
+	tags := map[string]string{ProvisionStatusTag: ProvisionStatusComplete}
+	if err != nil {
+		log.Warnf("Failed to complete provisioning of instance %s: %s", id, err)
+
+		message := err.Error()
+		if len(message) > maxTagValueLength {
+			message = message[:maxTagValueLength]
+		}
+		tags = map[string]string{ProvisionStatusTag: ProvisionStatusFailed, ProvisionErrorTag: message}
+
+		select {
+		case c.errors <- ProvisionError{ID: id, Err: err.Error()}:
+		default:
+			log.Warnf("Dropped provisioning failure of instance %s", id)
+		}
+	}
+
+	if err := ec2CreateTags(client, id, tags); err != nil {
+		log.Warnf("Failed to tag provisioning status of instance %s: %s", id, err)
+	}
+}
+
+// complete runs the job, failing it rather than the plugin if it panics.
+func (c *completer) complete(job completion) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Panic: %v", r)
+		}
+	}()
+	return job.complete()
+}
+
+// ProvisionErrors returns the failures to complete provisioning instances in the background.
+func (p awsInstancePlugin) ProvisionErrors() <-chan ProvisionError {
+	if p.completer == nil {
+		return nil
+	}
+	return p.completer.errors
+}
+
+// failInterruptedProvisions fails the completions of the instances of the namespace still pending, which a previous
+// run of the plugin scheduled but didn't finish: the requests they need aren't kept across restarts.
+func (p awsInstancePlugin) failInterruptedProvisions() {
+	if p.completer == nil {
+		return
+	}
+
+	pending, err := p.describeInstances(map[string]string{ProvisionStatusTag: ProvisionStatusPending}, false, nil)
+	if err != nil {
+		log.Warnf("Failed to describe the instances pending provisioning: %s", err)
+		return
+	}
+	for _, description := range pending {
+		p.completer.finish(description.ID, p.client,
+			fmt.Errorf("Provisioning was interrupted by a restart of the plugin"))
+	}
+}
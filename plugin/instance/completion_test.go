@@ -0,0 +1,225 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/docker/infrakit.aws/fake"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func tagMap(input *ec2.CreateTagsInput) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range input.Tags {
+		tags[*tag.Key] = *tag.Value
+	}
+	return tags
+}
+
+func TestProvisionCompletesInBackground(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	instanceID := "test-id"
+	done := make(chan map[string]string, 1)
+
+	gomock.InOrder(
+		clientMock.EXPECT().RunInstances(gomock.Any()).
+			Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).
+			Do(func(input *ec2.CreateTagsInput) {
+				require.Equal(t, ProvisionStatusPending, tagMap(input)[ProvisionStatusTag])
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().AttachVolume(&ec2.AttachVolumeInput{
+			InstanceId: &instanceID,
+			VolumeId:   aws.String("vol-1"),
+			Device:     aws.String("/dev/sdf"),
+		}).Return(&ec2.VolumeAttachment{}, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).
+			Do(func(input *ec2.CreateTagsInput) { done <- tagMap(input) }).
+			Return(&ec2.CreateTagsOutput{}, nil),
+	)
+
+	pluginImpl := NewInstancePluginWithOptions(clientMock, testNamespace,
+		InstancePluginOptions{CompletionWorkers: 1, CompletionTimeout: time.Minute})
+	id, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(`{"AttachVolumeInputs": [{"VolumeId": "vol-1", "Device": "/dev/sdf"}]}`),
+		Tags:       tags,
+	})
+	require.NoError(t, err)
+	require.Equal(t, instance.ID(instanceID), *id)
+
+	select {
+	case status := <-done:
+		require.Equal(t, map[string]string{ProvisionStatusTag: ProvisionStatusComplete}, status)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Provisioning was not completed")
+	}
+}
+
+func TestProvisionFailsInBackground(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	interval := runningPollInterval
+	runningPollInterval = time.Millisecond
+	defer func() { runningPollInterval = interval }()
+
+	instanceID := "test-id"
+	logicalID := instance.LogicalID("52.0.0.1")
+	done := make(chan map[string]string, 1)
+
+	gomock.InOrder(
+		clientMock.EXPECT().RunInstances(gomock.Any()).
+			Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().DescribeInstances(gomock.Any()).
+			Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{
+				InstanceId: &instanceID,
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			}}}}}, nil),
+		clientMock.EXPECT().DescribeAddresses(gomock.Any()).Return(nil, errors.New("kaboom")),
+		clientMock.EXPECT().CreateTags(gomock.Any()).
+			Do(func(input *ec2.CreateTagsInput) { done <- tagMap(input) }).
+			Return(&ec2.CreateTagsOutput{}, nil),
+	)
+
+	pluginImpl := NewInstancePluginWithOptions(clientMock, testNamespace,
+		InstancePluginOptions{CompletionWorkers: 1, CompletionTimeout: time.Minute})
+	_, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(`{"LogicalIDStrategy": "eip"}`),
+		Tags:       tags,
+		LogicalID:  &logicalID,
+	})
+	require.NoError(t, err)
+
+	select {
+	case status := <-done:
+		require.Equal(t, ProvisionStatusFailed, status[ProvisionStatusTag])
+		require.Equal(t, "DescribeAddresses failed: kaboom", status[ProvisionErrorTag])
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Provisioning was not completed")
+	}
+
+	provisionErr := <-pluginImpl.(ProvisionErrorSource).ProvisionErrors()
+	require.Equal(t, instance.ID(instanceID), provisionErr.ID)
+}
+
+func TestWaitForRunningUndescribedInstance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	interval := runningPollInterval
+	runningPollInterval = time.Millisecond
+	defer func() { runningPollInterval = interval }()
+
+	instanceID := "test-id"
+	gomock.InOrder(
+		clientMock.EXPECT().DescribeInstances(gomock.Any()).Return(&ec2.DescribeInstancesOutput{}, nil),
+		clientMock.EXPECT().DescribeInstances(gomock.Any()).
+			Return(nil, awserr.New("InvalidInstanceID.NotFound", "not found", nil)),
+		clientMock.EXPECT().DescribeInstances(gomock.Any()).
+			Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{{
+				InstanceId: &instanceID,
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			}}}}}, nil),
+	)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: testNamespace}
+	require.NoError(t, pluginImpl.waitForRunning(&instanceID, time.Minute))
+}
+
+func TestWaitForRunningMissingInstance(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	interval := runningPollInterval
+	runningPollInterval = time.Millisecond
+	defer func() { runningPollInterval = interval }()
+
+	clientMock.EXPECT().DescribeInstances(gomock.Any()).
+		Return(nil, awserr.New("InvalidInstanceID.NotFound", "not found", nil)).MinTimes(1)
+
+	instanceID := "test-id"
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: testNamespace}
+	require.Error(t, pluginImpl.waitForRunning(&instanceID, 10*time.Millisecond))
+}
+
+func TestProvisionRegistersWithLoadBalancers(t *testing.T) {
+	interval := runningPollInterval
+	runningPollInterval = time.Millisecond
+	defer func() { runningPollInterval = interval }()
+
+	client, elbClient := fake.NewEC2(), fake.NewELB()
+	_, err := elbClient.CreateLoadBalancer(&elb.CreateLoadBalancerInput{
+		LoadBalancerName: aws.String("workers"),
+		Listeners: []*elb.Listener{{
+			InstancePort:     aws.Int64(80),
+			LoadBalancerPort: aws.Int64(80),
+			Protocol:         aws.String("HTTP"),
+		}},
+		AvailabilityZones: []*string{aws.String(fake.DefaultAvailabilityZone)},
+	})
+	require.NoError(t, err)
+
+	spec := instance.Spec{
+		Properties: types.AnyString(fmt.Sprintf(`{
+			"RunInstancesInput": {"ImageId": "%s", "InstanceType": "t2.micro"},
+			"LoadBalancerNames": ["workers"]
+		}`, fake.DefaultImageID)),
+		Tags: tags,
+	}
+
+	// Without an ELB client, load balancers are refused.
+	_, err = NewInstancePluginWithOptions(client, testNamespace, InstancePluginOptions{}).Provision(spec)
+	require.Equal(t, ErrorClassInvalidSpec, ClassOf(err))
+
+	pluginImpl := NewInstancePluginWithOptions(client, testNamespace,
+		InstancePluginOptions{CompletionTimeout: time.Minute, ELB: elbClient})
+	id, err := pluginImpl.Provision(spec)
+	require.NoError(t, err)
+
+	output, err := elbClient.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String("workers")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*elb.Instance{{InstanceId: (*string)(id)}}, output.LoadBalancerDescriptions[0].Instances)
+}
+
+func TestFailInterruptedProvisions(t *testing.T) {
+	client := fake.NewEC2()
+	options := DefaultInstancePluginOptions
+	options.CompletionWorkers = 0
+	previous := NewInstancePluginWithOptions(client, testNamespace, options)
+
+	pending := provisionInstance(t, previous, false)
+	require.NoError(t, previous.Label(pending, map[string]string{ProvisionStatusTag: ProvisionStatusPending}))
+	complete := provisionInstance(t, previous, false)
+	require.NoError(t, previous.Label(complete, map[string]string{ProvisionStatusTag: ProvisionStatusComplete}))
+
+	pluginImpl := buildInstancePlugin(client, testNamespace, DefaultInstancePluginOptions)
+	provisionErr := <-pluginImpl.(ProvisionErrorSource).ProvisionErrors()
+	require.Equal(t, pending, provisionErr.ID)
+	require.Len(t, pluginImpl.(ProvisionErrorSource).ProvisionErrors(), 0)
+
+	descriptions, err := pluginImpl.DescribeInstances(map[string]string{ProvisionStatusTag: ProvisionStatusFailed}, false)
+	require.NoError(t, err)
+	require.Len(t, descriptions, 1)
+	require.Equal(t, pending, descriptions[0].ID)
+	require.Equal(t, "Provisioning was interrupted by a restart of the plugin", descriptions[0].Tags[ProvisionErrorTag])
+}
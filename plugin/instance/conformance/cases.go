@@ -0,0 +1,298 @@
+package conformance
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/docker/infrakit.aws/fake"
+	plugin "github.com/docker/infrakit.aws/plugin/instance"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// Backend is the clients of the AWS services the plugins are tested against.
+type Backend struct {
+	AutoScaling    autoscalingiface.AutoScalingAPI
+	CloudWatchLogs cloudwatchlogsiface.CloudWatchLogsAPI
+	DynamoDB       dynamodbiface.DynamoDBAPI
+	EC2            ec2iface.EC2API
+	ELB            elbiface.ELBAPI
+	IAM            iamiface.IAMAPI
+	SQS            sqsiface.SQSAPI
+
+	// AvailabilityZone is the availability zone to create resources in.
+	AvailabilityZone string
+
+	// ImageID is the ID of an image to launch instances of.
+	ImageID string
+}
+
+// FakeBackend returns a backend of in-memory fakes.
+func FakeBackend() Backend {
+	return Backend{
+		AutoScaling:      fake.NewAutoScaling(),
+		CloudWatchLogs:   fake.NewCloudWatchLogs(),
+		DynamoDB:         fake.NewDynamoDB(),
+		EC2:              fake.NewEC2(),
+		ELB:              fake.NewELB(),
+		IAM:              fake.NewIAM(),
+		SQS:              fake.NewSQS(),
+		AvailabilityZone: fake.DefaultAvailabilityZone,
+		ImageID:          fake.DefaultImageID,
+	}
+}
+
+// SessionBackend returns a backend of the SDK clients of the session, e.g. of an AWS emulator.
+func SessionBackend(sess client.ConfigProvider, availabilityZone, imageID string) Backend {
+	return Backend{
+		AutoScaling:      autoscaling.New(sess),
+		CloudWatchLogs:   cloudwatchlogs.New(sess),
+		DynamoDB:         dynamodb.New(sess),
+		EC2:              ec2.New(sess),
+		ELB:              elb.New(sess),
+		IAM:              iam.New(sess),
+		SQS:              sqs.New(sess),
+		AvailabilityZone: availabilityZone,
+		ImageID:          imageID,
+	}
+}
+
+const (
+	// assumeRolePolicy lets instances assume a role.
+	assumeRolePolicy = `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", ` +
+		`"Principal": {"Service": "ec2.amazonaws.com"}, "Action": "sts:AssumeRole"}]}`
+
+	// describePolicy allows describing EC2 resources.
+	describePolicy = `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "ec2:Describe*", ` +
+		`"Resource": "*"}]}`
+)
+
+// static returns the properties.
+func static(properties string) func() (string, error) {
+	return func() (string, error) {
+		return properties, nil
+	}
+}
+
+// withVpc returns the properties formatted with the ID of a new VPC of the CIDR block.
+func (b Backend) withVpc(cidr, format string) func() (string, error) {
+	return func() (string, error) {
+		output, err := b.EC2.CreateVpc(&ec2.CreateVpcInput{CidrBlock: aws.String(cidr)})
+		if err != nil {
+			return "", fmt.Errorf("CreateVpc failed: %s", err)
+		}
+		return fmt.Sprintf(format, *output.Vpc.VpcId), nil
+	}
+}
+
+// Cases returns the cases of all the plugins served by the instance plugin, against the backend.
+func (b Backend) Cases() []Case {
+	instanceOptions := plugin.DefaultInstancePluginOptions
+	instanceOptions.CompletionWorkers = 0
+
+	return []Case{
+		{
+			Resource: "autoscaling-autoscalinggroup",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewAutoScalingGroupPlugin(b.AutoScaling, namespaceTags)
+			},
+			Properties: func() (string, error) {
+				_, err := b.AutoScaling.CreateLaunchConfiguration(&autoscaling.CreateLaunchConfigurationInput{
+					LaunchConfigurationName: aws.String("conformance"),
+					ImageId:                 aws.String(b.ImageID),
+					InstanceType:            aws.String("t2.micro"),
+				})
+				if awsErr, ok := err.(awserr.Error); err != nil && !(ok && awsErr.Code() == "AlreadyExists") {
+					return "", fmt.Errorf("CreateLaunchConfiguration failed: %s", err)
+				}
+				return fmt.Sprintf(`{
+					"CreateAutoScalingGroupInput": {
+						"LaunchConfigurationName": "conformance",
+						"AvailabilityZones": ["%s"],
+						"MinSize": 0,
+						"MaxSize": 1
+					},
+					"PutLifecycleHookInputs": [{"LifecycleTransition": "autoscaling:EC2_INSTANCE_TERMINATING"}]
+				}`, b.AvailabilityZone), nil
+			},
+			Invalid: `{"CreateAutoScalingGroupInput": {"MinSize": "none"}}`,
+		},
+		{
+			Resource: "autoscaling-launchconfiguration",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewLaunchConfigurationPlugin(b.AutoScaling, namespaceTags)
+			},
+			Properties: static(fmt.Sprintf(`{
+				"CreateLaunchConfigurationInput": {"ImageId": "%s", "InstanceType": "t2.micro", "UserData": "#!/bin/sh"}
+			}`, b.ImageID)),
+			Invalid: `{"CreateLaunchConfigurationInput": []}`,
+		},
+		{
+			Resource: "cloudwatchlogs-loggroup",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewLogGroupPlugin(b.CloudWatchLogs, namespaceTags)
+			},
+			Properties: static(`{"CreateLogGroupInput": {}, "PutRetentionPolicyInput": {"RetentionInDays": 7}}`),
+			Invalid:    `{"PutRetentionPolicyInput": {"RetentionInDays": "a week"}}`,
+		},
+		{
+			Resource: "dynamodb-table",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewTablePlugin(b.DynamoDB, namespaceTags)
+			},
+			Properties: static(`{
+				"CreateTableInput": {
+					"AttributeDefinitions": [{"AttributeName": "id", "AttributeType": "S"}],
+					"KeySchema": [{"AttributeName": "id", "KeyType": "HASH"}],
+					"ProvisionedThroughput": {"ReadCapacityUnits": 1, "WriteCapacityUnits": 1}
+				}
+			}`),
+			Invalid: `{"CreateTableInput": {"KeySchema": {}}}`,
+		},
+		{
+			Resource: "ec2-instance",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewInstancePluginWithOptions(b.EC2, namespaceTags, instanceOptions)
+			},
+			Properties: static(fmt.Sprintf(`{
+				"RunInstancesInput": {"ImageId": "%s", "InstanceType": "t2.micro"}
+			}`, b.ImageID)),
+			Invalid: `{"DestroyMode": "explode"}`,
+			Labels:  true,
+		},
+		{
+			Resource: "ec2-internetgateway",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewInternetGatewayPlugin(b.EC2, namespaceTags)
+			},
+			Properties: b.withVpc("10.1.0.0/16", `{
+				"CreateInternetGatewayInput": {},
+				"AttachInternetGatewayInput": {"VpcId": "%s"}
+			}`),
+			Invalid: `{"Tags": ["a", "b"]}`,
+			Labels:  true,
+		},
+		{
+			Resource: "ec2-routetable",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewRouteTablePlugin(b.EC2, namespaceTags)
+			},
+			Properties: b.withVpc("10.2.0.0/16", `{"CreateRouteTableInput": {"VpcId": "%s"}}`),
+			Invalid:    `{"CreateRouteInputs": {}}`,
+			Labels:     true,
+		},
+		{
+			Resource: "ec2-securitygroup",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewSecurityGroupPlugin(b.EC2, namespaceTags)
+			},
+			Properties: b.withVpc("10.3.0.0/16", `{
+				"CreateSecurityGroupInput": {"Description": "conformance", "VpcId": "%s"},
+				"AuthorizeSecurityGroupIngressInput": {
+					"IpProtocol": "tcp",
+					"FromPort": 22,
+					"ToPort": 22,
+					"CidrIp": "0.0.0.0/0"
+				}
+			}`),
+			Invalid: `{"CreateSecurityGroupInput": "conformance"}`,
+			Labels:  true,
+		},
+		{
+			Resource: "ec2-subnet",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewSubnetPlugin(b.EC2, namespaceTags)
+			},
+			Properties: b.withVpc("10.4.0.0/16", `{"CreateSubnetInput": {"CidrBlock": "10.4.1.0/24", "VpcId": "%s"}}`),
+			Invalid:    `{"CreateSubnetInput": {"CidrBlock": 10}}`,
+			Labels:     true,
+		},
+		{
+			Resource: "ec2-volume",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewVolumePlugin(b.EC2, namespaceTags)
+			},
+			Properties: static(fmt.Sprintf(`{"CreateVolumeInput": {"AvailabilityZone": "%s", "Size": 1}}`,
+				b.AvailabilityZone)),
+			Invalid: `{"RetainPolicy": "forever"}`,
+			Labels:  true,
+		},
+		{
+			Resource: "ec2-vpc",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewVpcPlugin(b.EC2, namespaceTags)
+			},
+			Properties: static(`{
+				"CreateVpcInput": {"CidrBlock": "10.5.0.0/16"},
+				"ModifyVpcAttributeInputs": [{"EnableDnsHostnames": {"Value": true}}]
+			}`),
+			Invalid: `{"ModifyVpcAttributeInputs": {}}`,
+			Labels:  true,
+		},
+		{
+			Resource: "elb-loadbalancer",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewLoadBalancerPlugin(b.ELB, namespaceTags)
+			},
+			Properties: static(fmt.Sprintf(`{
+				"CreateLoadBalancerInput": {
+					"AvailabilityZones": ["%s"],
+					"Listeners": [{"Protocol": "TCP", "LoadBalancerPort": 80, "InstancePort": 8080}]
+				},
+				"ConfigureHealthCheckInput": {
+					"HealthCheck": {
+						"Target": "TCP:8080",
+						"Interval": 10,
+						"Timeout": 5,
+						"HealthyThreshold": 2,
+						"UnhealthyThreshold": 2
+					}
+				}
+			}`, b.AvailabilityZone)),
+			Invalid: `{"CreateLoadBalancerInput": {"Listeners": {}}}`,
+			Labels:  true,
+		},
+		{
+			Resource: "iam-instanceprofile",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewInstanceProfilePlugin(b.IAM, namespaceTags)
+			},
+			Properties: static(`{"CreateInstanceProfileInput": {}}`),
+			Invalid:    `{"AddRoleToInstanceProfileInput": {"RoleName": ["a", "b"]}}`,
+		},
+		{
+			Resource: "iam-role",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewRolePlugin(b.IAM, namespaceTags)
+			},
+			Properties: static(fmt.Sprintf(`{
+				"CreateRoleInput": {"AssumeRolePolicyDocument": %q},
+				"PutRolePolicyInputs": [{"PolicyDocument": %q}]
+			}`, assumeRolePolicy, describePolicy)),
+			Invalid: `{"PutRolePolicyInputs": "all"}`,
+		},
+		{
+			Resource: "sqs-queue",
+			New: func(namespaceTags map[string]string) instance.Plugin {
+				return plugin.NewQueuePlugin(b.SQS, namespaceTags)
+			},
+			Properties: static(`{"CreateQueueInput": {"Attributes": {"VisibilityTimeout": "60"}}}`),
+			Invalid:    `{"CreateQueueInput": {"Attributes": {"VisibilityTimeout": 60}}}`,
+		},
+	}
+}
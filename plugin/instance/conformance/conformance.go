@@ -0,0 +1,126 @@
+// Package conformance tests that instance plugins provision, describe, label and destroy their resources with the
+// same tag and namespace semantics, against a backend of fakes or of an AWS emulator.
+package conformance
+
+import (
+	"testing"
+
+	plugin "github.com/docker/infrakit.aws/plugin/instance"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Case is an instance plugin under test.
+type Case struct {
+	// Resource is the resource type of the plugin, as served by the instance plugin.
+	Resource string
+
+	// New returns the plugin of the namespace.
+	New func(namespaceTags map[string]string) instance.Plugin
+
+	// Properties returns the properties of a valid spec, creating the resources they refer to.
+	Properties func() (string, error)
+
+	// Invalid are properties that Validate rejects.
+	Invalid string
+
+	// Labels is true if Label tags the resource, so that it is described with its labels.  Otherwise Label must
+	// succeed without effect.
+	Labels bool
+}
+
+var (
+	// Namespace is the namespace of the plugins under test.
+	Namespace = map[string]string{"infrakit.namespace": "conformance"}
+
+	// OtherNamespace is a namespace whose plugins must not describe the resources of Namespace.
+	OtherNamespace = map[string]string{"infrakit.namespace": "elsewhere"}
+
+	// Tags are the tags of the spec provisioned.
+	Tags = map[string]string{"infrakit.group": "workers", "infrakit.config_sha": "0123456789"}
+
+	// Labels are the labels applied to the resource provisioned.
+	Labels = map[string]string{"infrakit.link": "abcdef"}
+
+	// breaker doesn't limit destroys.
+	breaker = plugin.NewDestroyBreaker(0, 0)
+)
+
+// describes returns true if the descriptions have the ID.
+func describes(descriptions []instance.Description, id instance.ID) bool {
+	for _, description := range descriptions {
+		if description.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// merge returns the union of the maps.
+func merge(maps ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// Run tests the plugin of the case:  its resource is provisioned with Tags, described by its tags and not by a
+// plugin of OtherNamespace, which refuses to label and destroy it, labeled with Labels, and destroyed twice, after
+// which it is no longer described.  Plugins are guarded by their namespace, and by the protection of resources.
+func Run(t *testing.T, c Case) {
+	p := plugin.NewNamespaceGuardPlugin(plugin.NewSafetyPlugin(c.Resource, c.New(Namespace), breaker), false)
+
+	require.Error(t, p.Validate(types.AnyString(c.Invalid)), "Validate accepted %s", c.Invalid)
+
+	properties, err := c.Properties()
+	require.NoError(t, err)
+	require.NoError(t, p.Validate(types.AnyString(properties)))
+
+	id, err := p.Provision(instance.Spec{Properties: types.AnyString(properties), Tags: Tags})
+	require.NoError(t, err)
+	require.NotNil(t, id)
+
+	descriptions, err := p.DescribeInstances(Tags, false)
+	require.NoError(t, err)
+	require.True(t, describes(descriptions, *id), "%s not described by %v: %v", *id, Tags, descriptions)
+
+	other := plugin.NewNamespaceGuardPlugin(c.New(OtherNamespace), false)
+	descriptions, err = other.DescribeInstances(Tags, false)
+	require.NoError(t, err)
+	require.False(t, describes(descriptions, *id), "%s described in another namespace: %v", *id, descriptions)
+	err = other.Label(*id, Labels)
+	require.Equal(t, plugin.ErrorClassNamespace, plugin.ClassOf(err), "%s labeled in another namespace: %v", *id, err)
+	err = other.Destroy(*id)
+	require.Equal(t, plugin.ErrorClassNamespace, plugin.ClassOf(err), "%s destroyed in another namespace: %v", *id, err)
+
+	require.NoError(t, p.Label(*id, Labels))
+	if c.Labels {
+		descriptions, err = p.DescribeInstances(merge(Tags, Labels), false)
+		require.NoError(t, err)
+		require.True(t, describes(descriptions, *id), "%s not described by its labels: %v", *id, descriptions)
+		for _, description := range descriptions {
+			if description.ID == *id {
+				for k, v := range merge(Namespace, Tags, Labels) {
+					require.Equal(t, v, description.Tags[k], "tag %s of %s", k, *id)
+				}
+			}
+		}
+	}
+
+	require.NoError(t, p.Destroy(*id))
+	descriptions, err = p.DescribeInstances(Tags, false)
+	require.NoError(t, err)
+	require.False(t, describes(descriptions, *id), "%s described after Destroy: %v", *id, descriptions)
+
+	// Destroying the resource again may only fail as the resource is missing, and must not bring it back.
+	err = p.Destroy(*id)
+	require.True(t, err == nil || plugin.ClassOf(err) == plugin.ErrorClassNotFound,
+		"%s destroyed twice: %v", *id, err)
+	descriptions, err = p.DescribeInstances(Tags, false)
+	require.NoError(t, err)
+	require.False(t, describes(descriptions, *id), "%s described after destroying twice: %v", *id, descriptions)
+}
@@ -0,0 +1,27 @@
+package conformance
+
+import (
+	"os"
+	"testing"
+
+	plugin "github.com/docker/infrakit.aws/plugin/instance"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the cases of all the plugins against fakes, or against the AWS emulator at the URL of
+// INFRAKIT_AWS_CONFORMANCE_EMULATOR, launching instances of the image INFRAKIT_AWS_CONFORMANCE_IMAGE.
+func TestConformance(t *testing.T) {
+	backend := FakeBackend()
+	if emulator := os.Getenv("INFRAKIT_AWS_CONFORMANCE_EMULATOR"); emulator != "" {
+		sess, err := plugin.NewSession(plugin.SessionOptions{Emulator: emulator, Region: "us-east-1"})
+		require.NoError(t, err)
+		backend = SessionBackend(sess, "us-east-1a", os.Getenv("INFRAKIT_AWS_CONFORMANCE_IMAGE"))
+	}
+
+	for _, c := range backend.Cases() {
+		c := c
+		t.Run(c.Resource, func(t *testing.T) {
+			Run(t, c)
+		})
+	}
+}
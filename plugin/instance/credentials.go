@@ -0,0 +1,100 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Sources of AWS API credentials.
+const (
+	// CredentialsChain tries the EC2 instance role, the environment, the shared credentials file and the static
+	// credentials, in that order.
+	CredentialsChain = "chain"
+
+	// CredentialsInstance uses the role of the EC2 instance.
+	CredentialsInstance = "instance"
+
+	// CredentialsEnv uses the AWS_* environment variables.
+	CredentialsEnv = "env"
+
+	// CredentialsShared uses a profile of the shared credentials file.
+	CredentialsShared = "shared"
+
+	// CredentialsStatic uses the access key or session token given as options.
+	CredentialsStatic = "static"
+)
+
+// CredentialsOptions select and configure the source of AWS API credentials.
+type CredentialsOptions struct {
+	Source          string
+	Profile         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+func (o CredentialsOptions) static() credentials.Provider {
+	if (len(o.AccessKeyID) > 0 && len(o.SecretAccessKey) > 0) || len(o.SessionToken) > 0 {
+		return &credentials.StaticProvider{
+			Value: credentials.Value{
+				AccessKeyID:     o.AccessKeyID,
+				SecretAccessKey: o.SecretAccessKey,
+				SessionToken:    o.SessionToken,
+			},
+		}
+	}
+	return nil
+}
+
+// metadataClient returns the client of the instance metadata service at the URL, which the instance role
+// credentials are retrieved from.
+func metadataClient(url string) *ec2metadata.EC2Metadata {
+	if url == defaultMetadataURL {
+		return ec2metadata.New(session.New())
+	}
+	return ec2metadata.New(session.New(), aws.NewConfig().WithEndpoint(url+"/latest"))
+}
+
+// Credentials returns the credentials from the source.
+func (o CredentialsOptions) Credentials() (*credentials.Credentials, error) {
+	return o.credentials(defaultMetadataURL)
+}
+
+// credentials returns the credentials from the source, retrieving those of the instance role from the instance
+// metadata service at the URL.
+func (o CredentialsOptions) credentials(metadataURL string) (*credentials.Credentials, error) {
+	switch o.Source {
+	case "", CredentialsChain:
+		providers := []credentials.Provider{
+			&ec2rolecreds.EC2RoleProvider{Client: metadataClient(metadataURL)},
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{Profile: o.Profile},
+		}
+		if static := o.static(); static != nil {
+			providers = append(providers, static)
+		}
+		return credentials.NewChainCredentials(providers), nil
+
+	case CredentialsInstance:
+		return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{Client: metadataClient(metadataURL)}), nil
+
+	case CredentialsEnv:
+		return credentials.NewEnvCredentials(), nil
+
+	case CredentialsShared:
+		return credentials.NewSharedCredentials("", o.Profile), nil
+
+	case CredentialsStatic:
+		static := o.static()
+		if static == nil {
+			return nil, fmt.Errorf("Static credentials need an access key ID and secret, or a session token")
+		}
+		return credentials.NewCredentials(static), nil
+	}
+	return nil, fmt.Errorf("Unknown credentials source: %s", o.Source)
+}
@@ -0,0 +1,248 @@
+package instance
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/docker/infrakit.aws/plugin/audit"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// dryRunErrorCode is the code of the error of EC2 operations checked with DryRun that would have succeeded.  Other
+// mutating operations fail with it in dry runs without being sent.
+const dryRunErrorCode = "DryRunOperation"
+
+// isDryRun returns true if the error is that of a mutating operation in a dry run that would have been made.
+func isDryRun(err error) bool {
+	if awsErr, is := err.(awserr.Error); is {
+		return awsErr.Code() == dryRunErrorCode
+	}
+	// Plugins wrap errors in their own.
+	return err != nil && strings.Contains(err.Error(), dryRunErrorCode)
+}
+
+// installDryRun installs handlers that prevent mutating operations: EC2 operations are sent with DryRun set, which
+// only checks permissions, and the operations of other services fail without being sent.
+func installDryRun(handlers *request.Handlers) {
+	handlers.Validate.PushFront(func(r *request.Request) {
+		if isDescribe(r.Operation.Name) || r.ClientInfo.ServiceName == "sts" {
+			return
+		}
+
+		params := reflect.Indirect(reflect.ValueOf(r.Params))
+		if r.ClientInfo.ServiceName == "ec2" && params.Kind() == reflect.Struct {
+			if dryRun := params.FieldByName("DryRun"); dryRun.IsValid() && dryRun.CanSet() {
+				log.Infof("Dry run: checking %s %s", r.ClientInfo.ServiceName, r.Operation.Name)
+				dryRun.Set(reflect.ValueOf(aws.Bool(true)))
+				return
+			}
+		}
+
+		log.Infof("Dry run: not calling %s %s", r.ClientInfo.ServiceName, r.Operation.Name)
+		r.Error = awserr.New(dryRunErrorCode, "Request would have succeeded, but this is a dry run", nil)
+	})
+}
+
+// dryRunPlugin simulates the instances of a plugin.  Mutating methods are called on the plugin, whose clients must
+// be in dry run mode, to check them, and their effects are simulated.  Described instances are the real instances of
+// the plugin, with the simulated changes.
+type dryRunPlugin struct {
+	passThroughPlugin
+	resource string
+
+	lock      *sync.Mutex
+	instances map[instance.ID]instance.Description
+	labels    map[instance.ID]map[string]string
+	destroyed map[instance.ID]bool
+	ids       map[string]int
+}
+
+// NewDryRunPlugin returns a plugin simulating the instances of the resource type of the plugin, whose clients must
+// be in dry run mode.  Provisioned instances have stable IDs, derived from their specs.
+func NewDryRunPlugin(resource string, plugin instance.Plugin) instance.Plugin {
+	return &dryRunPlugin{
+		passThroughPlugin: passThrough(plugin),
+		resource:          resource,
+		lock:              &sync.Mutex{},
+		instances:         map[instance.ID]instance.Description{},
+		labels:            map[instance.ID]map[string]string{},
+		destroyed:         map[instance.ID]bool{},
+		ids:               map[string]int{},
+	}
+}
+
+// check calls the method of the plugin, and returns its error, unless it would have succeeded.
+func check(err error) error {
+	if isDryRun(err) {
+		return nil
+	}
+	return err
+}
+
+// id returns the ID of the instance of the spec.  Instances with a logical ID are identified by it, and others by
+// the hash of their spec, and the number of instances provisioned with the spec.
+func (p *dryRunPlugin) id(spec instance.Spec) (instance.ID, error) {
+	if spec.LogicalID != nil {
+		return instance.ID(fmt.Sprintf("dryrun-%s-%s", p.resource, *spec.LogicalID)), nil
+	}
+
+	keys := []string{}
+	for k := range spec.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	hash := sha1.New()
+	for _, k := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", k, spec.Tags[k])
+	}
+	properties, err := json.Marshal(spec.Properties)
+	if err != nil {
+		return "", err
+	}
+	hash.Write(properties)
+	sum := hex.EncodeToString(hash.Sum(nil))[:10]
+
+	p.ids[sum]++
+	return instance.ID(fmt.Sprintf("dryrun-%s-%s-%d", p.resource, sum, p.ids[sum])), nil
+}
+
+// WithCall returns the plugin checking the methods with the plugin of the call of the plugin, sharing the simulation.
+func (p *dryRunPlugin) WithCall(call *audit.Call) instance.Plugin {
+	simulated := *p
+	simulated.passThroughPlugin = passThrough(audit.WithCall(p.Wrapped(), call))
+	return &simulated
+}
+
+// Provision checks provisioning the instance, and simulates it.
+func (p *dryRunPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	if _, err := p.Plugin.Provision(spec); check(err) != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	id, err := p.id(spec)
+	if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{}
+	for k, v := range spec.Tags {
+		tags[k] = v
+	}
+	p.instances[id] = instance.Description{ID: id, LogicalID: spec.LogicalID, Tags: tags}
+	log.Infof("Dry run: provisioned %s %s with tags %v", p.resource, id, tags)
+	return &id, nil
+}
+
+// Label checks labeling the instance, and simulates it.
+func (p *dryRunPlugin) Label(id instance.ID, labels map[string]string) error {
+	p.lock.Lock()
+	_, simulated := p.instances[id]
+	p.lock.Unlock()
+
+	if !simulated {
+		if err := check(p.Plugin.Label(id, labels)); err != nil {
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if simulated {
+		for k, v := range labels {
+			p.instances[id].Tags[k] = v
+		}
+	} else {
+		if p.labels[id] == nil {
+			p.labels[id] = map[string]string{}
+		}
+		for k, v := range labels {
+			p.labels[id][k] = v
+		}
+	}
+	log.Infof("Dry run: labeled %s %s with %v", p.resource, id, labels)
+	return nil
+}
+
+// Destroy checks destroying the instance, and simulates it.
+func (p *dryRunPlugin) Destroy(id instance.ID) error {
+	p.lock.Lock()
+	_, simulated := p.instances[id]
+	p.lock.Unlock()
+
+	if !simulated {
+		if err := check(p.Plugin.Destroy(id)); err != nil {
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.instances, id)
+	p.destroyed[id] = true
+	log.Infof("Dry run: destroyed %s %s", p.resource, id)
+	return nil
+}
+
+func hasTags(actual, expected map[string]string) bool {
+	for k, v := range expected {
+		if actual[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DescribeInstances describes the real instances with the tags that were not destroyed, and the simulated ones.
+func (p *dryRunPlugin) DescribeInstances(tags map[string]string, properties bool) ([]instance.Description, error) {
+	described, err := p.Plugin.DescribeInstances(tags, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	descriptions := []instance.Description{}
+	for _, description := range described {
+		if p.destroyed[description.ID] {
+			continue
+		}
+		if labels, has := p.labels[description.ID]; has {
+			merged := map[string]string{}
+			for k, v := range description.Tags {
+				merged[k] = v
+			}
+			for k, v := range labels {
+				merged[k] = v
+			}
+			description.Tags = merged
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	ids := []string{}
+	for id := range p.instances {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if description := p.instances[instance.ID(id)]; hasTags(description.Tags, tags) {
+			descriptions = append(descriptions, description)
+		}
+	}
+	return descriptions, nil
+}
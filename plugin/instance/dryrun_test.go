@@ -0,0 +1,109 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunSession(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, r.Form.Get("Action")+" "+r.Form.Get("DryRun"))
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(`<Response><Errors><Error><Code>DryRunOperation</Code>` +
+			`<Message>Request would have succeeded, but DryRun flag is set.</Message></Error></Errors></Response>`))
+	}))
+	defer server.Close()
+
+	sess, err := NewSession(SessionOptions{Emulator: server.URL, Region: "us-east-1", DryRun: true})
+	require.NoError(t, err)
+
+	_, err = ec2.New(sess).TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{aws.String("i-1")}})
+	require.True(t, isDryRun(err))
+	require.Equal(t, []string{"TerminateInstances true"}, requests)
+
+	_, err = sqs.New(sess).CreateQueue(&sqs.CreateQueueInput{QueueName: aws.String("queue")})
+	require.True(t, isDryRun(err))
+	require.Equal(t, []string{"TerminateInstances true"}, requests)
+
+	require.True(t, isDryRun(fmt.Errorf("CreateQueue failed: %s", err)))
+}
+
+type dryRunTestPlugin struct {
+	instance.Plugin
+	err error
+}
+
+func (p dryRunTestPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	return nil, p.err
+}
+
+func (p dryRunTestPlugin) Destroy(id instance.ID) error {
+	return p.err
+}
+
+func (p dryRunTestPlugin) DescribeInstances(tags map[string]string, properties bool) ([]instance.Description, error) {
+	return []instance.Description{
+		{ID: "i-1", Tags: map[string]string{"group": "workers"}},
+		{ID: "i-2", Tags: map[string]string{"group": "workers"}},
+	}, nil
+}
+
+func TestDryRunPlugin(t *testing.T) {
+	dryRunErr := awserr.New(dryRunErrorCode, "dry run", nil)
+	plugin := NewDryRunPlugin("ec2-instance", dryRunTestPlugin{err: dryRunErr})
+
+	spec := instance.Spec{Properties: types.AnyString(`{}`), Tags: map[string]string{"group": "workers"}}
+	id1, err := plugin.Provision(spec)
+	require.NoError(t, err)
+	id2, err := plugin.Provision(spec)
+	require.NoError(t, err)
+	require.NotEqual(t, *id1, *id2)
+
+	// IDs are stable.
+	again, err := NewDryRunPlugin("ec2-instance", dryRunTestPlugin{err: dryRunErr}).Provision(spec)
+	require.NoError(t, err)
+	require.Equal(t, *id1, *again)
+
+	logicalID := instance.LogicalID("10.0.0.1")
+	id3, err := plugin.Provision(instance.Spec{Properties: types.AnyString(`{}`), LogicalID: &logicalID})
+	require.NoError(t, err)
+	require.Equal(t, instance.ID("dryrun-ec2-instance-10.0.0.1"), *id3)
+
+	require.NoError(t, plugin.Destroy("i-1"))
+	require.NoError(t, plugin.Label(*id1, map[string]string{"label": "value"}))
+
+	described, err := plugin.DescribeInstances(map[string]string{"group": "workers"}, false)
+	require.NoError(t, err)
+	ids := []instance.ID{}
+	for _, description := range described {
+		ids = append(ids, description.ID)
+	}
+	require.Len(t, ids, 3)
+	require.Equal(t, instance.ID("i-2"), ids[0])
+	require.Contains(t, ids, *id1)
+	require.Contains(t, ids, *id2)
+
+	described, err = plugin.DescribeInstances(map[string]string{"label": "value"}, false)
+	require.NoError(t, err)
+	require.Equal(t, *id1, described[len(described)-1].ID)
+
+	// Other errors fail the methods.
+	plugin = NewDryRunPlugin("ec2-instance", dryRunTestPlugin{err: errors.New("UnauthorizedOperation")})
+	_, err = plugin.Provision(spec)
+	require.Error(t, err)
+	require.Error(t, plugin.Destroy("i-1"))
+}
@@ -2,12 +2,12 @@ package instance
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -26,14 +26,24 @@ type createTableRequest struct {
 	CreateTableInput dynamodb.CreateTableInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsTablePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(dynamodbiface.DynamoDBAPI)
+	return p
+}
+
 func (p awsTablePlugin) Validate(req *types.Any) error {
+	request := createTableRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsTablePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createTableRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	name := newTableName(spec.Tags, p.namespaceTags)
@@ -41,20 +51,25 @@ func (p awsTablePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request.CreateTableInput.TableName = aws.String(name)
 	_, err := p.client.CreateTable(&request.CreateTableInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateTable failed: %s", err)
+		return nil, apiError("CreateTable", err)
 	}
 	id := instance.ID(name)
 
 	return &id, nil
 }
 
+// inNamespace returns true if the name of the table has the values of the namespace tags.
+func (p awsTablePlugin) inNamespace(id instance.ID) (bool, error) {
+	return namedInNamespace(string(id), newTableName, p.namespaceTags), nil
+}
+
 func (p awsTablePlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
 
 func (p awsTablePlugin) Destroy(id instance.ID) error {
 	if _, err := p.client.DeleteTable(&dynamodb.DeleteTableInput{TableName: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteTable failed: %s", err)
+		return apiError("DeleteTable", err)
 	}
 	return nil
 }
@@ -66,7 +81,7 @@ func (p awsTablePlugin) DescribeInstances(tags map[string]string, properties boo
 		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ResourceNotFoundException" {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("ListTables failed: %s", err)
+		return nil, apiError("ListTables", err)
 	}
 
 	return []instance.Description{{ID: instance.ID(name), Tags: tags}}, nil
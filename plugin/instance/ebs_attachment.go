@@ -0,0 +1,336 @@
+package instance
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+const (
+	// RetiredVolumeTag is the AWS tag name that replaces VolumeTag on a volume that was migrated to another
+	// availability zone.
+	RetiredVolumeTag = "docker-infrakit-volume-retired"
+
+	// DeviceTagPrefix is the prefix of the AWS tag names on an instance mapping an attached volume ID to its device
+	// name, e.g. infrakit.device.vol-1234=/dev/sdf.
+	DeviceTagPrefix = "infrakit.device."
+
+	// deviceLetters are the letters of the device names recommended for EBS volumes, /dev/sd[f-p].
+	deviceLetters = "fghijklmnop"
+)
+
+// EBSAttachmentPolicy configures the EBS volumes attached to an instance by its ebs attachments, i.e. the volumes
+// tagged with VolumeTag and the ID of the attachment.
+type EBSAttachmentPolicy struct {
+	// CreateMissing creates a volume in the availability zone of the instance when no volume is tagged with the
+	// ID of an attachment.
+	CreateMissing bool
+
+	// CreateVolumeInput is the template of volumes created, e.g. with Size, VolumeType, Encrypted and KmsKeyId.
+	CreateVolumeInput ec2.CreateVolumeInput
+
+	// Tags are additional tags of volumes created.
+	Tags map[string]string
+
+	// MigrateAcrossZones moves a volume that is in a different availability zone than the instance to the zone of
+	// the instance, by creating a new volume from a snapshot of it.
+	MigrateAcrossZones bool
+
+	// Devices are the device names of the volumes by attachment ID, e.g. /dev/sdg.  Volumes without a device name
+	// are assigned a free one.
+	Devices map[string]string
+
+	// RetireRetainPolicy is applied to the old volume once it is migrated.  Defaults to RetainPolicyRetain.  The
+	// snapshot taken for the migration is kept, unless the policy is RetainPolicyDelete.
+	RetireRetainPolicy RetainPolicy
+}
+
+func (p EBSAttachmentPolicy) validate() error {
+	if p.CreateMissing && p.CreateVolumeInput.Size == nil && p.CreateVolumeInput.SnapshotId == nil {
+		return newError(ErrorClassInvalidSpec,
+			"Size or SnapshotId of CreateVolumeInput is required to create missing volumes")
+	}
+	if !validRetainPolicy(p.RetireRetainPolicy) {
+		return newError(ErrorClassInvalidSpec, "Invalid retain policy: %s", p.RetireRetainPolicy)
+	}
+	return nil
+}
+
+// ebsAttachment is an ebs attachment and the volume it refers to, if found.
+type ebsAttachment struct {
+	ID     string
+	Volume *ec2.Volume
+	Device string
+}
+
+// AllocateDeviceNames returns count device names of the form /dev/sdX that don't collide with the device names in
+// use.  Device names in use may be given in any of the forms /dev/sdX, /dev/xvdX, sdX or xvdX.
+func AllocateDeviceNames(inUse []string, count int) ([]string, error) {
+	used := map[byte]bool{}
+	for _, name := range inUse {
+		name = strings.TrimPrefix(name, "/dev/")
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "xvd"), "sd")
+		if len(name) > 0 {
+			used[name[0]] = true
+		}
+	}
+
+	names := []string{}
+	for i := 0; i < len(deviceLetters) && len(names) < count; i++ {
+		if !used[deviceLetters[i]] {
+			names = append(names, fmt.Sprintf("/dev/sd%c", deviceLetters[i]))
+		}
+	}
+	if len(names) < count {
+		return nil, newError(ErrorClassInvalidSpec, "Unable to allocate %d device names, %d available", count, len(names))
+	}
+	return names, nil
+}
+
+// assignEBSDevices assigns the device names of the attachments, either as configured in the policy or allocated
+// from the names not used by the block device mappings of the image and the request.
+func (p awsInstancePlugin) assignEBSDevices(attachments []ebsAttachment, request CreateInstanceRequest) error {
+	inUse := []string{}
+	unassigned := []int{}
+	for i, attachment := range attachments {
+		if device, has := request.EBSAttachment.Devices[attachment.ID]; has {
+			attachments[i].Device = device
+			inUse = append(inUse, device)
+		} else {
+			unassigned = append(unassigned, i)
+		}
+	}
+
+	if len(unassigned) == 0 {
+		return nil
+	}
+
+	run := request.RunInstancesInput
+	mappings := run.BlockDeviceMappings
+	if run.ImageId != nil {
+		output, err := p.client.DescribeImages(&ec2.DescribeImagesInput{ImageIds: []*string{run.ImageId}})
+		if err != nil {
+			return apiError("DescribeImages", err)
+		}
+		for _, image := range output.Images {
+			mappings = append(mappings, image.BlockDeviceMappings...)
+		}
+	}
+	for _, mapping := range mappings {
+		inUse = append(inUse, aws.StringValue(mapping.DeviceName))
+	}
+	for _, input := range request.AttachVolumeInputs {
+		inUse = append(inUse, aws.StringValue(input.Device))
+	}
+
+	names, err := AllocateDeviceNames(inUse, len(unassigned))
+	if err != nil {
+		return err
+	}
+	for i, index := range unassigned {
+		attachments[index].Device = names[i]
+	}
+	return nil
+}
+
+// deviceTags returns the tags mapping the volumes of the attachments to their device names.
+func deviceTags(attachments []ebsAttachment) map[string]string {
+	tags := map[string]string{}
+	for _, attachment := range attachments {
+		tags[DeviceTagPrefix+aws.StringValue(attachment.Volume.VolumeId)] = attachment.Device
+	}
+	return tags
+}
+
+// availabilityZone returns the availability zone of the launched instance, falling back to that of the request.
+func availabilityZone(ec2Instance *ec2.Instance, request CreateInstanceRequest) string {
+	if ec2Instance.Placement != nil && ec2Instance.Placement.AvailabilityZone != nil {
+		return *ec2Instance.Placement.AvailabilityZone
+	}
+	if request.RunInstancesInput.Placement != nil {
+		return aws.StringValue(request.RunInstancesInput.Placement.AvailabilityZone)
+	}
+	return ""
+}
+
+// createMissingEBSVolumes creates the volumes of the attachments that were not found, and waits for them to become
+// available.
+func (p awsInstancePlugin) createMissingEBSVolumes(
+	attachments []ebsAttachment,
+	availabilityZone string,
+	policy EBSAttachmentPolicy) error {
+
+	created := []*string{}
+	for i, attachment := range attachments {
+		if attachment.Volume != nil {
+			continue
+		}
+		if availabilityZone == "" {
+			return newError(ErrorClassInvalidSpec,
+				"Unable to create volume %s without the availability zone of the instance", attachment.ID)
+		}
+
+		input := policy.CreateVolumeInput
+		input.AvailabilityZone = aws.String(availabilityZone)
+		volume, err := p.client.CreateVolume(&input)
+		if err != nil {
+			return apiError("CreateVolume", err)
+		}
+		log.Infof("Created volume %s for attachment %s in %s", *volume.VolumeId, attachment.ID, availabilityZone)
+
+		err = ec2CreateTags(p.client, instance.ID(*volume.VolumeId),
+			policy.Tags, map[string]string{VolumeTag: attachment.ID}, p.namespaceTags)
+		if err != nil {
+			return apiError("CreateTags", err)
+		}
+
+		attachments[i].Volume = volume
+		created = append(created, volume.VolumeId)
+	}
+
+	if len(created) == 0 {
+		return nil
+	}
+
+	if err := p.client.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: created}); err != nil {
+		return apiError("WaitUntilVolumeAvailable", err)
+	}
+	return nil
+}
+
+// migrateEBSVolumes moves the volumes of the attachments that are not in the availability zone of the instance.
+func (p awsInstancePlugin) migrateEBSVolumes(
+	attachments []ebsAttachment,
+	availabilityZone string,
+	policy EBSAttachmentPolicy) error {
+
+	for i, attachment := range attachments {
+		if attachment.Volume == nil || availabilityZone == "" ||
+			aws.StringValue(attachment.Volume.AvailabilityZone) == availabilityZone {
+			continue
+		}
+		if !policy.MigrateAcrossZones {
+			return newError(ErrorClassInvalidSpec, "Volume %s for attachment %s is in %s, not in %s of the instance",
+				*attachment.Volume.VolumeId, attachment.ID, *attachment.Volume.AvailabilityZone, availabilityZone)
+		}
+
+		volume, err := p.migrateEBSVolume(attachment, availabilityZone, policy)
+		if err != nil {
+			return err
+		}
+		attachments[i].Volume = volume
+	}
+	return nil
+}
+
+// migrateEBSVolume moves the volume of the attachment to the availability zone via a snapshot.  Until the VolumeTag is
+// moved to the new volume, a failure deletes the snapshot and the new volume, so that a retry starts over.
+func (p awsInstancePlugin) migrateEBSVolume(
+	attachment ebsAttachment,
+	availabilityZone string,
+	policy EBSAttachmentPolicy) (volume *ec2.Volume, err error) {
+
+	old := attachment.Volume
+	log.Infof("Migrating volume %s for attachment %s from %s to %s",
+		*old.VolumeId, attachment.ID, *old.AvailabilityZone, availabilityZone)
+
+	snapshot, err := p.client.CreateSnapshot(&ec2.CreateSnapshotInput{
+		VolumeId:    old.VolumeId,
+		Description: aws.String(fmt.Sprintf("Migration of %s to %s", *old.VolumeId, availabilityZone)),
+	})
+	if err != nil {
+		return nil, apiError("CreateSnapshot", err)
+	}
+	defer func() {
+		if err != nil {
+			p.abortEBSVolumeMigration(snapshot.SnapshotId, volume)
+			volume = nil
+		}
+	}()
+
+	err = p.client.WaitUntilSnapshotCompleted(&ec2.DescribeSnapshotsInput{SnapshotIds: []*string{snapshot.SnapshotId}})
+	if err != nil {
+		return nil, apiError("WaitUntilSnapshotCompleted", err)
+	}
+
+	input := &ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(availabilityZone),
+		SnapshotId:       snapshot.SnapshotId,
+		VolumeType:       old.VolumeType,
+	}
+	if aws.StringValue(old.VolumeType) == ec2.VolumeTypeIo1 {
+		input.Iops = old.Iops
+	}
+	volume, err = p.client.CreateVolume(input)
+	if err != nil {
+		return nil, apiError("CreateVolume", err)
+	}
+
+	// The new volume takes over all tags of the old one that can be set, including VolumeTag.
+	tags := map[string]string{}
+	for _, tag := range old.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	if err = ec2CreateTags(p.client, instance.ID(*volume.VolumeId), withoutReservedTags(tags)); err != nil {
+		return volume, apiError("CreateTags", err)
+	}
+
+	err = p.client.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}})
+	if err != nil {
+		return volume, apiError("WaitUntilVolumeAvailable", err)
+	}
+
+	_, err = p.client.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{old.VolumeId},
+		Tags:      []*ec2.Tag{{Key: aws.String(VolumeTag)}},
+	})
+	if err != nil {
+		return volume, apiError("DeleteTags", err)
+	}
+	log.Infof("Migrated volume %s to %s", *old.VolumeId, *volume.VolumeId)
+
+	// The new volume is the volume of the attachment now, so failing to retire the old one doesn't fail the migration.
+	err = ec2CreateTags(p.client, instance.ID(*old.VolumeId), map[string]string{RetiredVolumeTag: attachment.ID})
+	if err != nil {
+		log.Warnf("Failed to tag migrated volume %s as retired: %s", *old.VolumeId, err)
+		err = nil
+	}
+
+	switch policy.RetireRetainPolicy {
+	case RetainPolicySnapshot, RetainPolicyDelete:
+		// The old volume may still be attached to an instance in an unavailable zone, so failing to delete it
+		// doesn't fail the migration.
+		if _, err := p.client.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: old.VolumeId}); err != nil {
+			log.Warnf("Failed to delete migrated volume %s: %s", *old.VolumeId, err)
+		}
+	}
+	if policy.RetireRetainPolicy == RetainPolicyDelete {
+		if _, err := p.client.DeleteSnapshot(&ec2.DeleteSnapshotInput{SnapshotId: snapshot.SnapshotId}); err != nil {
+			log.Warnf("Failed to delete snapshot %s: %s", *snapshot.SnapshotId, err)
+		}
+	}
+
+	return volume, nil
+}
+
+// abortEBSVolumeMigration deletes the new volume, if any, and the snapshot of a failed migration.
+func (p awsInstancePlugin) abortEBSVolumeMigration(snapshotID *string, volume *ec2.Volume) {
+	if volume != nil {
+		err := p.client.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}})
+		if err == nil {
+			_, err = p.client.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: volume.VolumeId})
+		}
+		if err != nil {
+			log.Warnf("Failed to delete volume %s of a failed migration: %s", *volume.VolumeId, err)
+		}
+	}
+	if _, err := p.client.DeleteSnapshot(&ec2.DeleteSnapshotInput{SnapshotId: snapshotID}); err != nil {
+		log.Warnf("Failed to delete snapshot %s of a failed migration: %s", *snapshotID, err)
+	}
+}
@@ -0,0 +1,211 @@
+package instance
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMissingEBSVolumes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: map[string]string{"cluster": "test"}}
+
+	spec := instance.Spec{
+		Attachments: []instance.Attachment{
+			{Type: AttachmentEBSVolume, ID: "10.0.0.1"},
+			{Type: AttachmentEBSVolume, ID: "10.0.0.2"},
+		},
+	}
+	existing := &ec2.Volume{
+		VolumeId: aws.String("vol-1"),
+		Tags: []*ec2.Tag{
+			{Key: aws.String("cluster"), Value: aws.String("test")},
+			{Key: aws.String(VolumeTag), Value: aws.String("10.0.0.1")},
+		},
+	}
+	clientMock.EXPECT().DescribeVolumes(gomock.Any()).
+		Return(&ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{existing}}, nil).Times(2)
+
+	// Without the policy, a missing volume is an error.
+	_, err := pluginImpl.findEBSVolumeAttachments(spec, EBSAttachmentPolicy{})
+	require.Error(t, err)
+
+	policy := EBSAttachmentPolicy{
+		CreateMissing: true,
+		CreateVolumeInput: ec2.CreateVolumeInput{
+			Size:       aws.Int64(10),
+			VolumeType: aws.String("gp2"),
+			KmsKeyId:   aws.String("key"),
+		},
+	}
+	attachments, err := pluginImpl.findEBSVolumeAttachments(spec, policy)
+	require.NoError(t, err)
+	require.Equal(t, []ebsAttachment{{ID: "10.0.0.1", Volume: existing}, {ID: "10.0.0.2"}}, attachments)
+
+	created := &ec2.Volume{VolumeId: aws.String("vol-2")}
+	gomock.InOrder(
+		clientMock.EXPECT().CreateVolume(&ec2.CreateVolumeInput{
+			AvailabilityZone: aws.String("us-west-2a"),
+			Size:             aws.Int64(10),
+			VolumeType:       aws.String("gp2"),
+			KmsKeyId:         aws.String("key"),
+		}).Return(created, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).
+			Do(func(input *ec2.CreateTagsInput) {
+				tags := map[string]string{}
+				for _, tag := range input.Tags {
+					tags[*tag.Key] = *tag.Value
+				}
+				require.Equal(t, map[string]string{"cluster": "test", VolumeTag: "10.0.0.2"}, tags)
+			}).
+			Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{created.VolumeId}}).
+			Return(nil),
+	)
+
+	require.NoError(t, pluginImpl.createMissingEBSVolumes(attachments, "us-west-2a", policy))
+	require.Equal(t, created, attachments[1].Volume)
+}
+
+func TestMigrateEBSVolumeAcrossZones(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: map[string]string{"cluster": "test"}}
+
+	old := &ec2.Volume{
+		VolumeId:         aws.String("vol-old"),
+		AvailabilityZone: aws.String("us-west-2a"),
+		VolumeType:       aws.String("gp2"),
+		Tags: []*ec2.Tag{
+			{Key: aws.String(VolumeTag), Value: aws.String("10.0.0.1")},
+			{Key: aws.String("aws:cloudformation:stack-name"), Value: aws.String("stack")},
+		},
+	}
+	attachments := []ebsAttachment{{ID: "10.0.0.1", Volume: old}}
+
+	// Without the policy, a volume in another zone is an error.
+	require.Error(t, pluginImpl.migrateEBSVolumes(attachments, "us-west-2b", EBSAttachmentPolicy{}))
+
+	snapshotID := aws.String("snap-1")
+	migrated := &ec2.Volume{VolumeId: aws.String("vol-new"), AvailabilityZone: aws.String("us-west-2b")}
+	gomock.InOrder(
+		clientMock.EXPECT().CreateSnapshot(gomock.Any()).Return(&ec2.Snapshot{SnapshotId: snapshotID}, nil),
+		clientMock.EXPECT().WaitUntilSnapshotCompleted(&ec2.DescribeSnapshotsInput{SnapshotIds: []*string{snapshotID}}).
+			Return(nil),
+		clientMock.EXPECT().CreateVolume(&ec2.CreateVolumeInput{
+			AvailabilityZone: aws.String("us-west-2b"),
+			SnapshotId:       snapshotID,
+			VolumeType:       aws.String("gp2"),
+		}).Return(migrated, nil),
+		clientMock.EXPECT().CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{migrated.VolumeId},
+			Tags:      []*ec2.Tag{{Key: aws.String(VolumeTag), Value: aws.String("10.0.0.1")}},
+		}).Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{migrated.VolumeId}}).
+			Return(nil),
+		clientMock.EXPECT().DeleteTags(&ec2.DeleteTagsInput{
+			Resources: []*string{old.VolumeId},
+			Tags:      []*ec2.Tag{{Key: aws.String(VolumeTag)}},
+		}).Return(&ec2.DeleteTagsOutput{}, nil),
+		clientMock.EXPECT().CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{old.VolumeId},
+			Tags:      []*ec2.Tag{{Key: aws.String(RetiredVolumeTag), Value: aws.String("10.0.0.1")}},
+		}).Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: old.VolumeId}).
+			Return(&ec2.DeleteVolumeOutput{}, nil),
+	)
+
+	policy := EBSAttachmentPolicy{MigrateAcrossZones: true, RetireRetainPolicy: RetainPolicySnapshot}
+	require.NoError(t, pluginImpl.migrateEBSVolumes(attachments, "us-west-2b", policy))
+	require.Equal(t, migrated, attachments[0].Volume)
+
+	// Volumes in the zone of the instance are left alone.
+	require.NoError(t, pluginImpl.migrateEBSVolumes(attachments, "us-west-2b", policy))
+}
+
+func TestMigrateEBSVolumeFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: map[string]string{"cluster": "test"}}
+
+	old := &ec2.Volume{
+		VolumeId:         aws.String("vol-old"),
+		AvailabilityZone: aws.String("us-west-2a"),
+		Tags:             []*ec2.Tag{{Key: aws.String(VolumeTag), Value: aws.String("10.0.0.1")}},
+	}
+	attachments := []ebsAttachment{{ID: "10.0.0.1", Volume: old}}
+
+	// A migration failing before the new volume takes over deletes the new volume and the snapshot.
+	snapshotID := aws.String("snap-1")
+	migrated := &ec2.Volume{VolumeId: aws.String("vol-new"), AvailabilityZone: aws.String("us-west-2b")}
+	available := &ec2.DescribeVolumesInput{VolumeIds: []*string{migrated.VolumeId}}
+	gomock.InOrder(
+		clientMock.EXPECT().CreateSnapshot(gomock.Any()).Return(&ec2.Snapshot{SnapshotId: snapshotID}, nil),
+		clientMock.EXPECT().WaitUntilSnapshotCompleted(gomock.Any()).Return(nil),
+		clientMock.EXPECT().CreateVolume(gomock.Any()).Return(migrated, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().WaitUntilVolumeAvailable(available).Return(nil),
+		clientMock.EXPECT().DeleteTags(gomock.Any()).Return(nil, errors.New("request failed")),
+		clientMock.EXPECT().WaitUntilVolumeAvailable(available).Return(nil),
+		clientMock.EXPECT().DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: migrated.VolumeId}).
+			Return(&ec2.DeleteVolumeOutput{}, nil),
+		clientMock.EXPECT().DeleteSnapshot(&ec2.DeleteSnapshotInput{SnapshotId: snapshotID}).
+			Return(&ec2.DeleteSnapshotOutput{}, nil),
+	)
+
+	policy := EBSAttachmentPolicy{MigrateAcrossZones: true}
+	require.Error(t, pluginImpl.migrateEBSVolumes(attachments, "us-west-2b", policy))
+	require.Equal(t, old, attachments[0].Volume)
+}
+
+func TestAssignEBSDevices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: map[string]string{"cluster": "test"}}
+
+	names, err := AllocateDeviceNames([]string{"/dev/sdf", "xvdg", "/dev/xvdh1", "sdj"}, 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/dev/sdi", "/dev/sdk", "/dev/sdl"}, names)
+
+	_, err = AllocateDeviceNames(nil, 12)
+	require.Error(t, err)
+
+	clientMock.EXPECT().DescribeImages(&ec2.DescribeImagesInput{ImageIds: []*string{aws.String("ami-1")}}).
+		Return(&ec2.DescribeImagesOutput{Images: []*ec2.Image{{
+			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+				{DeviceName: aws.String("/dev/sda1")},
+				{DeviceName: aws.String("/dev/sdf")},
+			},
+		}}}, nil)
+
+	attachments := []ebsAttachment{
+		{ID: "a", Volume: &ec2.Volume{VolumeId: aws.String("vol-a")}},
+		{ID: "b", Volume: &ec2.Volume{VolumeId: aws.String("vol-b")}},
+		{ID: "c", Volume: &ec2.Volume{VolumeId: aws.String("vol-c")}},
+	}
+	request := CreateInstanceRequest{
+		RunInstancesInput: ec2.RunInstancesInput{ImageId: aws.String("ami-1")},
+		EBSAttachment:     EBSAttachmentPolicy{Devices: map[string]string{"b": "/dev/sdg"}},
+	}
+	require.NoError(t, pluginImpl.assignEBSDevices(attachments, request))
+	require.Equal(t, map[string]string{
+		DeviceTagPrefix + "vol-a": "/dev/sdh",
+		DeviceTagPrefix + "vol-b": "/dev/sdg",
+		DeviceTagPrefix + "vol-c": "/dev/sdi",
+	}, deviceTags(attachments))
+}
@@ -1,19 +1,23 @@
 package instance
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
@@ -25,32 +29,139 @@ const (
 
 	// AttachmentEBSVolume is the type name used in instance.Attachment
 	AttachmentEBSVolume = "ebs"
+
+	// DestroyModeTag is the AWS tag name recording the DestroyMode of an instance.
+	DestroyModeTag = "infrakit.destroy-mode"
+
+	// ReuseClaimTag is the AWS tag name claiming a stopped instance for a Provision restarting it, so that concurrent
+	// Provisions don't restart the same instance.  Its value starts with the Unix time of the claim.
+	ReuseClaimTag = "infrakit.reuse-claim"
+)
+
+// reuseClaimTTL is how long the claim of a stopped instance holds, so that an instance claimed by a plugin that
+// crashed before restarting it is reused eventually.
+var reuseClaimTTL = 5 * time.Minute
+
+// DestroyMode determines what Destroy does to an instance.
+type DestroyMode string
+
+const (
+	// DestroyModeTerminate terminates the instance.
+	DestroyModeTerminate = DestroyMode("terminate")
+
+	// DestroyModeStop stops the instance, keeping it for reuse by a later Provision.
+	DestroyModeStop = DestroyMode("stop")
+
+	// DestroyModeHibernate hibernates the instance where supported, and stops it otherwise.
+	DestroyModeHibernate = DestroyMode("hibernate")
+)
+
+var (
+	// liveInstanceStates are the states of instances that are members of a group.
+	liveInstanceStates = []string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}
+
+	// stoppedInstanceStates are the states of instances that can be restarted by Provision.
+	stoppedInstanceStates = []string{ec2.InstanceStateNameStopped}
 )
 
 type awsInstancePlugin struct {
-	client        ec2iface.EC2API
-	namespaceTags map[string]string
+	client           ec2iface.EC2API
+	namespaceTags    map[string]string
+	options          InstancePluginOptions
+	completer        *completer
+	capacityFailures *capacityFailures
+
+	// reuseLock serializes the reuse of stopped instances.
+	reuseLock *sync.Mutex
+
+	pendingPlacements *pendingPlacements
+
+	// call is the call whose operations the clients journal, if any.
+	call *audit.Call
 }
 
-type properties struct {
-	Region   string
-	Retries  int
-	Instance *types.Any
+// InstancePluginOptions are the options of the plugin that creates instances.
+type InstancePluginOptions struct {
+	// CompletionWorkers is the number of instances whose provisioning is completed in the background at a time.
+	// Provision waits for the completion itself if it is 0.
+	CompletionWorkers int
+
+	// CompletionTimeout bounds the wait for an instance to enter the running state.
+	CompletionTimeout time.Duration
+
+	// CapacityFailureTTL is how long a candidate instance type and placement without capacity is tried last.
+	CapacityFailureTTL time.Duration
+
+	// CheckTerminationProtection refuses to destroy instances whose DisableApiTermination attribute is set, whatever
+	// the destroy mode, instead of leaving it to TerminateInstances to fail.
+	CheckTerminationProtection bool
+
+	// Role is the ARN of the role the client assumes, if any.  It is reported in the VendorInfo.
+	Role string
+
+	// Region is the region of the client, if known.  It is returned by the region function of user data templates.
+	Region string
+
+	// ELB is the client registering instances with the LoadBalancerNames of their requests, in the region of the
+	// client.  Requests with LoadBalancerNames are refused without it.
+	ELB elbiface.ELBAPI
+}
+
+// DefaultInstancePluginOptions are the options of the plugin created by NewInstancePlugin.
+var DefaultInstancePluginOptions = InstancePluginOptions{
+	CompletionWorkers:  8,
+	CompletionTimeout:  10 * time.Minute,
+	CapacityFailureTTL: 10 * time.Minute,
 }
 
 // NewInstancePlugin creates a new plugin that creates instances in AWS EC2.
 func NewInstancePlugin(client ec2iface.EC2API, namespaceTags map[string]string) instance.Plugin {
-	return &awsInstancePlugin{client: client, namespaceTags: namespaceTags}
+	return NewInstancePluginWithOptions(client, namespaceTags, DefaultInstancePluginOptions)
+}
+
+// NewInstancePluginWithOptions creates a new plugin that creates instances in AWS EC2 with the given options.
+func NewInstancePluginWithOptions(
+	client ec2iface.EC2API,
+	namespaceTags map[string]string,
+	options InstancePluginOptions) instance.Plugin {
+
+	p := &awsInstancePlugin{
+		client:            client,
+		namespaceTags:     namespaceTags,
+		options:           options,
+		capacityFailures:  newCapacityFailures(options.CapacityFailureTTL),
+		reuseLock:         &sync.Mutex{},
+		pendingPlacements: newPendingPlacements(),
+	}
+	if options.CompletionWorkers > 0 {
+		p.completer = newCompleter(options.CompletionWorkers)
+	}
+	return p
+}
+
+// WithCall returns the plugin making its operations with the clients of the call.
+func (p awsInstancePlugin) WithCall(call *audit.Call) instance.Plugin {
+	return p.withCall(call)
+}
+
+func (p awsInstancePlugin) withCall(call *audit.Call) awsInstancePlugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	if p.options.ELB != nil {
+		p.options.ELB = call.Service(p.options.ELB).(elbiface.ELBAPI)
+	}
+	p.call = call
+	return p
 }
 
 func (p awsInstancePlugin) tagInstance(
 	instance *ec2.Instance,
 	systemTags map[string]string,
+	pluginTags map[string]string,
 	userTags map[string]string) error {
 
 	ec2Tags := []*ec2.Tag{}
 
-	keys, allTags := mergeTags(userTags, systemTags, p.namespaceTags)
+	keys, allTags := mergeTags(userTags, systemTags, pluginTags, p.namespaceTags)
 
 	for _, k := range keys {
 		key := k
@@ -63,16 +174,80 @@ func (p awsInstancePlugin) tagInstance(
 
 // CreateInstanceRequest is the concrete provision request type.
 type CreateInstanceRequest struct {
+	// Region is the region to create the instance in, if served by the plugin.  Defaults to the region of the plugin.
+	Region string
+
+	// Role is the ARN of the role to assume to create the instance, if served by the plugin.  Defaults to the role of
+	// the plugin.
+	Role string
+
 	Tags               map[string]string
 	RunInstancesInput  ec2.RunInstancesInput
 	AttachVolumeInputs []ec2.AttachVolumeInput
+
+	// UserDataParts are composed with RunInstancesInput.UserData and the flavor Init into a multipart user data.
+	UserDataParts []UserDataPart
+
+	// RenderUserData renders the user data as a template with a UserDataContext.  Otherwise, the user data is used
+	// as is, e.g. when it has literal {{ }} or was already rendered by the flavor.
+	RenderUserData bool
+
+	// DestroyMode is what Destroy does to the instance.  Defaults to DestroyModeTerminate.  When the instance
+	// is stopped or hibernated, Provision restarts a matching stopped instance instead of launching a new one.
+	DestroyMode DestroyMode
+
+	// LogicalIDStrategy is how the logical ID of the instance is realized.  Defaults to LogicalIDPrivateIP.
+	LogicalIDStrategy LogicalIDStrategy
+
+	// EBSAttachment configures the volumes of the ebs attachments of the instance.
+	EBSAttachment EBSAttachmentPolicy
+
+	// InstanceTypes are the candidate instance types, in order of preference.  The next one is tried when there is
+	// no capacity for an instance type.  Defaults to the InstanceType of RunInstancesInput.
+	InstanceTypes []string
+
+	// Placements are the candidate placements, in order of preference.  The next one is tried when there is no
+	// capacity in a placement.  Defaults to the placement of RunInstancesInput.
+	Placements []Placement
+
+	// LoadBalancerNames are the classic load balancers to register the instance with, once it is running.
+	LoadBalancerNames []string
+
+	// Subnets are the subnets to spread the instances of the group across.  Each instance is placed in the
+	// availability zone with the fewest live instances of the group.  Cannot be used with Placements.
+	Subnets []string
+}
+
+func (r CreateInstanceRequest) validate() error {
+	if !validDestroyMode(r.DestroyMode) {
+		return newError(ErrorClassInvalidSpec, "Invalid destroy mode: %s", r.DestroyMode)
+	}
+	if !validLogicalIDStrategy(r.LogicalIDStrategy) {
+		return newError(ErrorClassInvalidSpec, "Invalid logical ID strategy: %s", r.LogicalIDStrategy)
+	}
+	if err := r.validateCandidates(); err != nil {
+		return err
+	}
+	return r.EBSAttachment.validate()
+}
+
+func validDestroyMode(mode DestroyMode) bool {
+	switch mode {
+	case "", DestroyModeTerminate, DestroyModeStop, DestroyModeHibernate:
+		return true
+	}
+	return false
 }
 
 // VendorInfo returns a vendor specific name and version
 func (p awsInstancePlugin) VendorInfo() *spi.VendorInfo {
+	name := "infrakit-instance-aws"
+	if p.options.Role != "" {
+		name = fmt.Sprintf("%s (%s)", name, p.options.Role)
+	}
 	return &spi.VendorInfo{
 		InterfaceSpec: spi.InterfaceSpec{
-			Name:    "infrakit-instance-aws",
+			Name:    name,
 			Version: "0.3.0",
 		},
 		URL: "https://github.com/docker/infrakit.aws",
@@ -107,8 +282,37 @@ func (p awsInstancePlugin) ExampleProperties() *types.Any {
 
 // Validate performs local checks to determine if the request is valid.
 func (p awsInstancePlugin) Validate(req *types.Any) error {
-	// TODO(wfarner): Implement
-	return nil
+	request := CreateInstanceRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
+	return request.validate()
+}
+
+// inNamespace returns true if the instance has the namespace tags.
+func (p awsInstancePlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the instance by the ProtectedTag, or by its DisableApiTermination attribute
+// if the plugin checks it.
+func (p awsInstancePlugin) protection(id instance.ID) (string, error) {
+	protection, err := ec2Protection(p.client, id)
+	if err != nil || protection != "" || !p.options.CheckTerminationProtection {
+		return protection, err
+	}
+
+	output, err := p.client.DescribeInstanceAttribute(&ec2.DescribeInstanceAttributeInput{
+		Attribute:  aws.String(ec2.InstanceAttributeNameDisableApiTermination),
+		InstanceId: aws.String(string(id)),
+	})
+	if err != nil {
+		return "", apiError("DescribeInstanceAttribute", err)
+	}
+	if output.DisableApiTermination != nil && aws.BoolValue(output.DisableApiTermination.Value) {
+		return "termination protection", nil
+	}
+	return "", nil
 }
 
 // Label implements labeling the instances.
@@ -168,21 +372,23 @@ func mergeTags(tagMaps ...map[string]string) ([]string, map[string]string) {
 	return keys, tags
 }
 
-func (p awsInstancePlugin) findEBSVolumeAttachments(spec instance.Spec) ([]*string, error) {
-	found := []*string{}
+// findEBSVolumeAttachments looks up the volumes tagged with the IDs of the ebs attachments of the spec, in the order
+// of the attachments.  Missing volumes have a nil Volume, and are an error unless the policy creates them.
+func (p awsInstancePlugin) findEBSVolumeAttachments(spec instance.Spec, policy EBSAttachmentPolicy) ([]ebsAttachment, error) {
+	attachments := []ebsAttachment{}
 
 	// for querying volumes
 	filterValues := []*string{}
 
 	for _, attachment := range spec.Attachments {
 		if attachment.Type == AttachmentEBSVolume {
-			s := attachment.ID
-			filterValues = append(filterValues, &s)
+			attachments = append(attachments, ebsAttachment{ID: attachment.ID})
+			filterValues = append(filterValues, aws.String(attachment.ID))
 		}
 	}
 
 	if len(filterValues) == 0 {
-		return found, nil // nothing
+		return attachments, nil // nothing
 	}
 
 	volumes, err := p.client.DescribeVolumes(&ec2.DescribeVolumesInput{
@@ -197,21 +403,28 @@ func (p awsInstancePlugin) findEBSVolumeAttachments(spec instance.Spec) ([]*stri
 		return nil, errors.New("Failed while looking up volume")
 	}
 
-	for _, volume := range volumes.Volumes {
-		if p.hasNamespaceTags(volume.Tags) {
-			found = append(found, volume.VolumeId)
+	missing := []string{}
+	for i, attachment := range attachments {
+		for _, volume := range volumes.Volumes {
+			if !p.hasNamespaceTags(volume.Tags) {
+				continue
+			}
+			for _, tag := range volume.Tags {
+				if aws.StringValue(tag.Key) == VolumeTag && aws.StringValue(tag.Value) == attachment.ID {
+					attachments[i].Volume = volume
+				}
+			}
+		}
+		if attachments[i].Volume == nil {
+			missing = append(missing, attachment.ID)
 		}
 	}
 
-	// TODO(chungers) -- not dealing with if only a subset is found.
-	if len(found) != len(spec.Attachments) {
-		return nil, fmt.Errorf(
-			"Not all required volumes found to attach.  Wanted %s, found %s",
-			spec.Attachments,
-			volumes.Volumes)
+	if len(missing) > 0 && !policy.CreateMissing {
+		return nil, newError(ErrorClassNotFound, "Not all required volumes found to attach.  Missing %v", missing)
 	}
 
-	return found, nil
+	return attachments, nil
 }
 
 func (p awsInstancePlugin) hasNamespaceTags(tags []*ec2.Tag) bool {
@@ -236,36 +449,54 @@ func (p awsInstancePlugin) hasNamespaceTags(tags []*ec2.Tag) bool {
 func (p awsInstancePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 
 	if spec.Properties == nil {
-		return nil, errors.New("Properties must be set")
+		return nil, newError(ErrorClassInvalidSpec, "Properties must be set")
 	}
 
 	request := CreateInstanceRequest{}
 	err := json.Unmarshal(*spec.Properties, &request)
 	if err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
+	}
+	if err := request.validate(); err != nil {
+		return nil, err
+	}
+	if len(request.LoadBalancerNames) > 0 && p.options.ELB == nil {
+		return nil, newError(ErrorClassInvalidSpec, "LoadBalancerNames are not supported by the plugin")
 	}
 
 	request.RunInstancesInput.MinCount = aws.Int64(1)
 	request.RunInstancesInput.MaxCount = aws.Int64(1)
 
+	if request.DestroyMode == DestroyModeStop || request.DestroyMode == DestroyModeHibernate {
+		id, err := p.restartStoppedInstance(spec, request)
+		if err != nil || id != nil {
+			return id, err
+		}
+	}
+
 	if spec.LogicalID != nil {
-		if len(request.RunInstancesInput.NetworkInterfaces) > 0 {
-			request.RunInstancesInput.NetworkInterfaces[0].PrivateIpAddress = (*string)(spec.LogicalID)
-		} else {
-			request.RunInstancesInput.PrivateIpAddress = (*string)(spec.LogicalID)
+		if err := p.applyLogicalID(&request, *spec.LogicalID); err != nil {
+			return nil, err
 		}
 	}
 
-	if spec.Init != "" {
-		request.RunInstancesInput.UserData = aws.String(spec.Init)
+	// work with attachments
+	attachments, err := p.findEBSVolumeAttachments(spec, request.EBSAttachment)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.assignEBSDevices(attachments, request); err != nil {
+		return nil, err
 	}
 
-	if request.RunInstancesInput.UserData != nil {
-		request.RunInstancesInput.UserData = aws.String(
-			base64.StdEncoding.EncodeToString([]byte(*request.RunInstancesInput.UserData)))
+	pending, err := p.balanceSubnets(spec, &request, attachments)
+	if err != nil {
+		return nil, err
 	}
+	var launched *ec2.Instance
+	defer func() { p.pendingPlacements.launched(pending, launched) }()
 
-	reservation, err := p.client.RunInstances(&request.RunInstancesInput)
+	reservation, request, err := p.runInstance(spec, request, attachments)
 	if err != nil {
 		return nil, err
 	}
@@ -274,68 +505,88 @@ func (p awsInstancePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 		return nil, errors.New("Unexpected AWS API response")
 	}
 	ec2Instance := reservation.Instances[0]
+	launched = ec2Instance
 
 	id := (*instance.ID)(ec2Instance.InstanceId)
 
-	err = p.tagInstance(ec2Instance, spec.Tags, request.Tags)
+	_, pluginTags := mergeTags(request.pluginTags(spec), request.candidateTags())
+	if p.completer != nil && needsCompletion(spec, request, attachments) {
+		pluginTags[ProvisionStatusTag] = ProvisionStatusPending
+	}
+	err = p.tagInstance(ec2Instance, spec.Tags, pluginTags, request.Tags)
 	if err != nil {
 		return id, err
 	}
 
-	// work with attachments
-	awsVolumeIDs, err := p.findEBSVolumeAttachments(spec)
-	if err != nil {
-		return id, err
+	if !needsCompletion(spec, request, attachments) {
+		return id, nil
 	}
 
-	if len(awsVolumeIDs) > 0 {
-		log.Infof("Waiting for instance %s to enter running state before attaching volume", *id)
-		for {
-			time.Sleep(10 * time.Second)
+	if p.completer == nil {
+		return id, p.completeProvision(ec2Instance, spec, request, attachments)
+	}
 
-			inst, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
-				InstanceIds: []*string{ec2Instance.InstanceId},
-			})
-			if err == nil {
-				if *inst.Reservations[0].Instances[0].State.Name == ec2.InstanceStateNameRunning {
-					break
-				}
-			} else if awsErr, ok := err.(awserr.Error); ok {
-				if awsErr.Code() == "InvalidInstanceID.NotFound" {
-					return id, nil
-				}
-			}
+	// The completion outlives the call of Provision, so it is journaled as a call of its own.
+	job := p.withCall(p.call.Begin("CompleteProvision", string(*id), spec.Tags))
+	p.completer.schedule(*id, job.client, func() error {
+		return job.completeProvision(ec2Instance, spec, request, attachments)
+	})
+	return id, nil
+}
 
+// waitForRunning waits for the instance to enter the running state, until the timeout.  Returns an error unless the
+// instance runs.
+func (p awsInstancePlugin) waitForRunning(instanceID *string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(runningPollInterval)
+
+		// A new instance may not be described yet, or be described as not found, so it is polled again until the
+		// deadline.
+		inst, err := p.client.DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{instanceID},
+		})
+		if err == nil && len(inst.Reservations) > 0 && len(inst.Reservations[0].Instances) > 0 {
+			state := ""
+			if inst.Reservations[0].Instances[0].State != nil {
+				state = aws.StringValue(inst.Reservations[0].Instances[0].State.Name)
+			}
+			switch state {
+			case ec2.InstanceStateNameRunning:
+				return nil
+			case ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameTerminated:
+				return fmt.Errorf("Instance %s is %s instead of running", *instanceID, state)
+			}
+		} else if err != nil && !isNotFound(err) {
+			log.Warnf("Failed to describe instance %s: %s", *instanceID, err)
 		}
 
-		for _, awsVolumeID := range awsVolumeIDs {
-			_, err := p.client.AttachVolume(&ec2.AttachVolumeInput{
-				InstanceId: ec2Instance.InstanceId,
-				VolumeId:   awsVolumeID,
-				Device:     aws.String("/dev/sdf"),
-			})
-			if err != nil {
-				return id, err
-			}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out after %s waiting for instance %s to run", timeout, *instanceID)
 		}
 	}
+}
 
-	for _, attachVolumeInput := range request.AttachVolumeInputs {
-		attachVolumeInput.InstanceId = ec2Instance.InstanceId
-		err := retry(30*time.Second, 500*time.Millisecond, func() error {
-			_, err := p.client.AttachVolume(&attachVolumeInput)
-			return err
-		})
-		if err != nil {
-			return id, fmt.Errorf("AttachVolume failed: %s", err)
+// Destroy terminates an existing instance, or stops it if the instance was provisioned with a stop or hibernate
+// DestroyMode.
+func (p awsInstancePlugin) Destroy(id instance.ID) error {
+	ec2Instance, err := p.describeInstance(id)
+	if err != nil {
+		return err
+	}
+
+	mode := DestroyModeTerminate
+	for _, tag := range ec2Instance.Tags {
+		if aws.StringValue(tag.Key) == DestroyModeTag {
+			mode = DestroyMode(aws.StringValue(tag.Value))
 		}
 	}
 
-	return id, nil
-}
+	switch mode {
+	case DestroyModeStop, DestroyModeHibernate:
+		return p.stopInstance(id, mode == DestroyModeHibernate)
+	}
 
-// Destroy terminates an existing instance.
-func (p awsInstancePlugin) Destroy(id instance.ID) error {
 	result, err := p.client.TerminateInstances(&ec2.TerminateInstancesInput{
 		InstanceIds: []*string{aws.String(string(id))}})
 
@@ -344,21 +595,202 @@ func (p awsInstancePlugin) Destroy(id instance.ID) error {
 	}
 
 	if len(result.TerminatingInstances) != 1 {
-		return errors.New("No matching instance")
+		return newError(ErrorClassNotFound, "No matching instance")
+	}
+
+	return nil
+}
+
+func (p awsInstancePlugin) stopInstance(id instance.ID, hibernate bool) error {
+	// Release the claim of the Provision which restarted the instance, if any, so that it can be reused again.
+	if err := p.releaseStoppedInstance(id); err != nil {
+		return err
+	}
+
+	input := &ec2.StopInstancesInput{InstanceIds: []*string{aws.String(string(id))}}
+
+	if hibernate {
+		req, result := p.client.StopInstancesRequest(input)
+		req.Handlers.Build.PushBack(hibernateHandler)
+		err := req.Send()
+		if err == nil {
+			if len(result.StoppingInstances) != 1 {
+				return newError(ErrorClassNotFound, "No matching instance")
+			}
+			return nil
+		}
+		log.Warnf("Hibernating instance %s failed, stopping instead: %s", id, err)
+	}
+
+	result, err := p.client.StopInstances(input)
+	if err != nil {
+		return err
+	}
+
+	if len(result.StoppingInstances) != 1 {
+		return newError(ErrorClassNotFound, "No matching instance")
+	}
+
+	return nil
+}
+
+// hibernateHandler adds the Hibernate parameter to a StopInstances request, which the vendored SDK does not model.
+func hibernateHandler(r *request.Request) {
+	if r.Error != nil {
+		return
+	}
+	body, err := ioutil.ReadAll(r.GetBody())
+	if err != nil {
+		r.Error = err
+		return
+	}
+	r.SetStringBody(string(body) + "&Hibernate=true")
+}
+
+// restartStoppedInstance starts a stopped instance matching the spec and request, if there is one.  Instances
+// with volume attachments are never reused since their volumes remain attached while stopped.
+func (p awsInstancePlugin) restartStoppedInstance(spec instance.Spec, request CreateInstanceRequest) (*instance.ID, error) {
+	if len(spec.Attachments) > 0 || len(request.AttachVolumeInputs) > 0 {
+		return nil, nil
+	}
+
+	p.reuseLock.Lock()
+	defer p.reuseLock.Unlock()
+
+	_, tags := mergeTags(spec.Tags, request.Tags)
+	result, err := p.client.DescribeInstances(describeGroupRequest(p.namespaceTags, tags, stoppedInstanceStates, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	run := request.RunInstancesInput
+	for _, reservation := range result.Reservations {
+		for _, ec2Instance := range reservation.Instances {
+			if run.ImageId != nil && aws.StringValue(run.ImageId) != aws.StringValue(ec2Instance.ImageId) {
+				continue
+			}
+			if !request.hasInstanceType(aws.StringValue(ec2Instance.InstanceType)) {
+				continue
+			}
+			if logicalID := logicalIDOf(ec2Instance); spec.LogicalID != nil &&
+				(logicalID == nil || *logicalID != *spec.LogicalID) {
+				continue
+			}
+
+			id := (*instance.ID)(ec2Instance.InstanceId)
+
+			if claimed, err := p.claimStoppedInstance(ec2Instance); err != nil {
+				log.Warnf("Failed to claim stopped instance %s: %s", *id, err)
+				continue
+			} else if !claimed {
+				continue
+			}
+
+			if err := p.updateUserData(spec, request, ec2Instance.InstanceId); err != nil {
+				p.releaseStoppedInstance(*id)
+				return nil, err
+			}
+
+			log.Infof("Restarting stopped instance %s", *id)
+			if _, err := p.client.StartInstances(&ec2.StartInstancesInput{
+				InstanceIds: []*string{ec2Instance.InstanceId},
+			}); err != nil {
+				log.Warnf("Failed to restart stopped instance %s: %s", *id, err)
+				p.releaseStoppedInstance(*id)
+				continue
+			}
+
+			return id, p.tagInstance(ec2Instance, spec.Tags, request.pluginTags(spec), request.Tags)
+		}
 	}
 
+	return nil, nil
+}
+
+// claimStoppedInstance claims the stopped instance with the ReuseClaimTag, unless another Provision already has and its
+// claim isn't stale, and returns true if the claim is still that of this Provision after tagging, and the instance is
+// still stopped.  The Provisions of the plugin claim instances one at a time, so they never claim the same instance;
+// since tags are eventually consistent, those of different plugin processes may.
+func (p awsInstancePlugin) claimStoppedInstance(ec2Instance *ec2.Instance) (bool, error) {
+	for _, tag := range ec2Instance.Tags {
+		if aws.StringValue(tag.Key) == ReuseClaimTag && !staleReuseClaim(aws.StringValue(tag.Value)) {
+			return false, nil
+		}
+	}
+
+	id := instance.ID(aws.StringValue(ec2Instance.InstanceId))
+	claim := fmt.Sprintf("%d-%s", time.Now().Unix(), randomString(16))
+	if err := ec2CreateTags(p.client, id, map[string]string{ReuseClaimTag: claim}); err != nil {
+		return false, apiError("CreateTags", err)
+	}
+
+	claimed, err := p.describeInstance(id)
+	if err != nil {
+		return false, err
+	}
+	if claimed.State == nil || aws.StringValue(claimed.State.Name) != ec2.InstanceStateNameStopped {
+		return false, nil
+	}
+	for _, tag := range claimed.Tags {
+		if aws.StringValue(tag.Key) == ReuseClaimTag {
+			return aws.StringValue(tag.Value) == claim, nil
+		}
+	}
+	return false, nil
+}
+
+// staleReuseClaim returns true if the claim is older than the reuseClaimTTL, or has no time.
+func staleReuseClaim(claim string) bool {
+	at, err := strconv.ParseInt(strings.SplitN(claim, "-", 2)[0], 10, 64)
+	return err != nil || time.Since(time.Unix(at, 0)) > reuseClaimTTL
+}
+
+// releaseStoppedInstance deletes the ReuseClaimTag of the instance, if any.
+func (p awsInstancePlugin) releaseStoppedInstance(id instance.ID) error {
+	_, err := p.client.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(string(id))},
+		Tags:      []*ec2.Tag{{Key: aws.String(ReuseClaimTag)}},
+	})
+	if err != nil {
+		return apiError("DeleteTags", err)
+	}
 	return nil
 }
 
-func describeGroupRequest(namespaceTags, tags map[string]string, nextToken *string) *ec2.DescribeInstancesInput {
+// updateUserData builds the user data of a stopped instance again, now that its instance ID is known.
+func (p awsInstancePlugin) updateUserData(spec instance.Spec, request CreateInstanceRequest, instanceID *string) error {
+	userData, err := p.buildUserData(spec, request, nil, instanceID)
+	if err != nil || userData == nil {
+		return err
+	}
+	_, err = p.client.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId: instanceID,
+		UserData:   &ec2.BlobAttributeValue{Value: userData},
+	})
+	if err != nil {
+		return apiError("ModifyInstanceAttribute", err)
+	}
+	return nil
+}
+
+// pluginTags are the tags recording the options of the request that the plugin needs after Provision.
+func (r CreateInstanceRequest) pluginTags(spec instance.Spec) map[string]string {
+	tags := map[string]string{}
+	if r.DestroyMode != "" {
+		tags[DestroyModeTag] = string(r.DestroyMode)
+	}
+	if spec.LogicalID != nil {
+		tags[LogicalIDTag] = string(*spec.LogicalID)
+	}
+	return tags
+}
+
+func describeGroupRequest(namespaceTags, tags map[string]string, states []string, nextToken *string) *ec2.DescribeInstancesInput {
 
 	filters := []*ec2.Filter{
 		{
-			Name: aws.String("instance-state-name"),
-			Values: []*string{
-				aws.String("pending"),
-				aws.String("running"),
-			},
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice(states),
 		},
 	}
 
@@ -376,7 +808,7 @@ func describeGroupRequest(namespaceTags, tags map[string]string, nextToken *stri
 
 func (p awsInstancePlugin) describeInstances(tags map[string]string, properties bool, nextToken *string) ([]instance.Description, error) {
 
-	result, err := p.client.DescribeInstances(describeGroupRequest(p.namespaceTags, tags, nextToken))
+	result, err := p.client.DescribeInstances(describeGroupRequest(p.namespaceTags, tags, liveInstanceStates, nextToken))
 	if err != nil {
 		return nil, err
 	}
@@ -403,7 +835,7 @@ func (p awsInstancePlugin) describeInstances(tags map[string]string, properties
 			}
 			descriptions = append(descriptions, instance.Description{
 				ID:         instance.ID(*ec2Instance.InstanceId),
-				LogicalID:  (*instance.LogicalID)(ec2Instance.PrivateIpAddress),
+				LogicalID:  logicalIDOf(ec2Instance),
 				Tags:       tags,
 				Properties: status,
 			})
@@ -436,7 +868,7 @@ func (p awsInstancePlugin) describeInstance(id instance.ID) (*ec2.Instance, erro
 		return nil, err
 	}
 	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return nil, errors.New("Instance not found")
+		return nil, newError(ErrorClassNotFound, "Instance %s not found", id)
 	}
 
 	return result.Reservations[0].Instances[0], nil
@@ -1,12 +1,15 @@
 package instance
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/fake"
 	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
@@ -52,6 +55,8 @@ func TestInstanceLifecycle(t *testing.T) {
 
 	// Destroy the instance.
 
+	clientMock.EXPECT().DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{&instanceID}}).
+		Return(describeInstancesResponse([][]string{{instanceID}}, tags, nil), nil)
 	clientMock.EXPECT().TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{&instanceID}}).
 		Return(&ec2.TerminateInstancesOutput{
 			TerminatingInstances: []*ec2.InstanceStateChange{{InstanceId: &instanceID}}},
@@ -60,6 +65,40 @@ func TestInstanceLifecycle(t *testing.T) {
 	require.NoError(t, pluginImpl.Destroy(instance.ID(instanceID)))
 }
 
+func TestProvisionRendersUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	instanceID := "test-id"
+	logicalID := instance.LogicalID("10.0.0.5")
+
+	var userData string
+	clientMock.EXPECT().RunInstances(gomock.Any()).
+		Do(func(input *ec2.RunInstancesInput) {
+			decoded, err := base64.StdEncoding.DecodeString(*input.UserData)
+			require.NoError(t, err)
+			userData = string(decoded)
+		}).
+		Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}, nil)
+	clientMock.EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil)
+
+	options := DefaultInstancePluginOptions
+	options.Region = "us-west-2"
+	pluginImpl := NewInstancePluginWithOptions(clientMock, testNamespace, options)
+	_, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(`{
+			"RunInstancesInput": {"Placement": {"AvailabilityZone": "us-west-2-lax-1b"}},
+			"RenderUserData": true
+		}`),
+		Tags:      tags,
+		LogicalID: &logicalID,
+		Init:      `{{ .LogicalID }} {{ .AvailabilityZone }} {{ region }} {{ index .Tags "group" }} {{ .Namespace.cluster }}`,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.5 us-west-2-lax-1b us-west-2 workers test", userData)
+}
+
 func TestCreateInstanceError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -84,6 +123,8 @@ func TestDestroyInstanceError(t *testing.T) {
 	instanceID := "test-id"
 
 	runError := errors.New("request failed")
+	clientMock.EXPECT().DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{&instanceID}}).
+		Return(describeInstancesResponse([][]string{{instanceID}}, tags, nil), nil)
 	clientMock.EXPECT().TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{&instanceID}}).
 		Return(nil, runError)
 
@@ -91,6 +132,105 @@ func TestDestroyInstanceError(t *testing.T) {
 	require.Error(t, pluginImpl.Destroy(instance.ID(instanceID)))
 }
 
+func stopModeSpec(mode DestroyMode) instance.Spec {
+	return instance.Spec{
+		Properties: types.AnyString(fmt.Sprintf(`{
+			"DestroyMode": "%s",
+			"RunInstancesInput": {"ImageId": "%s", "InstanceType": "t2.micro"}
+		}`, mode, fake.DefaultImageID)),
+		Tags: tags,
+	}
+}
+
+func instanceState(t *testing.T, client *fake.EC2, id instance.ID) string {
+	result, err := client.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{(*string)(&id)}})
+	require.NoError(t, err)
+	return aws.StringValue(result.Reservations[0].Instances[0].State.Name)
+}
+
+func TestStopModeDestroyAndRestart(t *testing.T) {
+	for _, mode := range []DestroyMode{DestroyModeStop, DestroyModeHibernate} {
+		client := fake.NewEC2()
+		options := DefaultInstancePluginOptions
+		options.CompletionWorkers = 0
+		pluginImpl := NewInstancePluginWithOptions(client, testNamespace, options)
+		spec := stopModeSpec(mode)
+
+		id, err := pluginImpl.Provision(spec)
+		require.NoError(t, err)
+
+		// Destroy stops rather than terminates the instance.
+		require.NoError(t, pluginImpl.Destroy(*id))
+		require.Equal(t, ec2.InstanceStateNameStopped, instanceState(t, client, *id))
+
+		// Provision restarts the stopped instance rather than running a new one, and runs a new one once there are
+		// no stopped instances left.
+		restarted, err := pluginImpl.Provision(spec)
+		require.NoError(t, err)
+		require.Equal(t, *id, *restarted)
+		require.Equal(t, ec2.InstanceStateNameRunning, instanceState(t, client, *id))
+
+		other, err := pluginImpl.Provision(spec)
+		require.NoError(t, err)
+		require.NotEqual(t, *id, *other)
+
+		// The instance can be stopped and reused again.
+		require.NoError(t, pluginImpl.Destroy(*id))
+		restarted, err = pluginImpl.Provision(spec)
+		require.NoError(t, err)
+		require.Equal(t, *id, *restarted)
+	}
+}
+
+func TestRestartStoppedInstanceOnce(t *testing.T) {
+	client := fake.NewEC2()
+	options := DefaultInstancePluginOptions
+	options.CompletionWorkers = 0
+	pluginImpl := NewInstancePluginWithOptions(client, testNamespace, options)
+	spec := stopModeSpec(DestroyModeStop)
+
+	stopped, err := pluginImpl.Provision(spec)
+	require.NoError(t, err)
+	require.NoError(t, pluginImpl.Destroy(*stopped))
+
+	// Concurrent Provisions never restart the same instance.
+	type provisioned struct {
+		id  *instance.ID
+		err error
+	}
+	results := make(chan provisioned, 4)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			id, err := pluginImpl.Provision(spec)
+			results <- provisioned{id: id, err: err}
+		}()
+	}
+	ids := map[instance.ID]bool{}
+	for i := 0; i < cap(results); i++ {
+		result := <-results
+		require.NoError(t, result.err)
+		ids[*result.id] = true
+	}
+	require.Len(t, ids, cap(results))
+	require.True(t, ids[*stopped])
+
+	// Stopped instances claimed by another Provision, e.g. that of another plugin, are left to it.
+	require.NoError(t, pluginImpl.Destroy(*stopped))
+	claim := fmt.Sprintf("%d-other", time.Now().Unix())
+	require.NoError(t, ec2CreateTags(client, *stopped, map[string]string{ReuseClaimTag: claim}))
+	id, err := pluginImpl.Provision(spec)
+	require.NoError(t, err)
+	require.NotEqual(t, *stopped, *id)
+	require.Equal(t, ec2.InstanceStateNameStopped, instanceState(t, client, *stopped))
+
+	// Unless the claim is stale, e.g. because the other plugin crashed before restarting the instance.
+	claim = fmt.Sprintf("%d-other", time.Now().Add(-2*reuseClaimTTL).Unix())
+	require.NoError(t, ec2CreateTags(client, *stopped, map[string]string{ReuseClaimTag: claim}))
+	id, err = pluginImpl.Provision(spec)
+	require.NoError(t, err)
+	require.Equal(t, *stopped, *id)
+}
+
 func describeInstancesResponse(
 	instanceIds [][]string,
 	tags map[string]string,
@@ -122,7 +262,7 @@ func TestDescribeInstancesRequest(t *testing.T) {
 	defer ctrl.Finish()
 
 	var nextToken *string
-	request := describeGroupRequest(testNamespace, tags, nextToken)
+	request := describeGroupRequest(testNamespace, tags, liveInstanceStates, nextToken)
 
 	require.Equal(t, nextToken, request.NextToken)
 
@@ -145,9 +285,11 @@ func TestDescribeInstancesRequest(t *testing.T) {
 	for key, value := range testNamespace {
 		requireFilter(fmt.Sprintf("tag:%s", key), value)
 	}
+	requireFilter("instance-state-name", "pending")
+	requireFilter("instance-state-name", "running")
 
 	nextToken = aws.String("page-2")
-	request = describeGroupRequest(testNamespace, tags, nextToken)
+	request = describeGroupRequest(testNamespace, tags, liveInstanceStates, nextToken)
 	require.Equal(t, nextToken, request.NextToken)
 }
 
@@ -160,12 +302,12 @@ func TestListGroup(t *testing.T) {
 
 	// Split instance IDs across multiple reservations and request pages.
 	gomock.InOrder(
-		clientMock.EXPECT().DescribeInstances(describeGroupRequest(testNamespace, tags, nil)).
+		clientMock.EXPECT().DescribeInstances(describeGroupRequest(testNamespace, tags, liveInstanceStates, nil)).
 			Return(describeInstancesResponse([][]string{
 				{"a", "b", "c"},
 				{"d", "e"},
 			}, tags, &page2Token), nil),
-		clientMock.EXPECT().DescribeInstances(describeGroupRequest(testNamespace, tags, &page2Token)).
+		clientMock.EXPECT().DescribeInstances(describeGroupRequest(testNamespace, tags, liveInstanceStates, &page2Token)).
 			Return(describeInstancesResponse([][]string{{"f", "g"}}, tags, nil), nil),
 	)
 
@@ -222,3 +364,51 @@ var inputJSON = types.AnyString(`{
     }
 }
 `)
+
+func TestLogicalIDStrategies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	pluginImpl := awsInstancePlugin{client: clientMock, namespaceTags: map[string]string{"cluster": "test"}}
+	logicalID := instance.LogicalID("manager-1")
+
+	// The network interface tagged with the logical ID replaces the network settings of the request.
+	clientMock.EXPECT().DescribeNetworkInterfaces(gomock.Any()).
+		Do(func(input *ec2.DescribeNetworkInterfacesInput) {
+			filters := map[string]string{}
+			for _, filter := range input.Filters {
+				filters[*filter.Name] = *filter.Values[0]
+			}
+			require.Equal(t, map[string]string{"tag:cluster": "test", "tag:" + LogicalIDTag: "manager-1"}, filters)
+		}).
+		Return(&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{{NetworkInterfaceId: aws.String("eni-1")}},
+		}, nil)
+
+	request := CreateInstanceRequest{
+		LogicalIDStrategy: LogicalIDNetworkInterface,
+		RunInstancesInput: ec2.RunInstancesInput{
+			SubnetId:         aws.String("subnet-1"),
+			SecurityGroupIds: []*string{aws.String("sg-1")},
+		},
+	}
+	require.NoError(t, pluginImpl.applyLogicalID(&request, logicalID))
+	require.Equal(t, ec2.RunInstancesInput{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{
+			{DeviceIndex: aws.Int64(0), NetworkInterfaceId: aws.String("eni-1")},
+		},
+	}, request.RunInstancesInput)
+
+	// The tag strategy leaves the request alone.
+	request = CreateInstanceRequest{LogicalIDStrategy: LogicalIDTagOnly}
+	require.NoError(t, pluginImpl.applyLogicalID(&request, logicalID))
+	require.Nil(t, request.RunInstancesInput.PrivateIpAddress)
+
+	// Describe reports the logical ID recorded in the tag over the private IP address.
+	require.Equal(t, logicalID, *logicalIDOf(&ec2.Instance{
+		PrivateIpAddress: aws.String("10.0.0.1"),
+		Tags:             []*ec2.Tag{{Key: aws.String(LogicalIDTag), Value: aws.String("manager-1")}},
+	}))
+	require.Equal(t, instance.LogicalID("10.0.0.1"), *logicalIDOf(&ec2.Instance{PrivateIpAddress: aws.String("10.0.0.1")}))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -27,32 +28,52 @@ type createInternetGatewayRequest struct {
 	Tags                       map[string]string
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsInternetGatewayPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
+}
+
 func (p awsInternetGatewayPlugin) Validate(req *types.Any) error {
+	request := createInternetGatewayRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsInternetGatewayPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createInternetGatewayRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	output, err := p.client.CreateInternetGateway(&request.CreateInternetGatewayInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateInternetGateway failed: %s", err)
+		return nil, apiError("CreateInternetGateway", err)
 	}
 	id := instance.ID(*output.InternetGateway.InternetGatewayId)
 
 	if request.AttachInternetGatewayInput != nil {
 		request.AttachInternetGatewayInput.InternetGatewayId = output.InternetGateway.InternetGatewayId
 		if _, err := p.client.AttachInternetGateway(request.AttachInternetGatewayInput); err != nil {
-			return &id, fmt.Errorf("AttachInternetGateway failed: %s", err)
+			return &id, apiError("AttachInternetGateway", err)
 		}
 	}
 
 	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
 }
 
+// inNamespace returns true if the internet gateway has the namespace tags.
+func (p awsInternetGatewayPlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the internet gateway by the ProtectedTag, if any.
+func (p awsInternetGatewayPlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
+}
+
 func (p awsInternetGatewayPlugin) Label(id instance.ID, labels map[string]string) error {
 	return ec2CreateTags(p.client, id, labels)
 }
@@ -60,7 +81,7 @@ func (p awsInternetGatewayPlugin) Label(id instance.ID, labels map[string]string
 func (p awsInternetGatewayPlugin) Destroy(id instance.ID) error {
 	output, err := p.client.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{InternetGatewayIds: []*string{(*string)(&id)}})
 	if err != nil {
-		return fmt.Errorf("DescribeInternetGateways failed: %s", err)
+		return apiError("DescribeInternetGateways", err)
 	}
 
 	if len(output.InternetGateways) > 0 {
@@ -70,13 +91,13 @@ func (p awsInternetGatewayPlugin) Destroy(id instance.ID) error {
 				VpcId:             a.VpcId,
 			})
 			if err != nil {
-				return fmt.Errorf("DetachInternetGateway failed: %s", err)
+				return apiError("DetachInternetGateway", err)
 			}
 		}
 	}
 
 	if _, err := p.client.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{InternetGatewayId: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteInternetGateway failed: %s", err)
+		return apiError("DeleteInternetGateway", err)
 	}
 	return nil
 }
@@ -94,7 +115,7 @@ func (p awsInternetGatewayPlugin) DescribeInstances(labels map[string]string, pr
 
 	output, err := p.client.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeInternetGateways failed: %s", err)
+		return []instance.Description{}, apiError("DescribeInternetGateways", err)
 	}
 
 	descriptions := []instance.Description{}
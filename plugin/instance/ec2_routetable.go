@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -28,39 +29,59 @@ type createRouteTableRequest struct {
 	Tags                      map[string]string
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsRouteTablePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
+}
+
 func (p awsRouteTablePlugin) Validate(req *types.Any) error {
+	request := createRouteTableRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsRouteTablePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createRouteTableRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	output, err := p.client.CreateRouteTable(&request.CreateRouteTableInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateRouteTable failed: %s", err)
+		return nil, apiError("CreateRouteTable", err)
 	}
 	id := instance.ID(*output.RouteTable.RouteTableId)
 
 	for _, input := range request.AssociateRouteTableInputs {
 		input.RouteTableId = output.RouteTable.RouteTableId
 		if _, err := p.client.AssociateRouteTable(&input); err != nil {
-			return &id, fmt.Errorf("AssociateRouteTable failed: %s", err)
+			return &id, apiError("AssociateRouteTable", err)
 		}
 	}
 
 	for _, input := range request.CreateRouteInputs {
 		input.RouteTableId = output.RouteTable.RouteTableId
 		if _, err := p.client.CreateRoute(&input); err != nil {
-			return &id, fmt.Errorf("CreateRoute failed: %s", err)
+			return &id, apiError("CreateRoute", err)
 		}
 	}
 
 	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
 }
 
+// inNamespace returns true if the route table has the namespace tags.
+func (p awsRouteTablePlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the route table by the ProtectedTag, if any.
+func (p awsRouteTablePlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
+}
+
 func (p awsRouteTablePlugin) Label(id instance.ID, labels map[string]string) error {
 	return ec2CreateTags(p.client, id, labels)
 }
@@ -68,20 +89,20 @@ func (p awsRouteTablePlugin) Label(id instance.ID, labels map[string]string) err
 func (p awsRouteTablePlugin) Destroy(id instance.ID) error {
 	output, err := p.client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{RouteTableIds: []*string{(*string)(&id)}})
 	if err != nil {
-		return fmt.Errorf("DescribeRouteTables failed: %s", err)
+		return apiError("DescribeRouteTables", err)
 	}
 
 	if len(output.RouteTables) > 0 {
 		for _, a := range output.RouteTables[0].Associations {
 			_, err := p.client.DisassociateRouteTable(&ec2.DisassociateRouteTableInput{AssociationId: a.RouteTableAssociationId})
 			if err != nil {
-				return fmt.Errorf("DisassociateRouteTable failed: %s", err)
+				return apiError("DisassociateRouteTable", err)
 			}
 		}
 	}
 
 	if _, err := p.client.DeleteRouteTable(&ec2.DeleteRouteTableInput{RouteTableId: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteRouteTable failed: %s", err)
+		return apiError("DeleteRouteTable", err)
 	}
 	return nil
 }
@@ -99,7 +120,7 @@ func (p awsRouteTablePlugin) DescribeInstances(labels map[string]string, propert
 
 	output, err := p.client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeRouteTables failed: %s", err)
+		return []instance.Description{}, apiError("DescribeRouteTables", err)
 	}
 
 	descriptions := []instance.Description{}
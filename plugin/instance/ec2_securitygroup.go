@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -29,14 +30,24 @@ type createSecurityGroupRequest struct {
 	Tags                               map[string]string
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsSecurityGroupPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
+}
+
 func (p awsSecurityGroupPlugin) Validate(req *types.Any) error {
+	request := createSecurityGroupRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsSecurityGroupPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createSecurityGroupRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	_, tags := mergeTags(spec.Tags, p.namespaceTags)
@@ -45,38 +56,57 @@ func (p awsSecurityGroupPlugin) Provision(spec instance.Spec) (*instance.ID, err
 	request.CreateSecurityGroupInput.GroupName = aws.String(path)
 	output, err := p.client.CreateSecurityGroup(&request.CreateSecurityGroupInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateSecurityGroup failed: %s", err)
+		return nil, apiError("CreateSecurityGroup", err)
 	}
 	id := instance.ID(*output.GroupId)
 
 	if request.AuthorizeSecurityGroupEgressInput != nil {
 		request.AuthorizeSecurityGroupEgressInput.GroupId = output.GroupId
 		if _, err := p.client.AuthorizeSecurityGroupEgress(request.AuthorizeSecurityGroupEgressInput); err != nil {
-			return nil, fmt.Errorf("AuthorizeSecurityGroupEgress failed: %s", err)
+			return nil, apiError("AuthorizeSecurityGroupEgress", err)
 		}
 	}
 
 	if request.AuthorizeSecurityGroupIngressInput != nil {
 		request.AuthorizeSecurityGroupIngressInput.GroupId = output.GroupId
 		if _, err := p.client.AuthorizeSecurityGroupIngress(request.AuthorizeSecurityGroupIngressInput); err != nil {
-			return nil, fmt.Errorf("AuthorizeSecurityGroupIngress failed: %s", err)
+			return nil, apiError("AuthorizeSecurityGroupIngress", err)
 		}
 	}
 
 	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
 }
 
+// inNamespace returns true if the security group has the namespace tags.
+func (p awsSecurityGroupPlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the security group by the ProtectedTag, if any.
+func (p awsSecurityGroupPlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
+}
+
 func (p awsSecurityGroupPlugin) Label(id instance.ID, labels map[string]string) error {
 	return ec2CreateTags(p.client, id, labels)
 }
 
 func (p awsSecurityGroupPlugin) Destroy(id instance.ID) error {
+	var missing error
 	err := retry(30*time.Second, 500*time.Millisecond, func() error {
 		_, err := p.client.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{GroupId: (*string)(&id)})
+		if isNotFound(err) {
+			// Retrying won't bring back a missing security group.
+			missing = err
+			return nil
+		}
 		return err
 	})
+	if missing != nil {
+		err = missing
+	}
 	if err != nil {
-		return fmt.Errorf("DeleteSecurityGroup failed: %s", err)
+		return apiError("DeleteSecurityGroup", err)
 	}
 	return nil
 }
@@ -94,7 +124,7 @@ func (p awsSecurityGroupPlugin) DescribeInstances(labels map[string]string, prop
 
 	output, err := p.client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeSecurityGroups failed: %s", err)
+		return []instance.Description{}, apiError("DescribeSecurityGroups", err)
 	}
 
 	descriptions := []instance.Description{}
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -27,25 +28,45 @@ type createSubnetRequest struct {
 	CreateSubnetInput ec2.CreateSubnetInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsSubnetPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
+}
+
 func (p awsSubnetPlugin) Validate(req *types.Any) error {
+	request := createSubnetRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsSubnetPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createSubnetRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	output, err := p.client.CreateSubnet(&request.CreateSubnetInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateSubnet failed: %s", err)
+		return nil, apiError("CreateSubnet", err)
 	}
 	id := instance.ID(*output.Subnet.SubnetId)
 
 	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
 }
 
+// inNamespace returns true if the subnet has the namespace tags.
+func (p awsSubnetPlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the subnet by the ProtectedTag, if any.
+func (p awsSubnetPlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
+}
+
 func (p awsSubnetPlugin) Label(id instance.ID, labels map[string]string) error {
 	ec2Tags := []*ec2.Tag{}
 	for key, value := range labels {
@@ -53,18 +74,27 @@ func (p awsSubnetPlugin) Label(id instance.ID, labels map[string]string) error {
 	}
 
 	if _, err := p.client.CreateTags(&ec2.CreateTagsInput{Resources: []*string{(*string)(&id)}, Tags: ec2Tags}); err != nil {
-		return fmt.Errorf("CreateTags failed: %s", err)
+		return apiError("CreateTags", err)
 	}
 	return nil
 }
 
 func (p awsSubnetPlugin) Destroy(id instance.ID) error {
+	var missing error
 	err := retry(30*time.Second, 500*time.Millisecond, func() error {
 		_, err := p.client.DeleteSubnet(&ec2.DeleteSubnetInput{SubnetId: (*string)(&id)})
+		if isNotFound(err) {
+			// Retrying won't bring back a missing subnet.
+			missing = err
+			return nil
+		}
 		return err
 	})
+	if missing != nil {
+		err = missing
+	}
 	if err != nil {
-		return fmt.Errorf("DeleteSubnet failed: %s", err)
+		return apiError("DeleteSubnet", err)
 	}
 	return nil
 }
@@ -82,7 +112,7 @@ func (p awsSubnetPlugin) DescribeInstances(labels map[string]string, properties
 
 	output, err := p.client.DescribeSubnets(&ec2.DescribeSubnetsInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeSubnets failed: %s", err)
+		return []instance.Description{}, apiError("DescribeSubnets", err)
 	}
 
 	descriptions := []instance.Description{}
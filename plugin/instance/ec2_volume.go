@@ -3,14 +3,47 @@ package instance
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
 
+const (
+	// ProtectedTag is the AWS tag name that, when set to "true", prevents a resource from being destroyed.
+	ProtectedTag = "infrakit.protected"
+
+	// VolumeRetainPolicyTag is the AWS tag name recording the RetainPolicy applied when a volume is destroyed.
+	VolumeRetainPolicyTag = "infrakit.retain-policy"
+
+	// VolumeForceDetachTag is the AWS tag name that, when set to "true", forces detachment of a volume on destroy.
+	VolumeForceDetachTag = "infrakit.force-detach"
+
+	// VolumeRetainedTag is the AWS tag name set to "true" on a volume retained by its destroy, which is no longer
+	// described as a member of its group.
+	VolumeRetainedTag = "infrakit.retained"
+)
+
+// RetainPolicy determines what happens to the data of a volume when it is destroyed.
+type RetainPolicy string
+
+const (
+	// RetainPolicyRetain detaches the volume but never deletes it, and tags it with the VolumeRetainedTag.
+	RetainPolicyRetain = RetainPolicy("retain")
+
+	// RetainPolicySnapshot snapshots the volume before deleting it.
+	RetainPolicySnapshot = RetainPolicy("snapshot")
+
+	// RetainPolicyDelete deletes the volume.
+	RetainPolicyDelete = RetainPolicy("delete")
+)
+
 type awsVolumePlugin struct {
 	client        ec2iface.EC2API
 	namespaceTags map[string]string
@@ -24,34 +57,181 @@ func NewVolumePlugin(client ec2iface.EC2API, namespaceTags map[string]string) in
 type createVolumeRequest struct {
 	CreateVolumeInput ec2.CreateVolumeInput
 	Tags              map[string]string
+
+	// RetainPolicy is applied on Destroy.  Defaults to RetainPolicyDelete.
+	RetainPolicy RetainPolicy
+
+	// ForceDetach forces the detachment of the volume from its instance on Destroy.
+	ForceDetach bool
+
+	// Protected prevents the volume from being destroyed.
+	Protected bool
+}
+
+// destroyTags records the destroy options of the request as tags, since Destroy only has the volume ID to go by.
+func (r createVolumeRequest) destroyTags() map[string]string {
+	tags := map[string]string{}
+	if r.RetainPolicy != "" {
+		tags[VolumeRetainPolicyTag] = string(r.RetainPolicy)
+	}
+	if r.ForceDetach {
+		tags[VolumeForceDetachTag] = "true"
+	}
+	if r.Protected {
+		tags[ProtectedTag] = "true"
+	}
+	return tags
+}
+
+func validRetainPolicy(policy RetainPolicy) bool {
+	switch policy {
+	case "", RetainPolicyRetain, RetainPolicySnapshot, RetainPolicyDelete:
+		return true
+	}
+	return false
+}
+
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsVolumePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
 }
 
 func (p awsVolumePlugin) Validate(req *types.Any) error {
+	request := createVolumeRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
+	if !validRetainPolicy(request.RetainPolicy) {
+		return newError(ErrorClassInvalidSpec, "Invalid retain policy: %s", request.RetainPolicy)
+	}
 	return nil
 }
 
 func (p awsVolumePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createVolumeRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
+	}
+	if !validRetainPolicy(request.RetainPolicy) {
+		return nil, newError(ErrorClassInvalidSpec, "Invalid retain policy: %s", request.RetainPolicy)
 	}
 
 	output, err := p.client.CreateVolume(&request.CreateVolumeInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateVolume failed: %s", err)
+		return nil, apiError("CreateVolume", err)
 	}
 	id := instance.ID(*output.VolumeId)
 
-	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
+	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags, request.destroyTags())
+}
+
+// inNamespace returns true if the volume has the namespace tags.
+func (p awsVolumePlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the volume by the ProtectedTag, if any.
+func (p awsVolumePlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
 }
 
 func (p awsVolumePlugin) Label(id instance.ID, labels map[string]string) error {
 	return ec2CreateTags(p.client, id, labels)
 }
 
+// Destroy detaches the volume if it is in use, waits for it to become available and then applies the
+// retain policy recorded on the volume.
 func (p awsVolumePlugin) Destroy(id instance.ID) error {
-	if _, err := p.client.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteVolume failed: %s", err)
+	output, err := p.client.DescribeVolumes(&ec2.DescribeVolumesInput{VolumeIds: []*string{(*string)(&id)}})
+	if err != nil {
+		return apiError("DescribeVolumes", err)
+	}
+	if len(output.Volumes) != 1 {
+		return newError(ErrorClassNotFound, "No matching volume")
+	}
+	volume := output.Volumes[0]
+
+	tags := map[string]string{}
+	for _, tag := range volume.Tags {
+		if tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+
+	policy := RetainPolicy(tags[VolumeRetainPolicyTag])
+	if policy == "" {
+		policy = RetainPolicyDelete
+	}
+	if !validRetainPolicy(policy) {
+		return newError(ErrorClassInvalidSpec, "Invalid retain policy on volume %s: %s", id, policy)
+	}
+
+	if len(volume.Attachments) > 0 {
+		force, _ := strconv.ParseBool(tags[VolumeForceDetachTag])
+
+		log.Infof("Detaching volume %s (force=%v)", id, force)
+		_, err := p.client.DetachVolume(&ec2.DetachVolumeInput{VolumeId: volume.VolumeId, Force: aws.Bool(force)})
+		if err != nil {
+			return apiError("DetachVolume", err)
+		}
+
+		err = p.client.WaitUntilVolumeAvailable(&ec2.DescribeVolumesInput{VolumeIds: []*string{volume.VolumeId}})
+		if err != nil {
+			return apiError("WaitUntilVolumeAvailable", err)
+		}
+	}
+
+	switch policy {
+	case RetainPolicyRetain:
+		log.Infof("Retaining volume %s", id)
+		if err := ec2CreateTags(p.client, id, map[string]string{VolumeRetainedTag: "true"}); err != nil {
+			return apiError("CreateTags", err)
+		}
+		return nil
+
+	case RetainPolicySnapshot:
+		if err := p.snapshotBeforeDeletion(id, tags); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.client.DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: volume.VolumeId}); err != nil {
+		return apiError("DeleteVolume", err)
+	}
+	return nil
+}
+
+// snapshotBeforeDeletion snapshots the volume with its tags, unless a previous Destroy already did.  Failing to tag
+// the snapshot doesn't fail the Destroy, so that a retry doesn't leave another snapshot behind.
+func (p awsVolumePlugin) snapshotBeforeDeletion(id instance.ID, tags map[string]string) error {
+	description := fmt.Sprintf("Snapshot of %s before deletion", id)
+
+	existing, err := p.client.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("volume-id"), Values: []*string{aws.String(string(id))}},
+			{Name: aws.String("description"), Values: []*string{aws.String(description)}},
+		},
+	})
+	if err != nil {
+		return apiError("DescribeSnapshots", err)
+	}
+	if len(existing.Snapshots) > 0 {
+		log.Infof("Volume %s already has snapshot %s", id, aws.StringValue(existing.Snapshots[0].SnapshotId))
+		return nil
+	}
+
+	snapshot, err := p.client.CreateSnapshot(&ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(string(id)),
+		Description: aws.String(description),
+	})
+	if err != nil {
+		return apiError("CreateSnapshot", err)
+	}
+	log.Infof("Created snapshot %s of volume %s", aws.StringValue(snapshot.SnapshotId), id)
+
+	if err := ec2CreateTags(p.client, instance.ID(*snapshot.SnapshotId), withoutReservedTags(tags)); err != nil {
+		log.Warningf("Failed to tag snapshot %s of volume %s: %s", aws.StringValue(snapshot.SnapshotId), id, err)
 	}
 	return nil
 }
@@ -69,7 +249,7 @@ func (p awsVolumePlugin) DescribeInstances(labels map[string]string, properties
 
 	output, err := p.client.DescribeVolumes(&ec2.DescribeVolumesInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeVolumes failed: %s", err)
+		return []instance.Description{}, apiError("DescribeVolumes", err)
 	}
 
 	descriptions := []instance.Description{}
@@ -80,6 +260,9 @@ func (p awsVolumePlugin) DescribeInstances(labels map[string]string, properties
 				tags[*tag.Key] = *tag.Value
 			}
 		}
+		if retained, _ := strconv.ParseBool(tags[VolumeRetainedTag]); retained {
+			continue
+		}
 		descriptions = append(descriptions, instance.Description{ID: instance.ID(*volume.VolumeId), Tags: tags})
 	}
 	return descriptions, nil
@@ -0,0 +1,164 @@
+package instance
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/fake"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func describeVolumeResponse(volumeID string, attached bool, tags map[string]string) *ec2.DescribeVolumesOutput {
+	volume := &ec2.Volume{VolumeId: aws.String(volumeID)}
+	for k, v := range tags {
+		volume.Tags = append(volume.Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	if attached {
+		volume.Attachments = []*ec2.VolumeAttachment{{VolumeId: aws.String(volumeID), InstanceId: aws.String("i-1")}}
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: []*ec2.Volume{volume}}
+}
+
+func TestDestroyVolumeDetachesAndDeletes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	volumeID := "vol-1"
+	describe := &ec2.DescribeVolumesInput{VolumeIds: []*string{&volumeID}}
+
+	gomock.InOrder(
+		clientMock.EXPECT().DescribeVolumes(describe).
+			Return(describeVolumeResponse(volumeID, true, map[string]string{VolumeForceDetachTag: "true"}), nil),
+		clientMock.EXPECT().DetachVolume(&ec2.DetachVolumeInput{VolumeId: &volumeID, Force: aws.Bool(true)}).
+			Return(&ec2.VolumeAttachment{}, nil),
+		clientMock.EXPECT().WaitUntilVolumeAvailable(describe).Return(nil),
+		clientMock.EXPECT().DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: &volumeID}).
+			Return(&ec2.DeleteVolumeOutput{}, nil),
+	)
+
+	pluginImpl := NewVolumePlugin(clientMock, testNamespace)
+	require.NoError(t, pluginImpl.Destroy(instance.ID(volumeID)))
+}
+
+func TestDestroyVolumeRetainPolicies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	volumeID := "vol-1"
+	snapshotID := "snap-1"
+	describe := &ec2.DescribeVolumesInput{VolumeIds: []*string{&volumeID}}
+	pluginImpl := NewVolumePlugin(clientMock, testNamespace)
+
+	// Retained volumes are never deleted.
+	clientMock.EXPECT().DescribeVolumes(describe).
+		Return(describeVolumeResponse(volumeID, false, map[string]string{VolumeRetainPolicyTag: "retain"}), nil)
+	clientMock.EXPECT().CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{&volumeID},
+		Tags:      []*ec2.Tag{{Key: aws.String(VolumeRetainedTag), Value: aws.String("true")}},
+	}).Return(&ec2.CreateTagsOutput{}, nil)
+	require.NoError(t, pluginImpl.Destroy(instance.ID(volumeID)))
+
+	// Snapshotted volumes are deleted only after a snapshot is taken, tagged without the reserved tags.
+	snapshotTags := map[string]string{VolumeRetainPolicyTag: "snapshot", "aws:cloudformation:stack-name": "stack"}
+	gomock.InOrder(
+		clientMock.EXPECT().DescribeVolumes(describe).
+			Return(describeVolumeResponse(volumeID, false, snapshotTags), nil),
+		clientMock.EXPECT().DescribeSnapshots(gomock.Any()).Return(&ec2.DescribeSnapshotsOutput{}, nil),
+		clientMock.EXPECT().CreateSnapshot(gomock.Any()).
+			Return(&ec2.Snapshot{SnapshotId: &snapshotID}, nil),
+		clientMock.EXPECT().CreateTags(&ec2.CreateTagsInput{
+			Resources: []*string{&snapshotID},
+			Tags:      []*ec2.Tag{{Key: aws.String(VolumeRetainPolicyTag), Value: aws.String("snapshot")}},
+		}).Return(&ec2.CreateTagsOutput{}, nil),
+		clientMock.EXPECT().DeleteVolume(&ec2.DeleteVolumeInput{VolumeId: &volumeID}).
+			Return(&ec2.DeleteVolumeOutput{}, nil),
+	)
+	require.NoError(t, pluginImpl.Destroy(instance.ID(volumeID)))
+}
+
+func TestDestroyRetainedVolumeLeavesGroup(t *testing.T) {
+	client := fake.NewEC2()
+	pluginImpl := NewVolumePlugin(client, testNamespace)
+
+	id, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(fmt.Sprintf(`{
+			"CreateVolumeInput": {"AvailabilityZone": "%s", "Size": 10},
+			"RetainPolicy": "retain"
+		}`, fake.DefaultAvailabilityZone)),
+		Tags: tags,
+	})
+	require.NoError(t, err)
+
+	descriptions, err := pluginImpl.DescribeInstances(tags, false)
+	require.NoError(t, err)
+	require.Len(t, descriptions, 1)
+
+	// The retained volume is kept, but no longer described as a member of the group, also after another Destroy.
+	require.NoError(t, pluginImpl.Destroy(*id))
+	require.NoError(t, pluginImpl.Destroy(*id))
+	descriptions, err = pluginImpl.DescribeInstances(tags, false)
+	require.NoError(t, err)
+	require.Empty(t, descriptions)
+
+	volumes, err := client.DescribeVolumes(&ec2.DescribeVolumesInput{VolumeIds: []*string{(*string)(id)}})
+	require.NoError(t, err)
+	require.Len(t, volumes.Volumes, 1)
+}
+
+func TestDestroyVolumeSnapshotsOnce(t *testing.T) {
+	client := fake.NewEC2()
+	pluginImpl := NewVolumePlugin(client, testNamespace)
+
+	volume, err := client.CreateVolume(&ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(fake.DefaultAvailabilityZone),
+		Size:             aws.Int64(10),
+	})
+	require.NoError(t, err)
+	id := instance.ID(*volume.VolumeId)
+	require.NoError(t, ec2CreateTags(client, id, map[string]string{VolumeRetainPolicyTag: "snapshot"}))
+
+	// A Destroy failing after the snapshot is retried without taking another one.
+	client.Fail("DeleteVolume", fake.Error("InternalError", "An internal error has occurred"))
+	require.Error(t, pluginImpl.Destroy(id))
+	require.NoError(t, pluginImpl.Destroy(id))
+
+	snapshots, err := client.DescribeSnapshots(&ec2.DescribeSnapshotsInput{})
+	require.NoError(t, err)
+	require.Len(t, snapshots.Snapshots, 1)
+	require.Equal(t, []*ec2.Tag{{Key: aws.String(VolumeRetainPolicyTag), Value: aws.String("snapshot")}},
+		snapshots.Snapshots[0].Tags)
+}
+
+func TestDestroyProtectedVolume(t *testing.T) {
+	client := fake.NewEC2()
+	pluginImpl := NewVolumePlugin(client, testNamespace)
+	id, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(fmt.Sprintf(`{
+			"CreateVolumeInput": {"AvailabilityZone": "%s", "Size": 10},
+			"Protected": true
+		}`, fake.DefaultAvailabilityZone)),
+		Tags: tags,
+	})
+	require.NoError(t, err)
+
+	// Protection is enforced by the safety plugin.
+	safe := NewSafetyPlugin("ec2-volume", pluginImpl, NewDestroyBreaker(0, 0))
+	require.Equal(t, ErrorClassProtected, ClassOf(safe.Destroy(*id)))
+	volumes, err := client.DescribeVolumes(&ec2.DescribeVolumesInput{VolumeIds: []*string{(*string)(id)}})
+	require.NoError(t, err)
+	require.Len(t, volumes.Volumes, 1)
+}
+
+func TestValidateVolumeRetainPolicy(t *testing.T) {
+	pluginImpl := NewVolumePlugin(nil, testNamespace)
+	require.NoError(t, pluginImpl.Validate(types.AnyString(`{"RetainPolicy": "snapshot"}`)))
+	require.Error(t, pluginImpl.Validate(types.AnyString(`{"RetainPolicy": "sometimes"}`)))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -27,39 +28,59 @@ type createVpcRequest struct {
 	Tags                     map[string]string
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsVpcPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(ec2iface.EC2API)
+	return p
+}
+
 func (p awsVpcPlugin) Validate(req *types.Any) error {
+	request := createVpcRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsVpcPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createVpcRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	output, err := p.client.CreateVpc(&request.CreateVpcInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateVpc failed: %s", err)
+		return nil, apiError("CreateVpc", err)
 	}
 	id := instance.ID(*output.Vpc.VpcId)
 
 	for _, input := range request.ModifyVpcAttributeInputs {
 		input.VpcId = output.Vpc.VpcId
 		if _, err := p.client.ModifyVpcAttribute(&input); err != nil {
-			return &id, fmt.Errorf("ModifyVpcAttribute failed: %s", err)
+			return &id, apiError("ModifyVpcAttribute", err)
 		}
 	}
 
 	return &id, ec2CreateTags(p.client, id, request.Tags, spec.Tags, p.namespaceTags)
 }
 
+// inNamespace returns true if the VPC has the namespace tags.
+func (p awsVpcPlugin) inNamespace(id instance.ID) (bool, error) {
+	return ec2InNamespace(p.client, id, p.namespaceTags)
+}
+
+// protection returns the protection of the VPC by the ProtectedTag, if any.
+func (p awsVpcPlugin) protection(id instance.ID) (string, error) {
+	return ec2Protection(p.client, id)
+}
+
 func (p awsVpcPlugin) Label(id instance.ID, labels map[string]string) error {
 	return ec2CreateTags(p.client, id, labels)
 }
 
 func (p awsVpcPlugin) Destroy(id instance.ID) error {
 	if _, err := p.client.DeleteVpc(&ec2.DeleteVpcInput{VpcId: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteVpc failed: %s", err)
+		return apiError("DeleteVpc", err)
 	}
 	return nil
 }
@@ -77,7 +98,7 @@ func (p awsVpcPlugin) DescribeInstances(labels map[string]string, properties boo
 
 	output, err := p.client.DescribeVpcs(&ec2.DescribeVpcsInput{Filters: filters})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeVpcs failed: %s", err)
+		return []instance.Description{}, apiError("DescribeVpcs", err)
 	}
 
 	descriptions := []instance.Description{}
@@ -2,11 +2,11 @@ package instance
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -28,35 +28,45 @@ type createLoadBalancerRequest struct {
 	Tags                              map[string]string
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsLoadBalancerPlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(elbiface.ELBAPI)
+	return p
+}
+
 func (p awsLoadBalancerPlugin) Validate(req *types.Any) error {
+	request := createLoadBalancerRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsLoadBalancerPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createLoadBalancerRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	name := newLoadBalancerName(spec.Tags, p.namespaceTags)
 
 	request.CreateLoadBalancerInput.LoadBalancerName = aws.String(name)
 	if _, err := p.client.CreateLoadBalancer(&request.CreateLoadBalancerInput); err != nil {
-		return nil, fmt.Errorf("CreateLoadBalancer failed: %s", err)
+		return nil, apiError("CreateLoadBalancer", err)
 	}
 	id := instance.ID(name)
 
 	if request.ConfigureHealthCheckInput != nil {
 		request.ConfigureHealthCheckInput.LoadBalancerName = request.CreateLoadBalancerInput.LoadBalancerName
 		if _, err := p.client.ConfigureHealthCheck(request.ConfigureHealthCheckInput); err != nil {
-			return &id, fmt.Errorf("ConfigureHealthCheck failed: %s", err)
+			return &id, apiError("ConfigureHealthCheck", err)
 		}
 	}
 
 	if request.ModifyLoadBalancerAttributesInput != nil {
 		request.ModifyLoadBalancerAttributesInput.LoadBalancerName = request.CreateLoadBalancerInput.LoadBalancerName
 		if _, err := p.client.ModifyLoadBalancerAttributes(request.ModifyLoadBalancerAttributesInput); err != nil {
-			return &id, fmt.Errorf("ModifyLoadBalancerAttributes failed: %s", err)
+			return &id, apiError("ModifyLoadBalancerAttributes", err)
 		}
 	}
 
@@ -64,6 +74,42 @@ func (p awsLoadBalancerPlugin) Provision(spec instance.Spec) (*instance.ID, erro
 	return &id, p.Label(id, tags)
 }
 
+// tagsOf returns the tags of the load balancer.
+func (p awsLoadBalancerPlugin) tagsOf(id instance.ID) (map[string]string, error) {
+	output, err := p.client.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: []*string{(*string)(&id)}})
+	if err != nil {
+		return nil, apiError("DescribeTags", err)
+	}
+	tags := map[string]string{}
+	for _, tagDescription := range output.TagDescriptions {
+		for _, tag := range tagDescription.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// inNamespace returns true if the load balancer has the namespace tags.
+func (p awsLoadBalancerPlugin) inNamespace(id instance.ID) (bool, error) {
+	if len(p.namespaceTags) == 0 {
+		return true, nil
+	}
+	tags, err := p.tagsOf(id)
+	if err != nil {
+		return false, err
+	}
+	return hasTags(tags, p.namespaceTags), nil
+}
+
+// protection returns the protection of the load balancer by the ProtectedTag, if any.
+func (p awsLoadBalancerPlugin) protection(id instance.ID) (string, error) {
+	tags, err := p.tagsOf(id)
+	if err != nil {
+		return "", err
+	}
+	return protectedByTag(tags), nil
+}
+
 func (p awsLoadBalancerPlugin) Label(id instance.ID, labels map[string]string) error {
 	elbTags := []*elb.Tag{}
 	for key, value := range labels {
@@ -71,14 +117,14 @@ func (p awsLoadBalancerPlugin) Label(id instance.ID, labels map[string]string) e
 	}
 
 	if _, err := p.client.AddTags(&elb.AddTagsInput{LoadBalancerNames: []*string{(*string)(&id)}, Tags: elbTags}); err != nil {
-		return fmt.Errorf("AddTags failed: %s", err)
+		return apiError("AddTags", err)
 	}
 	return nil
 }
 
 func (p awsLoadBalancerPlugin) Destroy(id instance.ID) error {
 	if _, err := p.client.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{LoadBalancerName: (*string)(&id)}); err != nil {
-		return fmt.Errorf("DeleteLoadBalancer failed: %s", err)
+		return apiError("DeleteLoadBalancer", err)
 	}
 	return nil
 }
@@ -88,7 +134,7 @@ func (p awsLoadBalancerPlugin) DescribeInstances(labels map[string]string, prope
 
 	output, err := p.client.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeLoadBalancers failed: %s", err)
+		return []instance.Description{}, apiError("DescribeLoadBalancers", err)
 	}
 	if len(output.LoadBalancerDescriptions) == 0 {
 		return []instance.Description{}, nil
@@ -101,7 +147,7 @@ func (p awsLoadBalancerPlugin) DescribeInstances(labels map[string]string, prope
 
 	describeTagsOutput, err := p.client.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: loadBalancerNames})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("DescribeTags failed: %s", err)
+		return []instance.Description{}, apiError("DescribeTags", err)
 	}
 
 Loop:
@@ -2,6 +2,13 @@ package instance
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/docker/infrakit.aws/plugin/audit"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
 )
 
 // ErrUnexpectedResponse is error when the API call violates contract and has unexpected results.
@@ -26,3 +33,328 @@ type ErrExceededAttempts struct {
 func (e *ErrExceededAttempts) Error() string {
 	return fmt.Sprintf("Max attempts exceeded: %d", e.attempts)
 }
+
+// ErrorClass is the class of the errors of the plugins, telling callers whether retrying may succeed.
+type ErrorClass string
+
+const (
+	// ErrorClassRetryable is the class of transient errors, e.g. of throttled requests, of AWS internal errors or of
+	// resources not yet in the state an operation needs, which retrying the same request later may not hit.
+	ErrorClassRetryable ErrorClass = "retryable"
+
+	// ErrorClassCapacity is the class of errors of AWS lacking the capacity for the resource, which retrying later
+	// or with another instance type or placement may not hit.
+	ErrorClassCapacity ErrorClass = "capacity"
+
+	// ErrorClassQuota is the class of errors of requests exceeding a limit of the account, which retrying doesn't
+	// help until resources are released or the limit is raised.
+	ErrorClassQuota ErrorClass = "quota"
+
+	// ErrorClassInvalidSpec is the class of errors of invalid properties, which must be fixed before retrying.
+	ErrorClassInvalidSpec ErrorClass = "invalid-spec"
+
+	// ErrorClassNotFound is the class of errors of missing resources.
+	ErrorClassNotFound ErrorClass = "not-found"
+
+	// ErrorClassPermission is the class of errors of requests the credentials of the plugin aren't authorized to
+	// make, which must be granted before retrying.
+	ErrorClassPermission ErrorClass = "permission"
+
+	// ErrorClassNamespace is the class of errors of operations refused on resources outside the namespace of the
+	// plugin, which retrying doesn't help.
+	ErrorClassNamespace ErrorClass = "namespace"
+
+	// ErrorClassProtected is the class of errors of destroys refused on protected resources, which retrying doesn't
+	// help until the protection is lifted.
+	ErrorClassProtected ErrorClass = "protected"
+
+	// ErrorClassHalted is the class of errors of destroys refused by a tripped DestroyBreaker, which retrying doesn't
+	// help until the breaker is reset or the plugin restarts.
+	ErrorClassHalted ErrorClass = "halted"
+)
+
+// Retryable returns true if retrying the request later may succeed.
+func (c ErrorClass) Retryable() bool {
+	return c == ErrorClassRetryable || c == ErrorClassCapacity
+}
+
+var (
+	// quotaErrorCodes are the codes of the errors of limits of the account, besides those ending with
+	// LimitExceeded.
+	quotaErrorCodes = map[string]bool{
+		"InstanceLimitExceeded":        true,
+		"MaxSpotInstanceCountExceeded": true,
+		"TooManyLoadBalancers":         true,
+		"TooManyTags":                  true,
+		"TooManyPolicies":              true,
+	}
+
+	// permissionErrorCodes are the codes of the errors of unauthorized requests and invalid credentials.
+	permissionErrorCodes = map[string]bool{
+		"AccessDenied":                true,
+		"AccessDeniedException":       true,
+		"AuthFailure":                 true,
+		"Blocked":                     true,
+		"ExpiredToken":                true,
+		"ExpiredTokenException":       true,
+		"IncompleteSignature":         true,
+		"InvalidClientTokenId":        true,
+		"MissingAuthenticationToken":  true,
+		"NoCredentialProviders":       true,
+		"OptInRequired":               true,
+		"PendingVerification":         true,
+		"SignatureDoesNotMatch":       true,
+		"UnauthorizedOperation":       true,
+		"UnrecognizedClientException": true,
+	}
+
+	// retryableErrorCodes are the codes of transient errors, besides throttling.  Many are of eventual consistency,
+	// e.g. resources still in use by those being deleted.
+	retryableErrorCodes = map[string]bool{
+		"AWS.SimpleQueueService.QueueDeletedRecently": true,
+		"ConcurrentModification":                      true,
+		"DependencyViolation":                         true,
+		"EntityTemporarilyUnmodifiable":               true,
+		"IncorrectInstanceState":                      true,
+		"IncorrectState":                              true,
+		"InternalError":                               true,
+		"InternalFailure":                             true,
+		"OperationAborted":                            true,
+		"PriorRequestNotComplete":                     true,
+		"RequestError":                                true,
+		"RequestTimeout":                              true,
+		"RequestTimeoutException":                     true,
+		"ResourceContention":                          true,
+		"ResourceInUse":                               true,
+		"ResourceInUseException":                      true,
+		"ScalingActivityInProgress":                   true,
+		"ServiceUnavailable":                          true,
+		"Unavailable":                                 true,
+	}
+
+	// invalidSpecErrorCodes are the codes of the errors of invalid requests, besides those starting with Invalid or
+	// Malformed, or ending with .Malformed.
+	invalidSpecErrorCodes = map[string]bool{
+		"AlreadyExists":          true,
+		"AlreadyExistsException": true,
+		"EntityAlreadyExists":    true,
+		"MissingParameter":       true,
+		"UnknownParameter":       true,
+		"ValidationError":        true,
+		"ValidationException":    true,
+	}
+)
+
+// classOf returns the class of the AWS error, or an empty class if it is unknown.
+func classOf(awsErr awserr.Error) ErrorClass {
+	code := awsErr.Code()
+	switch {
+	case throttlingErrorCodes[code]:
+		return ErrorClassRetryable
+	case isNotFound(awsErr):
+		return ErrorClassNotFound
+	case quotaErrorCodes[code] || strings.HasSuffix(code, "LimitExceeded") ||
+		strings.HasSuffix(code, "LimitExceededException"):
+		return ErrorClassQuota
+	case capacityErrorCodes[code]:
+		return ErrorClassCapacity
+	case permissionErrorCodes[code]:
+		return ErrorClassPermission
+	case retryableErrorCodes[code]:
+		return ErrorClassRetryable
+	case invalidSpecErrorCodes[code] || strings.HasPrefix(code, "Invalid") || strings.HasPrefix(code, "Malformed") ||
+		strings.HasSuffix(code, ".Malformed"):
+		return ErrorClassInvalidSpec
+	}
+	if failure, is := awsErr.(awserr.RequestFailure); is && failure.StatusCode() >= 500 {
+		return ErrorClassRetryable
+	}
+	return ""
+}
+
+// Error is a classified error of a plugin.  It carries the code and request ID of the AWS error it wraps, if any,
+// and its message records them with its class, so that they are parsed back by AsError from errors received over
+// RPC.  It is an awserr.Error, with the code of the error it wraps.
+type Error struct {
+	// Class is the class of the error, or empty if it isn't known.
+	Class ErrorClass
+
+	// Description describes the error, e.g. which operation failed, without its class, code and request ID.
+	Description string
+
+	code      string
+	requestID string
+	err       error
+}
+
+// newError returns an error of the class, with the description formatted.
+func newError(class ErrorClass, format string, args ...interface{}) *Error {
+	return &Error{Class: class, Description: fmt.Sprintf(format, args...)}
+}
+
+// wrapError returns an error with the description formatted, and the class, code and request ID of the error.
+func wrapError(err error, format string, args ...interface{}) *Error {
+	wrapped := AsError(err)
+	return &Error{
+		Class:       wrapped.Class,
+		Description: fmt.Sprintf(format, args...),
+		code:        wrapped.code,
+		requestID:   wrapped.requestID,
+		err:         err,
+	}
+}
+
+// apiError returns the error of the failed AWS API operation.
+func apiError(operation string, err error) *Error {
+	return wrapError(err, "%s failed: %s", operation, err)
+}
+
+// invalidSpecError returns the error of properties failing to decode.
+func invalidSpecError(err error) *Error {
+	return newError(ErrorClassInvalidSpec, "Invalid input formatting: %s", err)
+}
+
+// errorAnnotation is the annotation of the class, code and request ID in the messages of errors.
+var errorAnnotation = regexp.MustCompile(`\s*\[((?:class|code|request)=\S*?(?: (?:class|code|request)=\S*?)*)\]`)
+
+func (e *Error) Error() string {
+	annotations := []string{}
+	if e.Class != "" {
+		annotations = append(annotations, "class="+string(e.Class))
+	}
+	if e.code != "" {
+		annotations = append(annotations, "code="+e.code)
+	}
+	if e.requestID != "" {
+		annotations = append(annotations, "request="+e.requestID)
+	}
+	if len(annotations) == 0 {
+		return e.Description
+	}
+	return fmt.Sprintf("%s [%s]", e.Description, strings.Join(annotations, " "))
+}
+
+// Code returns the code of the AWS error, if any.
+func (e *Error) Code() string {
+	return e.code
+}
+
+// Message returns the description of the error.
+func (e *Error) Message() string {
+	return e.Description
+}
+
+// OrigErr returns the error wrapped, which is nil for errors received over RPC.
+func (e *Error) OrigErr() error {
+	return e.err
+}
+
+// RequestID returns the ID of the AWS request that failed, if any.
+func (e *Error) RequestID() string {
+	return e.requestID
+}
+
+// AsError returns the error, classified.  Errors received over RPC are parsed from their message.  Returns nil if
+// the error is nil, and an error with an empty class if its class is unknown.
+func AsError(err error) *Error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *Error:
+		return e
+	case *ErrInvalidRequest:
+		return &Error{Class: ErrorClassInvalidSpec, Description: e.Error(), err: e}
+	case *ErrExceededAttempts:
+		return &Error{Class: ErrorClassRetryable, Description: e.Error(), err: e}
+	case awserr.Error:
+		classified := &Error{Class: classOf(e), Description: e.Error(), code: e.Code(), err: e}
+		if failure, is := e.(awserr.RequestFailure); is {
+			classified.requestID = failure.RequestID()
+		}
+		return classified
+	}
+
+	message := err.Error()
+	matches := errorAnnotation.FindAllStringSubmatchIndex(message, -1)
+	if len(matches) == 0 {
+		return &Error{Description: message, err: err}
+	}
+	match := matches[len(matches)-1]
+	parsed := &Error{Description: message[:match[0]] + message[match[1]:], err: err}
+	for _, annotation := range strings.Split(message[match[2]:match[3]], " ") {
+		keyAndValue := strings.SplitN(annotation, "=", 2)
+		switch keyAndValue[0] {
+		case "class":
+			parsed.Class = ErrorClass(keyAndValue[1])
+		case "code":
+			parsed.code = keyAndValue[1]
+		case "request":
+			parsed.requestID = keyAndValue[1]
+		}
+	}
+	return parsed
+}
+
+// ClassOf returns the class of the error, also when received over RPC, or an empty class if it is not known.
+func ClassOf(err error) ErrorClass {
+	if classified := AsError(err); classified != nil {
+		return classified.Class
+	}
+	return ""
+}
+
+// IsRetryable returns true if retrying the request that failed with the error later may succeed.
+func IsRetryable(err error) bool {
+	return ClassOf(err).Retryable()
+}
+
+// classify returns the error classified, so that its class, code and request ID are in its message, or nil.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	return AsError(err)
+}
+
+// classifiedPlugin classifies the errors of an instance plugin.
+type classifiedPlugin struct {
+	passThroughPlugin
+}
+
+// NewClassifiedPlugin returns the plugin, with its errors classified, so that callers receiving them over RPC can
+// parse their class with AsError.
+func NewClassifiedPlugin(plugin instance.Plugin) instance.Plugin {
+	return &classifiedPlugin{passThrough(plugin)}
+}
+
+// WithCall returns the plugin of the call of the plugin, with its errors classified.
+func (p *classifiedPlugin) WithCall(call *audit.Call) instance.Plugin {
+	return &classifiedPlugin{passThrough(audit.WithCall(p.Wrapped(), call))}
+}
+
+// Validate validates the request.
+func (p *classifiedPlugin) Validate(req *types.Any) error {
+	return classify(p.Plugin.Validate(req))
+}
+
+// Provision provisions an instance.
+func (p *classifiedPlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	id, err := p.Plugin.Provision(spec)
+	return id, classify(err)
+}
+
+// Label labels the instance.
+func (p *classifiedPlugin) Label(id instance.ID, labels map[string]string) error {
+	return classify(p.Plugin.Label(id, labels))
+}
+
+// Destroy destroys the instance.
+func (p *classifiedPlugin) Destroy(id instance.ID) error {
+	return classify(p.Plugin.Destroy(id))
+}
+
+// DescribeInstances describes the instances with the tags.
+func (p *classifiedPlugin) DescribeInstances(tags map[string]string, properties bool) ([]instance.Description, error) {
+	descriptions, err := p.Plugin.DescribeInstances(tags, properties)
+	return descriptions, classify(err)
+}
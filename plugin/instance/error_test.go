@@ -0,0 +1,118 @@
+package instance
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/docker/infrakit.aws/fake"
+	"github.com/docker/infrakit/pkg/plugin"
+	instance_rpc "github.com/docker/infrakit/pkg/rpc/instance"
+	"github.com/docker/infrakit/pkg/rpc/server"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassOf(t *testing.T) {
+	for code, class := range map[string]ErrorClass{
+		"RequestLimitExceeded":         ErrorClassRetryable,
+		"DependencyViolation":          ErrorClassRetryable,
+		"InsufficientInstanceCapacity": ErrorClassCapacity,
+		"InstanceLimitExceeded":        ErrorClassQuota,
+		"VpcLimitExceeded":             ErrorClassQuota,
+		"LimitExceededException":       ErrorClassQuota,
+		"InvalidParameterValue":        ErrorClassInvalidSpec,
+		"InvalidAMIID.Malformed":       ErrorClassInvalidSpec,
+		"ValidationError":              ErrorClassInvalidSpec,
+		"InvalidInstanceID.NotFound":   ErrorClassNotFound,
+		"NoSuchEntity":                 ErrorClassNotFound,
+		"UnauthorizedOperation":        ErrorClassPermission,
+		"InvalidClientTokenId":         ErrorClassPermission,
+		"Unheard":                      "",
+	} {
+		require.Equal(t, class, ClassOf(awserr.New(code, "message", nil)), code)
+	}
+
+	require.Equal(t, ErrorClassRetryable, ClassOf(awserr.NewRequestFailure(awserr.New("Unheard", "", nil), 503, "")))
+	require.Equal(t, ErrorClass(""), ClassOf(errors.New("failed")))
+	require.Equal(t, ErrorClass(""), ClassOf(nil))
+	require.True(t, IsRetryable(fake.Error("InsufficientInstanceCapacity", "no capacity")))
+	require.False(t, IsRetryable(fake.Error("UnauthorizedOperation", "denied")))
+}
+
+func TestAPIError(t *testing.T) {
+	cause := awserr.NewRequestFailure(awserr.New("InvalidVolume.NotFound", "missing", nil), 400, "request-1")
+	err := apiError("DescribeVolumes", cause)
+	require.Equal(t, ErrorClassNotFound, err.Class)
+	require.Equal(t, "InvalidVolume.NotFound", err.Code())
+	require.Equal(t, "request-1", err.RequestID())
+	require.Equal(t, cause, err.OrigErr())
+	require.Equal(t, "DescribeVolumes failed: "+cause.Error()+
+		" [class=not-found code=InvalidVolume.NotFound request=request-1]", err.Error())
+	require.True(t, isNotFound(err))
+
+	wrapped := wrapError(err, "Destroying vol-1 failed: %s", err)
+	require.Equal(t, ErrorClassNotFound, wrapped.Class)
+	require.Equal(t, "InvalidVolume.NotFound", wrapped.Code())
+
+	// The classification of errors received as text is parsed from their message.
+	parsed := AsError(errors.New("Provision failed: " + err.Error()))
+	require.Equal(t, ErrorClassNotFound, parsed.Class)
+	require.Equal(t, "InvalidVolume.NotFound", parsed.Code())
+	require.Equal(t, "request-1", parsed.RequestID())
+	require.Equal(t, "Provision failed: DescribeVolumes failed: "+cause.Error(), parsed.Description)
+
+	require.Equal(t, "Invalid retain policy: keep [class=invalid-spec]",
+		newError(ErrorClassInvalidSpec, "Invalid retain policy: %s", "keep").Error())
+	require.Equal(t, "failed", apiError("Anything", errors.New("failed")).OrigErr().Error())
+}
+
+func TestDestroyMissingResources(t *testing.T) {
+	start := time.Now()
+	for id, plugin := range map[instance.ID]instance.Plugin{
+		"subnet-missing":        NewSubnetPlugin(fake.NewEC2(), map[string]string{}),
+		"sg-missing":            NewSecurityGroupPlugin(fake.NewEC2(), map[string]string{}),
+		"missing-group":         NewAutoScalingGroupPlugin(fake.NewAutoScaling(), map[string]string{}),
+		"missing-launch-config": NewLaunchConfigurationPlugin(fake.NewAutoScaling(), map[string]string{}),
+	} {
+		require.Equal(t, ErrorClassNotFound, ClassOf(plugin.Destroy(id)), "Destroy of %s", id)
+	}
+	require.True(t, time.Since(start) < time.Second, "Destroys of missing resources were retried")
+}
+
+func TestClassifiedPluginOverRPC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "classified")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := fake.NewEC2()
+	client.Fail("DescribeVolumes", fake.Error("UnauthorizedOperation", "You are not authorized"))
+
+	socket := filepath.Join(dir, "instance-aws.sock")
+	served, err := server.StartPluginAtPath(socket,
+		instance_rpc.PluginServer(NewClassifiedPlugin(NewVolumePlugin(client, map[string]string{}))))
+	require.NoError(t, err)
+	defer served.Stop()
+
+	remote, err := instance_rpc.NewClient(plugin.Name("instance-aws"), socket)
+	require.NoError(t, err)
+
+	err = remote.Validate(types.AnyString(`{"RetainPolicy": "keep"}`))
+	require.Equal(t, ErrorClassInvalidSpec, ClassOf(err))
+
+	err = remote.Destroy(instance.ID("vol-1"))
+	require.Error(t, err)
+	classified := AsError(err)
+	require.Equal(t, ErrorClassPermission, classified.Class)
+	require.Equal(t, "UnauthorizedOperation", classified.Code())
+	require.NotEmpty(t, classified.RequestID())
+	require.False(t, IsRetryable(err))
+
+	err = remote.Destroy(instance.ID("vol-1"))
+	require.Equal(t, ErrorClassNotFound, ClassOf(err))
+}
@@ -2,13 +2,13 @@ package instance
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -28,7 +28,17 @@ type createInstanceProfileRequest struct {
 	AddRoleToInstanceProfileInput *iam.AddRoleToInstanceProfileInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsInstanceProfilePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(iamiface.IAMAPI)
+	return p
+}
+
 func (p awsInstanceProfilePlugin) Validate(req *types.Any) error {
+	request := createInstanceProfileRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
@@ -38,14 +48,14 @@ func (p awsInstanceProfilePlugin) Provision(spec instance.Spec) (*instance.ID, e
 
 	request := createInstanceProfileRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 	request.CreateInstanceProfileInput.InstanceProfileName = aws.String(strings.Replace(strings.Trim(path, "/"), "/", ".", -1))
 	request.CreateInstanceProfileInput.Path = aws.String(path)
 
 	if _, err := p.client.CreateInstanceProfile(&request.CreateInstanceProfileInput); err != nil {
 		if awsErr, ok := err.(awserr.Error); !(ok && awsErr.Code() == "EntityAlreadyExists") {
-			return nil, fmt.Errorf("CreateInstanceProfile failed: %s", err)
+			return nil, apiError("CreateInstanceProfile", err)
 		}
 	}
 	id := instance.ID(*request.CreateInstanceProfileInput.InstanceProfileName)
@@ -58,13 +68,26 @@ func (p awsInstanceProfilePlugin) Provision(spec instance.Spec) (*instance.ID, e
 
 		request.AddRoleToInstanceProfileInput.InstanceProfileName = request.CreateInstanceProfileInput.InstanceProfileName
 		if _, err := p.client.AddRoleToInstanceProfile(request.AddRoleToInstanceProfileInput); err != nil {
-			return nil, fmt.Errorf("AddRoleToInstanceProfile failed: %s", err)
+			return nil, apiError("AddRoleToInstanceProfile", err)
 		}
 	}
 
 	return &id, nil
 }
 
+// inNamespace returns true if the path of the instance profile has the values of the namespace tags.
+func (p awsInstanceProfilePlugin) inNamespace(id instance.ID) (bool, error) {
+	if len(p.namespaceTags) == 0 {
+		return true, nil
+	}
+
+	output, err := p.client.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: (*string)(&id)})
+	if err != nil {
+		return false, apiError("GetInstanceProfile", err)
+	}
+	return namedInNamespace(aws.StringValue(output.InstanceProfile.Path), newIamPath, p.namespaceTags), nil
+}
+
 func (p awsInstanceProfilePlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
@@ -72,7 +95,7 @@ func (p awsInstanceProfilePlugin) Label(id instance.ID, labels map[string]string
 func (p awsInstanceProfilePlugin) Destroy(id instance.ID) error {
 	output, err := p.client.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: (*string)(&id)})
 	if err != nil {
-		return fmt.Errorf("GetInstanceProfile failed: %s", err)
+		return apiError("GetInstanceProfile", err)
 	}
 
 	for _, r := range output.InstanceProfile.Roles {
@@ -81,13 +104,13 @@ func (p awsInstanceProfilePlugin) Destroy(id instance.ID) error {
 			RoleName:            r.RoleName,
 		})
 		if err != nil {
-			return fmt.Errorf("RemoveRoleFromInstanceProfile failed: %s", err)
+			return apiError("RemoveRoleFromInstanceProfile", err)
 		}
 	}
 
 	_, err = p.client.DeleteInstanceProfile(&iam.DeleteInstanceProfileInput{InstanceProfileName: (*string)(&id)})
 	if err != nil {
-		return fmt.Errorf("DeleteInstanceProfile failed: %s", err)
+		return apiError("DeleteInstanceProfile", err)
 	}
 	return nil
 }
@@ -98,7 +121,7 @@ func (p awsInstanceProfilePlugin) DescribeInstances(labels map[string]string, pr
 
 	output, err := p.client.ListInstanceProfiles(&iam.ListInstanceProfilesInput{PathPrefix: &path})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("ListInstanceProfiles failed: %s", err)
+		return []instance.Description{}, apiError("ListInstanceProfiles", err)
 	}
 
 	descriptions := []instance.Description{}
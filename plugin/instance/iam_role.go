@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -26,14 +27,24 @@ type createRoleRequest struct {
 	PutRolePolicyInputs []iam.PutRolePolicyInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsRolePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(iamiface.IAMAPI)
+	return p
+}
+
 func (p awsRolePlugin) Validate(req *types.Any) error {
+	request := createRoleRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsRolePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createRoleRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	roleName := newIamName(spec.Tags, p.namespaceTags)
@@ -43,7 +54,7 @@ func (p awsRolePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request.CreateRoleInput.RoleName = aws.String(roleName)
 	output, err := p.client.CreateRole(&request.CreateRoleInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateRole failed: %s", err)
+		return nil, apiError("CreateRole", err)
 	}
 	id := instance.ID(*output.Role.Arn)
 
@@ -51,13 +62,26 @@ func (p awsRolePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 		input.RoleName = aws.String(roleName)
 		input.PolicyName = aws.String(fmt.Sprintf("%s-%d", roleName, i))
 		if _, err := p.client.PutRolePolicy(&input); err != nil {
-			return &id, fmt.Errorf("PutRolePolicy failed: %s", err)
+			return &id, apiError("PutRolePolicy", err)
 		}
 	}
 
 	return &id, nil
 }
 
+// inNamespace returns true if the path of the role has the values of the namespace tags.
+func (p awsRolePlugin) inNamespace(id instance.ID) (bool, error) {
+	if len(p.namespaceTags) == 0 {
+		return true, nil
+	}
+
+	output, err := p.client.GetRole(&iam.GetRoleInput{RoleName: aws.String(arnOrNameToName(string(id)))})
+	if err != nil {
+		return false, apiError("GetRole", err)
+	}
+	return namedInNamespace(aws.StringValue(output.Role.Path), newIamPath, p.namespaceTags), nil
+}
+
 func (p awsRolePlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
@@ -67,7 +91,7 @@ func (p awsRolePlugin) Destroy(id instance.ID) error {
 
 	output, err := p.client.ListRolePolicies(&iam.ListRolePoliciesInput{RoleName: &roleName})
 	if err != nil {
-		return fmt.Errorf("ListRolePolicies failed: %s", err)
+		return apiError("ListRolePolicies", err)
 	}
 
 	for _, policyName := range output.PolicyNames {
@@ -76,7 +100,7 @@ func (p awsRolePlugin) Destroy(id instance.ID) error {
 			RoleName:   &roleName,
 		})
 		if err != nil {
-			return fmt.Errorf("DeleteRolePolicy for %s failed: %s", policyName, err)
+			return wrapError(err, "DeleteRolePolicy for %s failed: %s", *policyName, err)
 		}
 	}
 
@@ -84,7 +108,7 @@ func (p awsRolePlugin) Destroy(id instance.ID) error {
 		RoleName: aws.String(arnOrNameToName(string(id))),
 	})
 	if err != nil {
-		return fmt.Errorf("DeleteRole failed: %s", err)
+		return apiError("DeleteRole", err)
 	}
 	return nil
 }
@@ -95,7 +119,7 @@ func (p awsRolePlugin) DescribeInstances(labels map[string]string, properties bo
 
 	output, err := p.client.ListRoles(&iam.ListRolesInput{PathPrefix: aws.String(path)})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("ListRoles failed: %s", err)
+		return []instance.Description{}, apiError("ListRoles", err)
 	}
 
 	descriptions := []instance.Description{}
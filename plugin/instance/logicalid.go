@@ -0,0 +1,132 @@
+package instance
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+const (
+	// LogicalIDTag is the AWS tag name recording the logical ID of an instance, and identifying the network
+	// interface of an instance under LogicalIDNetworkInterface.
+	LogicalIDTag = "infrakit.logicalID"
+)
+
+// LogicalIDStrategy determines how the logical ID of an instance is realized.
+type LogicalIDStrategy string
+
+const (
+	// LogicalIDPrivateIP uses the logical ID as the private IP address of the instance.
+	LogicalIDPrivateIP = LogicalIDStrategy("private-ip")
+
+	// LogicalIDTagOnly only records the logical ID in the LogicalIDTag of the instance.
+	LogicalIDTagOnly = LogicalIDStrategy("tag")
+
+	// LogicalIDNetworkInterface launches the instance with the existing network interface whose LogicalIDTag is
+	// the logical ID.
+	LogicalIDNetworkInterface = LogicalIDStrategy("eni")
+
+	// LogicalIDElasticIP associates the elastic IP address that is the logical ID with the instance.
+	LogicalIDElasticIP = LogicalIDStrategy("eip")
+)
+
+func validLogicalIDStrategy(strategy LogicalIDStrategy) bool {
+	switch strategy {
+	case "", LogicalIDPrivateIP, LogicalIDTagOnly, LogicalIDNetworkInterface, LogicalIDElasticIP:
+		return true
+	}
+	return false
+}
+
+// logicalIDOf returns the logical ID of the instance.  Instances provisioned with a logical ID carry it in the
+// LogicalIDTag, regardless of strategy; otherwise the private IP address is the logical ID.
+func logicalIDOf(ec2Instance *ec2.Instance) *instance.LogicalID {
+	for _, tag := range ec2Instance.Tags {
+		if aws.StringValue(tag.Key) == LogicalIDTag && tag.Value != nil {
+			return (*instance.LogicalID)(tag.Value)
+		}
+	}
+	return (*instance.LogicalID)(ec2Instance.PrivateIpAddress)
+}
+
+// applyLogicalID updates the RunInstancesInput of the request to realize the logical ID before launch.
+func (p awsInstancePlugin) applyLogicalID(request *CreateInstanceRequest, logicalID instance.LogicalID) error {
+	run := &request.RunInstancesInput
+
+	switch request.LogicalIDStrategy {
+	case "", LogicalIDPrivateIP:
+		if len(run.NetworkInterfaces) > 0 {
+			run.NetworkInterfaces[0].PrivateIpAddress = aws.String(string(logicalID))
+		} else {
+			run.PrivateIpAddress = aws.String(string(logicalID))
+		}
+
+	case LogicalIDNetworkInterface:
+		networkInterfaceID, err := p.findNetworkInterface(logicalID)
+		if err != nil {
+			return err
+		}
+		// The subnet, security groups and addresses are those of the network interface.
+		run.NetworkInterfaces = []*ec2.InstanceNetworkInterfaceSpecification{
+			{
+				DeviceIndex:        aws.Int64(0),
+				NetworkInterfaceId: networkInterfaceID,
+			},
+		}
+		run.SubnetId = nil
+		run.SecurityGroupIds = nil
+		run.SecurityGroups = nil
+		run.PrivateIpAddress = nil
+	}
+	return nil
+}
+
+func (p awsInstancePlugin) findNetworkInterface(logicalID instance.LogicalID) (*string, error) {
+	_, tags := mergeTags(map[string]string{LogicalIDTag: string(logicalID)}, p.namespaceTags)
+
+	filters := []*ec2.Filter{}
+	for key, value := range tags {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(fmt.Sprintf("tag:%s", key)),
+			Values: []*string{aws.String(value)},
+		})
+	}
+
+	output, err := p.client.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{Filters: filters})
+	if err != nil {
+		return nil, apiError("DescribeNetworkInterfaces", err)
+	}
+	if len(output.NetworkInterfaces) != 1 {
+		return nil, fmt.Errorf("Expected one network interface for logical ID %s, found %d",
+			logicalID, len(output.NetworkInterfaces))
+	}
+	return output.NetworkInterfaces[0].NetworkInterfaceId, nil
+}
+
+// associateElasticIP associates the elastic IP address that is the logical ID with the running instance.
+func (p awsInstancePlugin) associateElasticIP(instanceID *string, logicalID instance.LogicalID) error {
+	output, err := p.client.DescribeAddresses(&ec2.DescribeAddressesInput{
+		PublicIps: []*string{aws.String(string(logicalID))},
+	})
+	if err != nil {
+		return apiError("DescribeAddresses", err)
+	}
+	if len(output.Addresses) != 1 {
+		return newError(ErrorClassNotFound, "Elastic IP %s not found", logicalID)
+	}
+	address := output.Addresses[0]
+
+	input := &ec2.AssociateAddressInput{InstanceId: instanceID}
+	if address.AllocationId != nil {
+		input.AllocationId = address.AllocationId
+		input.AllowReassociation = aws.Bool(true)
+	} else {
+		input.PublicIp = address.PublicIp
+	}
+	if _, err := p.client.AssociateAddress(input); err != nil {
+		return apiError("AssociateAddress", err)
+	}
+	return nil
+}
@@ -32,6 +32,9 @@ const (
 	MetadataAvailabilityZone = MetadataKey("http://169.254.169.254/latest/meta-data/placement/availability-zone")
 )
 
+// defaultMetadataURL is the URL of the EC2 instance metadata service.
+const defaultMetadataURL = "http://169.254.169.254"
+
 // MetadataKeyFromSlice returns a new metadata key with the given path, prefixed by the http://hostport/prefix
 func MetadataKeyFromSlice(p []string) MetadataKey {
 	return MetadataKey("http://169.254.169.254/latest/meta-data/" + strings.Join(p, "/"))
@@ -39,7 +42,16 @@ func MetadataKeyFromSlice(p []string) MetadataKey {
 
 // GetMetadata returns the value of the metadata by key
 func GetMetadata(key MetadataKey) (string, error) {
-	resp, err := http.Get(string(key))
+	return getMetadata(defaultMetadataURL, key)
+}
+
+// getMetadata returns the value of the metadata by key, from the instance metadata service at the URL.
+func getMetadata(baseURL string, key MetadataKey) (string, error) {
+	url := string(key)
+	if strings.HasPrefix(url, defaultMetadataURL) {
+		url = baseURL + url[len(defaultMetadataURL):]
+	}
+	resp, err := http.Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -53,7 +65,12 @@ func GetMetadata(key MetadataKey) (string, error) {
 
 // GetRegion returns the AWS region this instance is in.
 func GetRegion() (string, error) {
-	az, err := GetMetadata(MetadataAvailabilityZone)
+	return getRegion(defaultMetadataURL)
+}
+
+// getRegion returns the AWS region this instance is in, from the instance metadata service at the URL.
+func getRegion(baseURL string) (string, error) {
+	az, err := getMetadata(baseURL, MetadataAvailabilityZone)
 	if err != nil {
 		return "", err
 	}
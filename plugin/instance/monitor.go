@@ -5,6 +5,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/deckarep/golang-set"
+	"github.com/docker/infrakit.aws/plugin/metrics"
 	"github.com/docker/infrakit/pkg/spi/event"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
@@ -14,6 +15,12 @@ const (
 	monitorType = event.Type("instance-monitor")
 )
 
+// ProvisionErrorSource is implemented by instance plugins that complete provisioning in the background, and report
+// the failures to do so.
+type ProvisionErrorSource interface {
+	ProvisionErrors() <-chan ProvisionError
+}
+
 // Monitor implements the event spi -- just just calls the Describe to get a
 // list of known instances, and report anything it hasn't seen before, or
 // if anything that disappeared.
@@ -23,6 +30,9 @@ type Monitor struct {
 
 	// Plugin is the instance plugin to use
 	Plugin instance.Plugin
+
+	// DestroyHalts are the halts of destroys to publish, if any.
+	DestroyHalts <-chan DestroyHalt
 }
 
 func (m *Monitor) getEndpoint() interface{} {
@@ -38,6 +48,8 @@ func (m *Monitor) Init() event.Plugin {
 		"found",
 		"lost",
 		"error",
+		"provision-failed",
+		"destroy-halted",
 	) {
 		types.Put(topic, m.getEndpoint, m.topics)
 	}
@@ -66,6 +78,11 @@ func (m *Monitor) PublishOn(c chan<- *event.Event) {
 
 		ticker := time.Tick(2 * time.Second)
 
+		var provisionErrors <-chan ProvisionError
+		if source, is := m.Plugin.(ProvisionErrorSource); is {
+			provisionErrors = source.ProvisionErrors()
+		}
+
 		instances := map[instance.ID]instance.Description{}
 		last := mapset.NewSet()
 
@@ -74,11 +91,26 @@ func (m *Monitor) PublishOn(c chan<- *event.Event) {
 			case <-m.stop:
 				return
 
+			case provisionErr := <-provisionErrors:
+				metrics.MonitorEvents.Inc("provision-failed")
+				c <- event.Event{
+					Type: monitorType,
+					ID:   string(provisionErr.ID),
+				}.Init().Now().WithTopic("provision-failed").WithDataMust(provisionErr)
+
+			case halt := <-m.DestroyHalts:
+				metrics.MonitorEvents.Inc("destroy-halted")
+				c <- event.Event{
+					Type: monitorType,
+					ID:   halt.Resource,
+				}.Init().Now().WithTopic("destroy-halted").WithDataMust(halt)
+
 			case <-ticker:
 
 				described, err := m.Plugin.DescribeInstances(nil, true)
 				if err != nil {
 					log.Warningln("****** err", err)
+					metrics.MonitorEvents.Inc("error")
 					c <- event.Event{
 						Type: monitorType,
 						ID:   "error",
@@ -99,6 +131,7 @@ func (m *Monitor) PublishOn(c chan<- *event.Event) {
 
 				for v := range lost.Iter() {
 					id := v.(instance.ID)
+					metrics.MonitorEvents.Inc("lost")
 					c <- event.Event{
 						Type: monitorType,
 						ID:   string(id),
@@ -106,6 +139,7 @@ func (m *Monitor) PublishOn(c chan<- *event.Event) {
 				}
 				for v := range found.Iter() {
 					id := v.(instance.ID)
+					metrics.MonitorEvents.Inc("found")
 					c <- event.Event{
 						Type: monitorType,
 						ID:   string(id),
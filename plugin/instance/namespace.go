@@ -0,0 +1,151 @@
+package instance
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// namespaced is implemented by the plugins that can tell whether a resource is in their namespace.
+type namespaced interface {
+	// inNamespace returns true if the resource is in the namespace of the plugin, or the error of looking it up,
+	// e.g. of the ErrorClassNotFound class for a missing resource.
+	inNamespace(id instance.ID) (bool, error)
+}
+
+// namedInNamespace returns true if the name of a resource, made by the namer from the values of its tags, has the
+// values of the namespace tags among them.  This is the only sign of the namespace of the resource types without tags.
+func namedInNamespace(name string, namer func(...map[string]string) string, namespaceTags map[string]string) bool {
+	parts := "_" + strings.Trim(name, "/") + "_"
+	for k, v := range namespaceTags {
+		if !strings.Contains(parts, "_"+strings.Trim(namer(map[string]string{k: v}), "/")+"_") {
+			return false
+		}
+	}
+	return true
+}
+
+// ec2TagsOf returns the tags of the EC2 resource.
+func ec2TagsOf(client ec2iface.EC2API, id instance.ID) (map[string]string, error) {
+	output, err := client.DescribeTags(&ec2.DescribeTagsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("resource-id"), Values: []*string{aws.String(string(id))}}},
+	})
+	if err != nil {
+		return nil, apiError("DescribeTags", err)
+	}
+	tags := map[string]string{}
+	for _, tag := range output.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// ec2InNamespace returns true if the EC2 resource has the namespace tags.
+func ec2InNamespace(client ec2iface.EC2API, id instance.ID, namespaceTags map[string]string) (bool, error) {
+	if len(namespaceTags) == 0 {
+		return true, nil
+	}
+	tags, err := ec2TagsOf(client, id)
+	if err != nil {
+		return false, err
+	}
+	if hasTags(tags, namespaceTags) {
+		return true, nil
+	}
+	// Missing resources have no tags either, but they are not found rather than outside the namespace.
+	return false, ec2Describe(client, id)
+}
+
+// ec2Describe describes the EC2 resource by the kind of resource its ID identifies, returning the error of a missing
+// resource.  Resources of other kinds are not described.
+func ec2Describe(client ec2iface.EC2API, id instance.ID) error {
+	ids := []*string{aws.String(string(id))}
+	var operation string
+	var err error
+	switch strings.SplitN(string(id), "-", 2)[0] {
+	case "i":
+		operation = "DescribeInstances"
+		_, err = client.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: ids})
+	case "vol":
+		operation = "DescribeVolumes"
+		_, err = client.DescribeVolumes(&ec2.DescribeVolumesInput{VolumeIds: ids})
+	case "vpc":
+		operation = "DescribeVpcs"
+		_, err = client.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: ids})
+	case "subnet":
+		operation = "DescribeSubnets"
+		_, err = client.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: ids})
+	case "sg":
+		operation = "DescribeSecurityGroups"
+		_, err = client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: ids})
+	case "igw":
+		operation = "DescribeInternetGateways"
+		_, err = client.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{InternetGatewayIds: ids})
+	case "rtb":
+		operation = "DescribeRouteTables"
+		_, err = client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{RouteTableIds: ids})
+	}
+	if err != nil {
+		return apiError(operation, err)
+	}
+	return nil
+}
+
+// namespaceGuardPlugin refuses to destroy or label the resources outside the namespace of a plugin.
+type namespaceGuardPlugin struct {
+	passThroughPlugin
+	override bool
+}
+
+// NewNamespaceGuardPlugin returns the plugin, refusing to destroy or label the resources outside its namespace with
+// an error of the ErrorClassNamespace class, e.g. when an RPC is misrouted or given a bad ID.  With override, e.g. to
+// clean up the resources of another namespace, they are destroyed and labeled with a warning.
+func NewNamespaceGuardPlugin(plugin instance.Plugin, override bool) instance.Plugin {
+	return &namespaceGuardPlugin{passThroughPlugin: passThrough(plugin), override: override}
+}
+
+// check returns an error unless the resource is in the namespace of the plugin, or the check is overridden.
+func (p *namespaceGuardPlugin) check(verb string, id instance.ID) error {
+	inNamespace, err := p.inNamespace(id)
+	if err != nil {
+		if !p.override {
+			return err
+		}
+		log.Warnf("Unable to check the namespace of %s to %s it: %s", id, verb, err)
+	}
+
+	switch {
+	case inNamespace:
+		return nil
+	case p.override:
+		log.Warnf("Overriding the namespace to %s %s", verb, id)
+		return nil
+	}
+	return newError(ErrorClassNamespace, "Refusing to %s %s, which is not in the namespace of the plugin", verb, id)
+}
+
+// WithCall returns the plugin of the call of the plugin, guarded the same way.
+func (p *namespaceGuardPlugin) WithCall(call *audit.Call) instance.Plugin {
+	return &namespaceGuardPlugin{passThroughPlugin: passThrough(audit.WithCall(p.Wrapped(), call)), override: p.override}
+}
+
+// Label labels the instance, if it is in the namespace.
+func (p *namespaceGuardPlugin) Label(id instance.ID, labels map[string]string) error {
+	if err := p.check("label", id); err != nil {
+		return err
+	}
+	return p.Plugin.Label(id, labels)
+}
+
+// Destroy destroys the instance, if it is in the namespace.
+func (p *namespaceGuardPlugin) Destroy(id instance.ID) error {
+	if err := p.check("destroy", id); err != nil {
+		return err
+	}
+	return p.Plugin.Destroy(id)
+}
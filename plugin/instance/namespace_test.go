@@ -0,0 +1,45 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/fake"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedInNamespace(t *testing.T) {
+	namespace := map[string]string{"infrakit.namespace": "prod.us"}
+
+	require.True(t, namedInNamespace("workers_prod-us", newQueueName, namespace))
+	require.True(t, namedInNamespace("prod-us", newQueueName, namespace))
+	require.False(t, namedInNamespace("workers_prod-usa", newQueueName, namespace))
+	require.False(t, namedInNamespace("workers_staging", newQueueName, namespace))
+	require.True(t, namedInNamespace("/prod.us_workers/", newIamPath, namespace))
+	require.False(t, namedInNamespace("/", newIamPath, namespace))
+	require.True(t, namedInNamespace("anything", newQueueName, map[string]string{}))
+}
+
+func TestNamespaceGuard(t *testing.T) {
+	client := fake.NewEC2()
+	volume, err := client.CreateVolume(&ec2.CreateVolumeInput{AvailabilityZone: aws.String("us-west-2a"),
+		Size: aws.Int64(10)})
+	require.NoError(t, err)
+	id := instance.ID(*volume.VolumeId)
+	require.NoError(t, ec2CreateTags(client, id, map[string]string{"infrakit.namespace": "staging"}))
+
+	guarded := NewNamespaceGuardPlugin(NewVolumePlugin(client, map[string]string{"infrakit.namespace": "prod"}), false)
+	require.Equal(t, ErrorClassNamespace, ClassOf(guarded.Label(id, map[string]string{"label": "value"})))
+	require.Equal(t, ErrorClassNamespace, ClassOf(guarded.Destroy(id)))
+	require.Equal(t, ErrorClassNotFound, ClassOf(guarded.Destroy(instance.ID("vol-missing"))))
+	require.Equal(t, ErrorClassNotFound, ClassOf(guarded.Label(instance.ID("vol-missing"), nil)))
+
+	overridden := NewNamespaceGuardPlugin(NewVolumePlugin(client, map[string]string{"infrakit.namespace": "prod"}), true)
+	require.NoError(t, overridden.Destroy(id))
+
+	volumes, err := client.DescribeVolumes(&ec2.DescribeVolumesInput{})
+	require.NoError(t, err)
+	require.Empty(t, volumes.Volumes)
+}
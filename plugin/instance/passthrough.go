@@ -0,0 +1,42 @@
+package instance
+
+import (
+	"github.com/docker/infrakit.aws/plugin/passthrough"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// passThroughPlugin is a passthrough.Plugin that also passes the calls of the optional interfaces of the plugins of
+// this package through, so that decorators embedding it don't hide them from the decorators and monitors they are
+// wrapped by.
+type passThroughPlugin struct {
+	passthrough.Plugin
+}
+
+// passThrough returns the pass-through of the plugin, for decorators to embed.
+func passThrough(plugin instance.Plugin) passThroughPlugin {
+	return passThroughPlugin{passthrough.Plugin{Plugin: plugin}}
+}
+
+// ProvisionErrors returns the provision errors of the plugin, if any.
+func (p passThroughPlugin) ProvisionErrors() <-chan ProvisionError {
+	if source, is := p.Wrapped().(ProvisionErrorSource); is {
+		return source.ProvisionErrors()
+	}
+	return nil
+}
+
+// inNamespace returns true if the resource is in the namespace of the plugin, if it can tell.
+func (p passThroughPlugin) inNamespace(id instance.ID) (bool, error) {
+	if checker, is := p.Wrapped().(namespaced); is {
+		return checker.inNamespace(id)
+	}
+	return false, nil
+}
+
+// protection returns the protection of the resource by the plugin, if any.
+func (p passThroughPlugin) protection(id instance.ID) (string, error) {
+	if checker, is := p.Wrapped().(protectable); is {
+		return checker.protection(id)
+	}
+	return "", nil
+}
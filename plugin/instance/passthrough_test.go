@@ -0,0 +1,32 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/docker/infrakit.aws/fake"
+	"github.com/docker/infrakit/pkg/spi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoratorsPassThrough(t *testing.T) {
+	plugin := NewInstancePlugin(fake.NewEC2(), map[string]string{"infrakit.namespace": "test"})
+
+	// The decorators are stacked as by the instance plugin command, in dry run mode.
+	decorated := NewSafetyPlugin("ec2-instance", plugin, NewDestroyBreaker(0, 0))
+	decorated = NewNamespaceGuardPlugin(decorated, false)
+	decorated = NewDryRunPlugin("ec2-instance", decorated)
+	decorated = NewClassifiedPlugin(decorated)
+
+	require.Equal(t, plugin.(ProvisionErrorSource).ProvisionErrors(), decorated.(ProvisionErrorSource).ProvisionErrors())
+	require.Equal(t, plugin.(spi.Vendor).VendorInfo(), decorated.(spi.Vendor).VendorInfo())
+	require.Equal(t, plugin.(spi.InputExample).ExampleProperties(), decorated.(spi.InputExample).ExampleProperties())
+
+	id := provisionInstance(t, plugin, false)
+	require.NoError(t, plugin.Label(id, map[string]string{ProtectedTag: "true"}))
+	inNamespace, err := decorated.(namespaced).inNamespace(id)
+	require.NoError(t, err)
+	require.True(t, inNamespace)
+	protection, err := decorated.(protectable).protection(id)
+	require.NoError(t, err)
+	require.NotEmpty(t, protection)
+}
@@ -0,0 +1,231 @@
+package instance
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+const (
+	// groupTag is the tag the group plugin identifies the instances of a group with.
+	groupTag = "infrakit.group"
+)
+
+// groupTags returns the tags identifying the group of the instance with the given tags.  The group plugin also tags
+// instances with the SHA of their configuration, which differs across instances of the group during an update.
+func groupTags(tags map[string]string) map[string]string {
+	if group, has := tags[groupTag]; has {
+		return map[string]string{groupTag: group}
+	}
+	return tags
+}
+
+// pendingPlacementTTL is how long a launched instance is counted in its placement until it is described.
+var pendingPlacementTTL = time.Minute
+
+// pendingPlacement is a placement of an instance of a group being provisioned, which DescribeInstances doesn't count
+// yet.
+type pendingPlacement struct {
+	group      string
+	placement  Placement
+	instanceID string
+	launched   time.Time
+}
+
+// pendingPlacements counts the placements of instances being provisioned, so that concurrent Provisions of a group,
+// e.g. on a scale up, spread across availability zones rather than all picking the least populated one.
+type pendingPlacements struct {
+	lock    sync.Mutex
+	pending map[*pendingPlacement]bool
+}
+
+func newPendingPlacements() *pendingPlacements {
+	return &pendingPlacements{pending: map[*pendingPlacement]bool{}}
+}
+
+// count adds the pending placements of the group to the counts of its described instances, and forgets those
+// placements whose instances are described or were launched long ago.  It must be called with the lock held.
+func (p *pendingPlacements) count(group string, described map[string]bool, perZone, perSubnet map[string]int) {
+	for pending := range p.pending {
+		if pending.group != group {
+			continue
+		}
+		if pending.instanceID != "" &&
+			(described[pending.instanceID] || time.Since(pending.launched) > pendingPlacementTTL) {
+			delete(p.pending, pending)
+			continue
+		}
+		perZone[pending.placement.AvailabilityZone]++
+		perSubnet[pending.placement.SubnetID]++
+	}
+}
+
+// launched records the instance launched for the pending placement, or forgets the placement if the instance wasn't
+// launched.
+func (p *pendingPlacements) launched(pending *pendingPlacement, ec2Instance *ec2.Instance) {
+	if p == nil || pending == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if ec2Instance == nil {
+		delete(p.pending, pending)
+		return
+	}
+	pending.instanceID = aws.StringValue(ec2Instance.InstanceId)
+	pending.launched = time.Now()
+	if ec2Instance.Placement != nil && ec2Instance.Placement.AvailabilityZone != nil {
+		pending.placement.AvailabilityZone = *ec2Instance.Placement.AvailabilityZone
+	}
+	if ec2Instance.SubnetId != nil {
+		pending.placement.SubnetID = *ec2Instance.SubnetId
+	}
+}
+
+// balanceSubnets orders the Subnets of the request into Placements, from the availability zone with the fewest live
+// or pending instances of the group to the one with the most.  Subnets in the same zone are ordered by their own
+// number of instances, and otherwise keep their order.  Only the subnets containing the private IP address of the
+// logical ID, and those in the zone of the EBS volumes of the attachments, are placements.  The first placement is
+// pending until the instance launched in it is recorded with pendingPlacements.launched.
+func (p awsInstancePlugin) balanceSubnets(
+	spec instance.Spec,
+	request *CreateInstanceRequest,
+	attachments []ebsAttachment) (*pendingPlacement, error) {
+
+	if len(request.Subnets) == 0 {
+		return nil, nil
+	}
+
+	subnets, err := p.client.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice(request.Subnets)})
+	if err != nil {
+		return nil, apiError("DescribeSubnets", err)
+	}
+	zones := map[string]string{}
+	cidrs := map[string]string{}
+	for _, subnet := range subnets.Subnets {
+		zones[aws.StringValue(subnet.SubnetId)] = aws.StringValue(subnet.AvailabilityZone)
+		cidrs[aws.StringValue(subnet.SubnetId)] = aws.StringValue(subnet.CidrBlock)
+	}
+
+	placements := []Placement{}
+	for _, subnet := range request.Subnets {
+		zone, has := zones[subnet]
+		if !has {
+			return nil, newError(ErrorClassNotFound, "Subnet %s not found", subnet)
+		}
+		placements = append(placements, Placement{AvailabilityZone: zone, SubnetID: subnet})
+	}
+
+	// A private IP address is only valid in the subnet containing it.
+	if spec.LogicalID != nil &&
+		(request.LogicalIDStrategy == "" || request.LogicalIDStrategy == LogicalIDPrivateIP) {
+		ip := net.ParseIP(string(*spec.LogicalID))
+		placements = filterPlacements(placements, func(placement Placement) bool {
+			_, cidr, err := net.ParseCIDR(cidrs[placement.SubnetID])
+			return ip != nil && err == nil && cidr.Contains(ip)
+		})
+		if len(placements) == 0 {
+			return nil, newError(ErrorClassInvalidSpec, "None of the Subnets contains the private IP address %s",
+				*spec.LogicalID)
+		}
+	}
+
+	// Volumes are migrated to another zone only if no subnet is in theirs.
+	volumeZones := map[string]bool{}
+	for _, attachment := range attachments {
+		if attachment.Volume != nil {
+			volumeZones[aws.StringValue(attachment.Volume.AvailabilityZone)] = true
+		}
+	}
+	if len(volumeZones) > 0 {
+		inVolumeZones := filterPlacements(placements, func(placement Placement) bool {
+			return volumeZones[placement.AvailabilityZone]
+		})
+		if len(inVolumeZones) > 0 {
+			placements = inVolumeZones
+		} else if !request.EBSAttachment.MigrateAcrossZones {
+			return nil, newError(ErrorClassInvalidSpec, "None of the Subnets is in the availability zone of the volumes")
+		}
+	}
+
+	group := groupTags(spec.Tags)
+	described := map[string]bool{}
+	perZone := map[string]int{}
+	perSubnet := map[string]int{}
+	var nextToken *string
+	for {
+		result, err := p.client.DescribeInstances(
+			describeGroupRequest(p.namespaceTags, group, liveInstanceStates, nextToken))
+		if err != nil {
+			return nil, apiError("DescribeInstances", err)
+		}
+		for _, reservation := range result.Reservations {
+			for _, ec2Instance := range reservation.Instances {
+				described[aws.StringValue(ec2Instance.InstanceId)] = true
+				if ec2Instance.Placement != nil {
+					perZone[aws.StringValue(ec2Instance.Placement.AvailabilityZone)]++
+				}
+				perSubnet[aws.StringValue(ec2Instance.SubnetId)]++
+			}
+		}
+		if result.NextToken == nil {
+			break
+		}
+		nextToken = result.NextToken
+	}
+
+	key := newUnrestrictedName(group)
+	if p.pendingPlacements != nil {
+		p.pendingPlacements.lock.Lock()
+		defer p.pendingPlacements.lock.Unlock()
+		p.pendingPlacements.count(key, described, perZone, perSubnet)
+	}
+	sort.Stable(&byPopulation{placements: placements, perZone: perZone, perSubnet: perSubnet})
+	request.Placements = placements
+
+	if p.pendingPlacements == nil {
+		return nil, nil
+	}
+	pending := &pendingPlacement{group: key, placement: placements[0]}
+	p.pendingPlacements.pending[pending] = true
+	return pending, nil
+}
+
+// filterPlacements returns the placements to keep, in order.
+func filterPlacements(placements []Placement, keep func(Placement) bool) []Placement {
+	kept := []Placement{}
+	for _, placement := range placements {
+		if keep(placement) {
+			kept = append(kept, placement)
+		}
+	}
+	return kept
+}
+
+type byPopulation struct {
+	placements []Placement
+	perZone    map[string]int
+	perSubnet  map[string]int
+}
+
+func (b *byPopulation) Len() int {
+	return len(b.placements)
+}
+
+func (b *byPopulation) Less(i, j int) bool {
+	zi, zj := b.perZone[b.placements[i].AvailabilityZone], b.perZone[b.placements[j].AvailabilityZone]
+	if zi != zj {
+		return zi < zj
+	}
+	return b.perSubnet[b.placements[i].SubnetID] < b.perSubnet[b.placements[j].SubnetID]
+}
+
+func (b *byPopulation) Swap(i, j int) {
+	b.placements[i], b.placements[j] = b.placements[j], b.placements[i]
+}
@@ -0,0 +1,156 @@
+package instance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/fake"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionBalancesSubnets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	groupInstance := func(zone, subnet string) *ec2.Instance {
+		return &ec2.Instance{
+			Placement: &ec2.Placement{AvailabilityZone: aws.String(zone)},
+			SubnetId:  aws.String(subnet),
+		}
+	}
+
+	instanceID := "test-id"
+	var run *ec2.RunInstancesInput
+	gomock.InOrder(
+		clientMock.EXPECT().DescribeSubnets(&ec2.DescribeSubnetsInput{
+			SubnetIds: aws.StringSlice([]string{"subnet-a", "subnet-b", "subnet-c"}),
+		}).Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+			{SubnetId: aws.String("subnet-a"), AvailabilityZone: aws.String("us-west-2a")},
+			{SubnetId: aws.String("subnet-b"), AvailabilityZone: aws.String("us-west-2b")},
+			{SubnetId: aws.String("subnet-c"), AvailabilityZone: aws.String("us-west-2c")},
+		}}, nil),
+		clientMock.EXPECT().DescribeInstances(describeGroupRequest(testNamespace,
+			map[string]string{groupTag: "workers"}, liveInstanceStates, nil)).
+			Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{
+				groupInstance("us-west-2a", "subnet-a"),
+				groupInstance("us-west-2a", "subnet-a"),
+				groupInstance("us-west-2b", "subnet-b"),
+				groupInstance("us-west-2c", "subnet-c"),
+				groupInstance("us-west-2c", "subnet-c"),
+			}}}}, nil),
+		clientMock.EXPECT().RunInstances(gomock.Any()).
+			Do(func(input *ec2.RunInstancesInput) { run = input }).
+			Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}, nil),
+		clientMock.EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil),
+	)
+
+	pluginImpl := NewInstancePlugin(clientMock, testNamespace)
+	_, err := pluginImpl.Provision(instance.Spec{
+		Properties: types.AnyString(`{"Subnets": ["subnet-a", "subnet-b", "subnet-c"]}`),
+		Tags:       map[string]string{groupTag: "workers", "infrakit.config_sha": "abc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "subnet-b", *run.SubnetId)
+	require.Equal(t, "us-west-2b", *run.Placement.AvailabilityZone)
+
+	// Subnets and Placements are exclusive.
+	require.Error(t, pluginImpl.Validate(types.AnyString(
+		`{"Subnets": ["subnet-a"], "Placements": [{"AvailabilityZone": "us-west-2a"}]}`)))
+}
+
+func TestProvisionBalancesPendingInstances(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clientMock := mock_ec2.NewMockEC2API(ctrl)
+
+	// The instances launched are not described yet, as on a burst of Provisions.
+	zones := []string{}
+	clientMock.EXPECT().DescribeSubnets(gomock.Any()).AnyTimes().
+		Return(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+			{SubnetId: aws.String("subnet-a"), AvailabilityZone: aws.String("us-west-2a")},
+			{SubnetId: aws.String("subnet-b"), AvailabilityZone: aws.String("us-west-2b")},
+			{SubnetId: aws.String("subnet-c"), AvailabilityZone: aws.String("us-west-2c")},
+		}}, nil)
+	clientMock.EXPECT().DescribeInstances(gomock.Any()).AnyTimes().Return(&ec2.DescribeInstancesOutput{}, nil)
+	clientMock.EXPECT().RunInstances(gomock.Any()).Times(4).
+		Do(func(input *ec2.RunInstancesInput) { zones = append(zones, *input.Placement.AvailabilityZone) }).
+		Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: aws.String("test-id")}}}, nil)
+	clientMock.EXPECT().CreateTags(gomock.Any()).AnyTimes().Return(&ec2.CreateTagsOutput{}, nil)
+
+	pluginImpl := NewInstancePlugin(clientMock, testNamespace)
+	for i := 0; i < 4; i++ {
+		_, err := pluginImpl.Provision(instance.Spec{
+			Properties: types.AnyString(`{"Subnets": ["subnet-a", "subnet-b", "subnet-c"]}`),
+			Tags:       map[string]string{groupTag: "workers"},
+		})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"us-west-2a", "us-west-2b", "us-west-2c", "us-west-2a"}, zones)
+}
+
+func TestProvisionPinsSubnets(t *testing.T) {
+	interval := runningPollInterval
+	runningPollInterval = time.Millisecond
+	defer func() { runningPollInterval = interval }()
+
+	client := fake.NewEC2()
+	vpc, err := client.CreateVpc(&ec2.CreateVpcInput{CidrBlock: aws.String("10.0.0.0/16")})
+	require.NoError(t, err)
+	subnets := []string{}
+	for i, zone := range []string{"us-west-2a", "us-west-2b"} {
+		subnet, err := client.CreateSubnet(&ec2.CreateSubnetInput{
+			VpcId:            vpc.Vpc.VpcId,
+			CidrBlock:        aws.String(fmt.Sprintf("10.0.%d.0/24", i)),
+			AvailabilityZone: aws.String(zone),
+		})
+		require.NoError(t, err)
+		subnets = append(subnets, *subnet.Subnet.SubnetId)
+	}
+	properties := types.AnyString(fmt.Sprintf(`{
+		"RunInstancesInput": {"ImageId": "%s", "InstanceType": "t2.micro"},
+		"Subnets": ["%s", "%s"]
+	}`, fake.DefaultImageID, subnets[0], subnets[1]))
+
+	options := DefaultInstancePluginOptions
+	options.CompletionWorkers = 0
+	pluginImpl := NewInstancePluginWithOptions(client, testNamespace, options)
+	subnetOf := func(id *instance.ID) string {
+		output, err := client.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{(*string)(id)}})
+		require.NoError(t, err)
+		return *output.Reservations[0].Instances[0].SubnetId
+	}
+
+	// The private IP address of the logical ID is only valid in the second subnet.
+	logicalID := instance.LogicalID("10.0.1.10")
+	id, err := pluginImpl.Provision(instance.Spec{Properties: properties, Tags: tags, LogicalID: &logicalID})
+	require.NoError(t, err)
+	require.Equal(t, subnets[1], subnetOf(id))
+
+	logicalID = instance.LogicalID("10.1.0.10")
+	_, err = pluginImpl.Provision(instance.Spec{Properties: properties, Tags: tags, LogicalID: &logicalID})
+	require.Equal(t, ErrorClassInvalidSpec, ClassOf(err))
+
+	// The volume of the attachment is in the zone of the second subnet.
+	volume, err := client.CreateVolume(&ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String("us-west-2b"),
+		Size:             aws.Int64(10),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ec2CreateTags(client, instance.ID(*volume.VolumeId), testNamespace,
+		map[string]string{VolumeTag: "data"}))
+	id, err = pluginImpl.Provision(instance.Spec{
+		Properties:  properties,
+		Tags:        tags,
+		Attachments: []instance.Attachment{{ID: "data", Type: AttachmentEBSVolume}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, subnets[1], subnetOf(id))
+}
@@ -0,0 +1,147 @@
+package instance
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// throttlingErrorCodes are the codes of errors AWS APIs return when requests exceed their rate limit.
+var throttlingErrorCodes = map[string]bool{
+	"RequestLimitExceeded":     true,
+	"Throttling":               true,
+	"ThrottlingException":      true,
+	"RequestThrottled":         true,
+	"TooManyRequestsException": true,
+}
+
+// rateRecovery is the fraction of the configured rate a throttled rate recovers by per second.
+const rateRecovery = 0.1
+
+// RateLimitOptions configure the client-side rate limit of API requests, per service.
+type RateLimitOptions struct {
+	// Rate is the number of requests per second to each service.  Requests are not limited if it is 0.
+	Rate float64
+
+	// Burst is the number of requests that can be made at once.
+	Burst int
+
+	// Reserve is the number of requests of the burst that are reserved for requests other than describes, which
+	// therefore take priority.
+	Reserve int
+}
+
+// rateLimiter limits the rate of requests with a token bucket per service.  Describe, list and get requests only
+// take a token when more than the reserved tokens are left.  The rate of a service is halved when it throttles
+// requests, and recovers gradually.
+type rateLimiter struct {
+	options RateLimitOptions
+	now     func() time.Time
+	sleep   func(time.Duration)
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(options RateLimitOptions) *rateLimiter {
+	if options.Burst < 1 {
+		options.Burst = 1
+	}
+	if options.Reserve >= options.Burst {
+		options.Reserve = options.Burst - 1
+	}
+	return &rateLimiter{
+		options: options,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// install installs the rate limiter as handlers of the requests.
+func (l *rateLimiter) install(handlers *request.Handlers) {
+	handlers.Sign.PushFront(func(r *request.Request) {
+		l.wait(r.ClientInfo.ServiceName, isDescribe(r.Operation.Name))
+	})
+	handlers.Retry.PushFront(func(r *request.Request) {
+		if awsErr, ok := r.Error.(awserr.Error); ok && throttlingErrorCodes[awsErr.Code()] {
+			l.throttled(r.ClientInfo.ServiceName)
+		}
+	})
+}
+
+func isDescribe(operation string) bool {
+	for _, prefix := range []string{"Describe", "List", "Get"} {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket returns the refilled bucket of the service.  Must be called with the lock held.
+func (l *rateLimiter) bucket(service string) *tokenBucket {
+	now := l.now()
+	bucket, has := l.buckets[service]
+	if !has {
+		bucket = &tokenBucket{rate: l.options.Rate, tokens: float64(l.options.Burst), last: now}
+		l.buckets[service] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.last = now
+	bucket.tokens += elapsed * bucket.rate
+	if bucket.tokens > float64(l.options.Burst) {
+		bucket.tokens = float64(l.options.Burst)
+	}
+	bucket.rate += elapsed * l.options.Rate * rateRecovery
+	if bucket.rate > l.options.Rate {
+		bucket.rate = l.options.Rate
+	}
+	return bucket
+}
+
+// wait waits for a token of the service.
+func (l *rateLimiter) wait(service string, describe bool) {
+	threshold := 1.0
+	if describe {
+		threshold += float64(l.options.Reserve)
+	}
+
+	for {
+		l.lock.Lock()
+		bucket := l.bucket(service)
+		if bucket.tokens >= threshold {
+			bucket.tokens--
+			l.lock.Unlock()
+			return
+		}
+		wait := time.Duration((threshold - bucket.tokens) / bucket.rate * float64(time.Second))
+		l.lock.Unlock()
+
+		l.sleep(wait)
+	}
+}
+
+// throttled halves the rate of the service, down to a tenth of the configured rate.
+func (l *rateLimiter) throttled(service string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	bucket := l.bucket(service)
+	bucket.rate /= 2
+	if min := l.options.Rate * rateRecovery; bucket.rate < min {
+		bucket.rate = min
+	}
+	log.Warnf("Requests to %s are throttled, reducing the rate to %.1f/s", service, bucket.rate)
+}
@@ -0,0 +1,65 @@
+package instance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(options RateLimitOptions) (*rateLimiter, *time.Duration) {
+	now := time.Unix(0, 0)
+	slept := time.Duration(0)
+	limiter := newRateLimiter(options)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(d time.Duration) {
+		now = now.Add(d)
+		slept += d
+	}
+	return limiter, &slept
+}
+
+func TestRateLimiterReservesTokensForMutatingRequests(t *testing.T) {
+	limiter, slept := newTestRateLimiter(RateLimitOptions{Rate: 10, Burst: 4, Reserve: 2})
+
+	// Describes leave the reserved tokens.
+	limiter.wait("ec2", true)
+	limiter.wait("ec2", true)
+	require.Equal(t, time.Duration(0), *slept)
+
+	// Other requests take them.
+	limiter.wait("ec2", false)
+	limiter.wait("ec2", false)
+	require.Equal(t, time.Duration(0), *slept)
+
+	// Describes wait for the reserve to refill.
+	limiter.wait("ec2", true)
+	require.Equal(t, 300*time.Millisecond, *slept)
+
+	// Services are limited independently.
+	limiter.wait("autoscaling", false)
+	require.Equal(t, 300*time.Millisecond, *slept)
+}
+
+func TestRateLimiterBacksOffWhenThrottled(t *testing.T) {
+	limiter, slept := newTestRateLimiter(RateLimitOptions{Rate: 10, Burst: 1})
+
+	limiter.wait("ec2", false)
+	limiter.wait("ec2", false)
+	require.Equal(t, 100*time.Millisecond, *slept)
+
+	limiter.throttled("ec2")
+	require.Equal(t, 5.0, limiter.buckets["ec2"].rate)
+	for i := 0; i < 10; i++ {
+		limiter.throttled("ec2")
+	}
+	require.Equal(t, 1.0, limiter.buckets["ec2"].rate)
+
+	// The rate recovers over time.
+	limiter.sleep(5 * time.Second)
+	limiter.wait("ec2", false)
+	require.Equal(t, 6.0, limiter.buckets["ec2"].rate)
+	limiter.sleep(time.Minute)
+	limiter.wait("ec2", false)
+	require.Equal(t, 10.0, limiter.buckets["ec2"].rate)
+}
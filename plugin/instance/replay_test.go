@@ -0,0 +1,58 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/docker/infrakit.aws/plugin/recording"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/stretchr/testify/require"
+)
+
+var productionNamespace = map[string]string{"infrakit.namespace": "production"}
+
+// replaySession returns a session replaying the fixture, and its replayer.
+func replaySession(t *testing.T, fixture string) (client.ConfigProvider, *recording.Replayer) {
+	sess, err := NewSession(SessionOptions{Replay: fixture, Region: "us-west-2"})
+	require.NoError(t, err)
+	replayer, is := sess.(*session.Session).Config.HTTPClient.Transport.(*recording.Replayer)
+	require.True(t, is)
+	return sess, replayer
+}
+
+func TestReplayDescribeInstancesPages(t *testing.T) {
+	sess, replayer := replaySession(t, "testdata/describe_instances_pages.jsonl")
+
+	descriptions, err := NewInstancePlugin(ec2.New(sess), productionNamespace).DescribeInstances(
+		map[string]string{"infrakit.group": "workers"}, false)
+	require.NoError(t, err)
+
+	ids := []instance.ID{}
+	for _, description := range descriptions {
+		ids = append(ids, description.ID)
+	}
+	require.Equal(t, []instance.ID{
+		"i-0b22a22eec53b9321", "i-0c33b33ffd64ca432", "i-0d44c44aae75db543", "i-0e55d55bbf86ec654",
+	}, ids)
+	require.Equal(t, "10.0.2.9", string(*descriptions[2].LogicalID))
+	require.Empty(t, replayer.Remaining())
+}
+
+func TestReplayDescribeLoadBalancersOfNamespace(t *testing.T) {
+	sess, replayer := replaySession(t, "testdata/describe_load_balancers.jsonl")
+
+	descriptions, err := NewLoadBalancerPlugin(elb.New(sess), productionNamespace).DescribeInstances(
+		map[string]string{"infrakit.group": "workers"}, false)
+	require.NoError(t, err)
+	require.Len(t, descriptions, 1)
+	require.Equal(t, instance.ID("production-workers"), descriptions[0].ID)
+	require.Empty(t, replayer.Remaining())
+}
+
+func TestRecordAndReplayExclusive(t *testing.T) {
+	_, err := NewSession(SessionOptions{Record: "a.jsonl", Replay: "b.jsonl", Region: "us-west-2"})
+	require.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package instance
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// roleSessionName is the name of the sessions of assumed roles, as shown in CloudTrail.
+const roleSessionName = "infrakit"
+
+// Role is an IAM role assumed to access AWS, e.g. in another account.
+type Role struct {
+	// ARN is the ARN of the role.
+	ARN string
+
+	// ExternalID is the external ID the trust policy of the role may require.
+	ExternalID string
+}
+
+// ParseRole parses a role given as <role ARN>[ <external ID>].  Neither the ARN nor the external ID can have spaces,
+// while about any other separator, e.g. =, can be part of them.
+func ParseRole(s string) (Role, error) {
+	parts := strings.Fields(s)
+	if len(parts) == 0 || len(parts) > 2 {
+		return Role{}, newError(ErrorClassInvalidSpec, "Invalid role: %s", s)
+	}
+	role := Role{ARN: parts[0]}
+	if len(parts) == 2 {
+		role.ExternalID = parts[1]
+	}
+	if role.Account() == "" {
+		return role, newError(ErrorClassInvalidSpec, "Invalid role ARN: %s", role.ARN)
+	}
+	return role, nil
+}
+
+// Account returns the ID of the account of the role.
+func (r Role) Account() string {
+	// arn:aws:iam::<account>:role/<name>
+	parts := strings.Split(r.ARN, ":")
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
+
+// NewRoleSession returns a session with the config that assumes the role, using the credentials of the base session.
+// The credentials of the role are refreshed before they expire.
+func NewRoleSession(base client.ConfigProvider, config *aws.Config, role Role) *session.Session {
+	log.Infof("Assuming role %s", role.ARN)
+
+	credentials := stscreds.NewCredentials(base, role.ARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = roleSessionName
+		if role.ExternalID != "" {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+	})
+	return session.New(config.Copy().WithCredentials(credentials))
+}
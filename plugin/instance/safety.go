@@ -0,0 +1,196 @@
+package instance
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit.aws/plugin/audit"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+// protectable is implemented by the plugins of resources that can be protected from being destroyed.
+type protectable interface {
+	// protection returns what protects the resource from being destroyed, or an empty string if it isn't protected.
+	protection(id instance.ID) (string, error)
+}
+
+// protectedByTag returns the protection of a resource with the tags by the ProtectedTag, if any.
+func protectedByTag(tags map[string]string) string {
+	if protected, _ := strconv.ParseBool(tags[ProtectedTag]); protected {
+		return "tag " + ProtectedTag
+	}
+	return ""
+}
+
+// ec2Protection returns the protection of the EC2 resource by the ProtectedTag, if any.
+func ec2Protection(client ec2iface.EC2API, id instance.ID) (string, error) {
+	tags, err := ec2TagsOf(client, id)
+	if err != nil {
+		return "", err
+	}
+	return protectedByTag(tags), nil
+}
+
+// DestroyHalt is the halt of the destroys of a resource type by a DestroyBreaker.
+type DestroyHalt struct {
+	// Resource is the resource type whose destroys are halted.
+	Resource string
+
+	// ID is the resource whose destroy tripped the breaker.
+	ID instance.ID
+
+	// Limit is the number of destroys allowed in the Window.
+	Limit  int
+	Window string
+}
+
+func (h DestroyHalt) Error() string {
+	return fmt.Sprintf("Destroying %s would exceed %d destroys of %s in %s, destroys are halted",
+		h.ID, h.Limit, h.Resource, h.Window)
+}
+
+// destroy is a destroy counted by a DestroyBreaker.
+type destroy struct {
+	id instance.ID
+	at time.Time
+}
+
+// DestroyBreaker caps the destroys of each resource type in a sliding window, e.g. so that a bad group config
+// can't destroy a fleet.  Once a destroy would exceed the cap, it and all the following destroys of the resource
+// type are refused, until the breaker is reset, and the halt is reported.
+type DestroyBreaker struct {
+	limit  int
+	window time.Duration
+
+	lock     sync.Mutex
+	destroys map[string][]destroy
+	halted   map[string]bool
+	halts    chan DestroyHalt
+}
+
+// NewDestroyBreaker returns a breaker allowing limit destroys of each resource type in the window, or any number if
+// limit is 0.
+func NewDestroyBreaker(limit int, window time.Duration) *DestroyBreaker {
+	return &DestroyBreaker{
+		limit:    limit,
+		window:   window,
+		destroys: map[string][]destroy{},
+		halted:   map[string]bool{},
+		halts:    make(chan DestroyHalt, 64),
+	}
+}
+
+// DestroyHalts returns the halts of destroys, as the breaker trips.
+func (b *DestroyBreaker) DestroyHalts() <-chan DestroyHalt {
+	return b.halts
+}
+
+// Reset resumes the halted destroys, and forgets the destroys in the window, e.g. once an operator has fixed the
+// config that tripped the breaker.
+func (b *DestroyBreaker) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for resource := range b.halted {
+		log.Warnln("Resuming the destroys of", resource)
+	}
+	b.destroys = map[string][]destroy{}
+	b.halted = map[string]bool{}
+}
+
+// admit records the destroy of the resource of the type, or returns an error if the destroys of the type are
+// halted.
+func (b *DestroyBreaker) admit(resource string, id instance.ID) error {
+	if b.limit <= 0 {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.halted[resource] {
+		return newError(ErrorClassHalted, "Destroys of %s are halted, refusing to destroy %s", resource, id)
+	}
+
+	now := time.Now()
+	recent := []destroy{}
+	for _, destroyed := range b.destroys[resource] {
+		if now.Sub(destroyed.at) < b.window {
+			recent = append(recent, destroyed)
+		}
+	}
+	if len(recent) >= b.limit {
+		b.halted[resource] = true
+		halt := DestroyHalt{Resource: resource, ID: id, Limit: b.limit, Window: b.window.String()}
+		log.Error(halt)
+		select {
+		case b.halts <- halt:
+		default:
+		}
+		return newError(ErrorClassHalted, "%s", halt)
+	}
+	b.destroys[resource] = append(recent, destroy{id: id, at: now})
+	return nil
+}
+
+// forget forgets the destroy of the resource of the type admitted last, e.g. because it failed, so that retrying a
+// destroy that fails doesn't trip the breaker.
+func (b *DestroyBreaker) forget(resource string, id instance.ID) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	destroys := b.destroys[resource]
+	for i := len(destroys) - 1; i >= 0; i-- {
+		if destroys[i].id == id {
+			b.destroys[resource] = append(destroys[:i:i], destroys[i+1:]...)
+			return
+		}
+	}
+}
+
+// safetyPlugin refuses to destroy protected resources, and caps the destroys of a resource type.
+type safetyPlugin struct {
+	passThroughPlugin
+	resource string
+	breaker  *DestroyBreaker
+}
+
+// NewSafetyPlugin returns the plugin of the resource type, refusing to destroy the resources protected by the
+// ProtectedTag, or by their plugin, with an error of the ErrorClassProtected class, and capping their destroys with
+// the breaker.  Only the resource types with tags can be protected.
+func NewSafetyPlugin(resource string, plugin instance.Plugin, breaker *DestroyBreaker) instance.Plugin {
+	return &safetyPlugin{passThroughPlugin: passThrough(plugin), resource: resource, breaker: breaker}
+}
+
+// WithCall returns the plugin of the call of the plugin, sharing the breaker.
+func (p *safetyPlugin) WithCall(call *audit.Call) instance.Plugin {
+	return &safetyPlugin{
+		passThroughPlugin: passThrough(audit.WithCall(p.Wrapped(), call)),
+		resource:          p.resource,
+		breaker:           p.breaker,
+	}
+}
+
+// Destroy destroys the instance, unless it is protected or the destroys of the resource type are halted.  Only the
+// destroys that succeed count towards the cap.
+func (p *safetyPlugin) Destroy(id instance.ID) error {
+	protection, err := p.protection(id)
+	if err != nil {
+		return err
+	}
+	if protection != "" {
+		return newError(ErrorClassProtected, "Refusing to destroy %s, which is protected by %s", id, protection)
+	}
+	if err := p.breaker.admit(p.resource, id); err != nil {
+		return err
+	}
+	err = p.Plugin.Destroy(id)
+	if err != nil {
+		p.breaker.forget(p.resource, id)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package instance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit.aws/fake"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestroyBreaker(t *testing.T) {
+	breaker := NewDestroyBreaker(2, time.Minute)
+	require.NoError(t, breaker.admit("ec2-instance", "i-1"))
+	require.NoError(t, breaker.admit("ec2-instance", "i-2"))
+	require.NoError(t, breaker.admit("ec2-volume", "vol-1"))
+
+	require.Equal(t, ErrorClassHalted, ClassOf(breaker.admit("ec2-instance", "i-3")))
+	require.Equal(t, DestroyHalt{Resource: "ec2-instance", ID: "i-3", Limit: 2, Window: "1m0s"},
+		<-breaker.DestroyHalts())
+
+	// Destroys stay halted, and the halt is only reported once.
+	require.Equal(t, ErrorClassHalted, ClassOf(breaker.admit("ec2-instance", "i-4")))
+	require.Len(t, breaker.DestroyHalts(), 0)
+	require.NoError(t, breaker.admit("ec2-volume", "vol-2"))
+
+	// Destroys resume once the breaker is reset.
+	breaker.Reset()
+	require.NoError(t, breaker.admit("ec2-instance", "i-4"))
+	require.NoError(t, breaker.admit("ec2-instance", "i-5"))
+	require.Equal(t, ErrorClassHalted, ClassOf(breaker.admit("ec2-instance", "i-6")))
+
+	// Forgotten destroys don't count.
+	breaker = NewDestroyBreaker(1, time.Minute)
+	require.NoError(t, breaker.admit("ec2-instance", "i-1"))
+	breaker.forget("ec2-instance", "i-1")
+	require.NoError(t, breaker.admit("ec2-instance", "i-1"))
+	require.Equal(t, ErrorClassHalted, ClassOf(breaker.admit("ec2-instance", "i-2")))
+
+	// Destroys leave the sliding window.
+	breaker = NewDestroyBreaker(1, 10*time.Millisecond)
+	require.NoError(t, breaker.admit("ec2-instance", "i-1"))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, breaker.admit("ec2-instance", "i-2"))
+
+	require.NoError(t, NewDestroyBreaker(0, 0).admit("ec2-instance", "i-1"))
+}
+
+func provisionInstance(t *testing.T, plugin instance.Plugin, disableAPITermination bool) instance.ID {
+	id, err := plugin.Provision(instance.Spec{Properties: types.AnyString(fmt.Sprintf(`{
+		"RunInstancesInput": {"ImageId": "%s", "InstanceType": "t2.micro", "DisableApiTermination": %v}
+	}`, fake.DefaultImageID, disableAPITermination))})
+	require.NoError(t, err)
+	return *id
+}
+
+func TestSafetyPluginProtection(t *testing.T) {
+	client := fake.NewEC2()
+	breaker := NewDestroyBreaker(0, 0)
+	options := DefaultInstancePluginOptions
+	options.CompletionWorkers = 0
+
+	plugin := NewInstancePluginWithOptions(client, map[string]string{}, options)
+	safe := NewSafetyPlugin("ec2-instance", plugin, breaker)
+	protected := provisionInstance(t, plugin, false)
+	require.NoError(t, plugin.Label(protected, map[string]string{ProtectedTag: "true"}))
+	err := safe.Destroy(protected)
+	require.Equal(t, ErrorClassProtected, ClassOf(err))
+	require.Contains(t, err.Error(), ProtectedTag)
+
+	// Termination protection is only checked if the plugin is configured to, otherwise EC2 refuses the destroy.
+	err = safe.Destroy(provisionInstance(t, plugin, true))
+	require.Error(t, err)
+	require.NotEqual(t, ErrorClassProtected, ClassOf(err))
+
+	options.CheckTerminationProtection = true
+	plugin = NewInstancePluginWithOptions(client, map[string]string{}, options)
+	safe = NewSafetyPlugin("ec2-instance", plugin, breaker)
+	checked := provisionInstance(t, plugin, true)
+	err = safe.Destroy(checked)
+	require.Equal(t, ErrorClassProtected, ClassOf(err))
+	require.Contains(t, err.Error(), "termination protection")
+	require.NoError(t, safe.Destroy(provisionInstance(t, plugin, false)))
+
+	volume, err := client.CreateVolume(&ec2.CreateVolumeInput{AvailabilityZone: aws.String(fake.DefaultAvailabilityZone),
+		Size: aws.Int64(10)})
+	require.NoError(t, err)
+	require.NoError(t, ec2CreateTags(client, instance.ID(*volume.VolumeId), map[string]string{ProtectedTag: "1"}))
+	err = NewSafetyPlugin("ec2-volume", NewVolumePlugin(client, map[string]string{}), breaker).Destroy(
+		instance.ID(*volume.VolumeId))
+	require.Equal(t, ErrorClassProtected, ClassOf(err))
+}
+
+func TestSafetyPluginBreaker(t *testing.T) {
+	client := fake.NewEC2()
+	options := DefaultInstancePluginOptions
+	options.CompletionWorkers = 0
+	plugin := NewInstancePluginWithOptions(client, map[string]string{}, options)
+	safe := NewSafetyPlugin("ec2-instance", plugin, NewDestroyBreaker(1, time.Minute))
+
+	first, second := provisionInstance(t, plugin, false), provisionInstance(t, plugin, false)
+
+	// Failed destroys don't count.
+	for i := 0; i < 3; i++ {
+		client.Fail("TerminateInstances", fake.Error("UnauthorizedOperation", "You are not authorized"))
+		require.Error(t, safe.Destroy(first))
+	}
+
+	require.NoError(t, safe.Destroy(first))
+	require.Equal(t, ErrorClassHalted, ClassOf(safe.Destroy(second)))
+
+	descriptions, err := plugin.DescribeInstances(map[string]string{}, false)
+	require.NoError(t, err)
+	require.Len(t, descriptions, 1)
+	require.Equal(t, second, descriptions[0].ID)
+}
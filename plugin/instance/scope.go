@@ -0,0 +1,334 @@
+package instance
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/infrakit.aws/plugin/audit"
+	"github.com/docker/infrakit/pkg/spi"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+)
+
+// Scope is where instances are created: a region, and the role assumed to access it.
+type Scope struct {
+	Region string
+	Role   Role
+}
+
+func (s Scope) String() string {
+	if s.Role.ARN == "" {
+		return s.Region
+	}
+	return s.Region + " as " + s.Role.ARN
+}
+
+// ScopedPluginFunc creates the plugin that creates instances in the given scope.
+type ScopedPluginFunc func(scope Scope) (instance.Plugin, error)
+
+// scopedInstancePlugin serves instances in several regions and accounts, routing each request to the plugin of its
+// scope.  Instances outside the default region are identified as <region>/<instance ID>, and instances accessed with
+// another role than the default one as <account>/<region>/<instance ID>.
+type scopedInstancePlugin struct {
+	defaultScope Scope
+	regions      []string
+	roles        []Role
+	newPlugin    ScopedPluginFunc
+
+	lock    *sync.Mutex
+	plugins map[Scope]instance.Plugin
+	errors  chan ProvisionError
+
+	// call is the call whose operations the plugins of the scopes journal, if any.
+	call *audit.Call
+}
+
+// NewMultiScopeInstancePlugin creates a plugin that creates instances in the default scope, or in the Region and with
+// the Role of the request.  Only the default region and role and the given regions and roles may be used, and
+// described.  The plugins of the scopes are created on first use.  Since the instances of the roles other than the
+// default one are identified by account, those roles must be in different accounts.
+func NewMultiScopeInstancePlugin(
+	defaultScope Scope,
+	regions []string,
+	roles []Role,
+	newPlugin ScopedPluginFunc) (instance.Plugin, error) {
+
+	otherRegions := []string{}
+	for _, region := range regions {
+		if region != defaultScope.Region && !contains(otherRegions, region) {
+			otherRegions = append(otherRegions, region)
+		}
+	}
+	otherRoles := []Role{}
+	accounts := map[string]string{}
+	for _, role := range roles {
+		if role.ARN == defaultScope.Role.ARN {
+			continue
+		}
+		if other, has := accounts[role.Account()]; has {
+			if other == role.ARN {
+				continue
+			}
+			return nil, newError(ErrorClassInvalidSpec, "Roles %s and %s are in the same account", other, role.ARN)
+		}
+		accounts[role.Account()] = role.ARN
+		otherRoles = append(otherRoles, role)
+	}
+
+	return &scopedInstancePlugin{
+		defaultScope: defaultScope,
+		regions:      otherRegions,
+		roles:        otherRoles,
+		newPlugin:    newPlugin,
+		lock:         &sync.Mutex{},
+		plugins:      map[Scope]instance.Plugin{},
+		errors:       make(chan ProvisionError, 64),
+	}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, element := range list {
+		if element == s {
+			return true
+		}
+	}
+	return false
+}
+
+// scope returns the served scope of the region and role ARN, which default to those of the default scope.
+func (r *scopedInstancePlugin) scope(region, roleARN string) (Scope, error) {
+	scope := r.defaultScope
+	if region != "" {
+		if region != r.defaultScope.Region && !contains(r.regions, region) {
+			return scope, fmt.Errorf("Region %s is not served", region)
+		}
+		scope.Region = region
+	}
+	if roleARN != "" && roleARN != r.defaultScope.Role.ARN {
+		found := false
+		for _, role := range r.roles {
+			if role.ARN == roleARN {
+				scope.Role, found = role, true
+			}
+		}
+		if !found {
+			return scope, fmt.Errorf("Role %s is not served", roleARN)
+		}
+	}
+	return scope, nil
+}
+
+func (r *scopedInstancePlugin) scopes() []Scope {
+	scopes := []Scope{}
+	for _, role := range append([]Role{r.defaultScope.Role}, r.roles...) {
+		for _, region := range append([]string{r.defaultScope.Region}, r.regions...) {
+			scopes = append(scopes, Scope{Region: region, Role: role})
+		}
+	}
+	return scopes
+}
+
+func (r *scopedInstancePlugin) plugin(scope Scope) (instance.Plugin, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if plugin, has := r.plugins[scope]; has {
+		return audit.WithCall(plugin, r.call), nil
+	}
+
+	log.Infof("Creating plugin for %s", scope)
+	plugin, err := r.newPlugin(scope)
+	if err != nil {
+		return nil, err
+	}
+	r.plugins[scope] = plugin
+
+	if source, is := plugin.(ProvisionErrorSource); is && source.ProvisionErrors() != nil {
+		go func() {
+			for provisionErr := range source.ProvisionErrors() {
+				provisionErr.ID = r.qualify(scope, provisionErr.ID)
+				select {
+				case r.errors <- provisionErr:
+				default:
+					log.Warnf("Dropped provisioning failure of instance %s", provisionErr.ID)
+				}
+			}
+		}()
+	}
+	return audit.WithCall(plugin, r.call), nil
+}
+
+// qualify returns the ID of the instance in the scope as served by this plugin.
+func (r *scopedInstancePlugin) qualify(scope Scope, id instance.ID) instance.ID {
+	switch {
+	case scope.Role.ARN != r.defaultScope.Role.ARN:
+		return instance.ID(scope.Role.Account() + "/" + scope.Region + "/" + string(id))
+	case scope.Region != r.defaultScope.Region:
+		return instance.ID(scope.Region + "/" + string(id))
+	}
+	return id
+}
+
+// parseID returns the scope and the EC2 ID of the instance with the ID served by this plugin.
+func (r *scopedInstancePlugin) parseID(id instance.ID) (Scope, instance.ID, error) {
+	parts := strings.SplitN(string(id), "/", 3)
+	switch len(parts) {
+	case 3:
+		for _, role := range r.roles {
+			if role.Account() == parts[0] {
+				scope, err := r.scope(parts[1], role.ARN)
+				return scope, instance.ID(parts[2]), err
+			}
+		}
+		return r.defaultScope, id, fmt.Errorf("No role for account %s", parts[0])
+	case 2:
+		scope, err := r.scope(parts[0], "")
+		return scope, instance.ID(parts[1]), err
+	}
+	return r.defaultScope, id, nil
+}
+
+func requestScope(properties *types.Any) (string, string, error) {
+	request := CreateInstanceRequest{}
+	if err := properties.Decode(&request); err != nil {
+		return "", "", invalidSpecError(err)
+	}
+	return request.Region, request.Role, nil
+}
+
+// WithCall returns the plugin routing requests to the plugins of the call of the scopes, sharing the plugins.
+func (r *scopedInstancePlugin) WithCall(call *audit.Call) instance.Plugin {
+	scoped := *r
+	scoped.call = call
+	return &scoped
+}
+
+// VendorInfo returns a vendor specific name and version
+func (r *scopedInstancePlugin) VendorInfo() *spi.VendorInfo {
+	return awsInstancePlugin{options: InstancePluginOptions{Role: r.defaultScope.Role.ARN}}.VendorInfo()
+}
+
+// ExampleProperties returns the properties / config of this plugin
+func (r *scopedInstancePlugin) ExampleProperties() *types.Any {
+	return awsInstancePlugin{}.ExampleProperties()
+}
+
+// Validate performs local checks to determine if the request is valid.
+func (r *scopedInstancePlugin) Validate(req *types.Any) error {
+	region, role, err := requestScope(req)
+	if err != nil {
+		return err
+	}
+	if _, err := r.scope(region, role); err != nil {
+		return err
+	}
+	return awsInstancePlugin{}.Validate(req)
+}
+
+// Provision creates a new instance in the scope of the request.
+func (r *scopedInstancePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	if spec.Properties == nil {
+		return nil, newError(ErrorClassInvalidSpec, "Properties must be set")
+	}
+	region, role, err := requestScope(spec.Properties)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := r.scope(region, role)
+	if err != nil {
+		return nil, err
+	}
+
+	plugin, err := r.plugin(scope)
+	if err != nil {
+		return nil, err
+	}
+	id, err := plugin.Provision(spec)
+	if id != nil {
+		qualified := r.qualify(scope, *id)
+		id = &qualified
+	}
+	return id, err
+}
+
+// route returns the plugin of the scope of the instance, and its EC2 ID.
+func (r *scopedInstancePlugin) route(id instance.ID) (instance.Plugin, instance.ID, error) {
+	scope, ec2ID, err := r.parseID(id)
+	if err != nil {
+		return nil, id, err
+	}
+	plugin, err := r.plugin(scope)
+	return plugin, ec2ID, err
+}
+
+// inNamespace returns true if the instance is in the namespace of the plugin of its scope.
+func (r *scopedInstancePlugin) inNamespace(id instance.ID) (bool, error) {
+	plugin, ec2ID, err := r.route(id)
+	if err != nil {
+		return false, err
+	}
+	if checker, is := plugin.(namespaced); is {
+		return checker.inNamespace(ec2ID)
+	}
+	return false, nil
+}
+
+// protection returns the protection of the instance by the plugin of its scope, if any.
+func (r *scopedInstancePlugin) protection(id instance.ID) (string, error) {
+	plugin, ec2ID, err := r.route(id)
+	if err != nil {
+		return "", err
+	}
+	if checker, is := plugin.(protectable); is {
+		return checker.protection(ec2ID)
+	}
+	return "", nil
+}
+
+// Label labels the instance.
+func (r *scopedInstancePlugin) Label(id instance.ID, labels map[string]string) error {
+	plugin, ec2ID, err := r.route(id)
+	if err != nil {
+		return err
+	}
+	return plugin.Label(ec2ID, labels)
+}
+
+// Destroy destroys the instance.
+func (r *scopedInstancePlugin) Destroy(id instance.ID) error {
+	plugin, ec2ID, err := r.route(id)
+	if err != nil {
+		return err
+	}
+	return plugin.Destroy(ec2ID)
+}
+
+// DescribeInstances returns descriptions of the instances with the tags in all served scopes.
+func (r *scopedInstancePlugin) DescribeInstances(
+	tags map[string]string,
+	properties bool) ([]instance.Description, error) {
+
+	descriptions := []instance.Description{}
+	for _, scope := range r.scopes() {
+		plugin, err := r.plugin(scope)
+		if err != nil {
+			return nil, err
+		}
+		described, err := plugin.DescribeInstances(tags, properties)
+		if err != nil {
+			return nil, wrapError(err, "Describing instances in %s failed: %s", scope, err)
+		}
+		for _, description := range described {
+			description.ID = r.qualify(scope, description.ID)
+			descriptions = append(descriptions, description)
+		}
+	}
+	return descriptions, nil
+}
+
+// ProvisionErrors returns the failures to complete provisioning instances in the background in all scopes.
+func (r *scopedInstancePlugin) ProvisionErrors() <-chan ProvisionError {
+	return r.errors
+}
@@ -0,0 +1,117 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	mock_ec2 "github.com/docker/infrakit.aws/mock/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiScopeInstancePlugin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	role := Role{ARN: "arn:aws:iam::123456789012:role/infrakit", ExternalID: "secret"}
+	clients := map[string]*mock_ec2.MockEC2API{
+		"us-west-2":                mock_ec2.NewMockEC2API(ctrl),
+		"eu-west-1":                mock_ec2.NewMockEC2API(ctrl),
+		"us-west-2 as " + role.ARN: mock_ec2.NewMockEC2API(ctrl),
+		"eu-west-1 as " + role.ARN: mock_ec2.NewMockEC2API(ctrl),
+	}
+	created := []string{}
+	pluginImpl, err := NewMultiScopeInstancePlugin(Scope{Region: "us-west-2"}, []string{"eu-west-1", "us-west-2"},
+		[]Role{role, role},
+		func(scope Scope) (instance.Plugin, error) {
+			created = append(created, scope.String())
+			return &awsInstancePlugin{client: clients[scope.String()], namespaceTags: testNamespace}, nil
+		})
+	require.NoError(t, err)
+
+	require.Error(t, pluginImpl.Validate(types.AnyString(`{"Region": "ap-south-1"}`)))
+	require.NoError(t, pluginImpl.Validate(types.AnyString(`{"Region": "eu-west-1"}`)))
+	require.Error(t, pluginImpl.Validate(types.AnyString(`{"Role": "arn:aws:iam::210987654321:role/other"}`)))
+
+	// Provision in a region other than the default qualifies the instance ID with the region.
+	instanceID := "i-1"
+	clients["eu-west-1"].EXPECT().RunInstances(gomock.Any()).
+		Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: &instanceID}}}, nil)
+	clients["eu-west-1"].EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil)
+
+	id, err := pluginImpl.Provision(instance.Spec{Properties: types.AnyString(`{"Region": "eu-west-1"}`), Tags: tags})
+	require.NoError(t, err)
+	require.Equal(t, instance.ID("eu-west-1/i-1"), *id)
+	require.Equal(t, []string{"eu-west-1"}, created)
+
+	_, err = pluginImpl.Provision(instance.Spec{Properties: types.AnyString(`{"Region": "ap-south-1"}`), Tags: tags})
+	require.Error(t, err)
+
+	// Provision with another role qualifies the instance ID with the account of the role.
+	clients["us-west-2 as "+role.ARN].EXPECT().RunInstances(gomock.Any()).
+		Return(&ec2.Reservation{Instances: []*ec2.Instance{{InstanceId: aws.String("i-3")}}}, nil)
+	clients["us-west-2 as "+role.ARN].EXPECT().CreateTags(gomock.Any()).Return(&ec2.CreateTagsOutput{}, nil)
+
+	id, err = pluginImpl.Provision(instance.Spec{Properties: types.AnyString(`{"Role": "` + role.ARN + `"}`), Tags: tags})
+	require.NoError(t, err)
+	require.Equal(t, instance.ID("123456789012/us-west-2/i-3"), *id)
+
+	// All served scopes are described, each once.
+	created = []string{}
+	for scope, id := range map[string]string{
+		"us-west-2":                "i-2",
+		"eu-west-1":                "i-1",
+		"us-west-2 as " + role.ARN: "i-3",
+		"eu-west-1 as " + role.ARN: "i-4",
+	} {
+		clients[scope].EXPECT().DescribeInstances(describeGroupRequest(testNamespace, tags, liveInstanceStates, nil)).
+			Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{
+				{InstanceId: aws.String(id), PrivateIpAddress: aws.String("10.0.0.1")},
+			}}}}, nil)
+	}
+	descriptions, err := pluginImpl.DescribeInstances(tags, false)
+	require.NoError(t, err)
+	ids := []instance.ID{}
+	for _, description := range descriptions {
+		ids = append(ids, description.ID)
+	}
+	require.Equal(t, []instance.ID{"i-2", "eu-west-1/i-1", "123456789012/us-west-2/i-3", "123456789012/eu-west-1/i-4"}, ids)
+	require.Equal(t, []string{"us-west-2", "eu-west-1 as " + role.ARN}, created)
+
+	// Destroy is routed to the region of the instance.
+	clients["eu-west-1"].EXPECT().DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{&instanceID}}).
+		Return(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{
+			{InstanceId: &instanceID},
+		}}}}, nil)
+	clients["eu-west-1"].EXPECT().TerminateInstances(&ec2.TerminateInstancesInput{InstanceIds: []*string{&instanceID}}).
+		Return(&ec2.TerminateInstancesOutput{
+			TerminatingInstances: []*ec2.InstanceStateChange{{InstanceId: &instanceID}},
+		}, nil)
+	require.NoError(t, pluginImpl.Destroy("eu-west-1/i-1"))
+}
+
+func TestMultiScopeInstancePluginRolesInSameAccount(t *testing.T) {
+	_, err := NewMultiScopeInstancePlugin(Scope{Region: "us-west-2"}, nil,
+		[]Role{{ARN: "arn:aws:iam::123456789012:role/infrakit"}, {ARN: "arn:aws:iam::123456789012:role/other"}},
+		func(scope Scope) (instance.Plugin, error) { return nil, nil })
+	require.Equal(t, ErrorClassInvalidSpec, ClassOf(err))
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("arn:aws:iam::123456789012:role/infrakit=workers secret=1")
+	require.NoError(t, err)
+	require.Equal(t, Role{ARN: "arn:aws:iam::123456789012:role/infrakit=workers", ExternalID: "secret=1"}, role)
+	require.Equal(t, "123456789012", role.Account())
+
+	role, err = ParseRole("arn:aws:iam::123456789012:role/infrakit")
+	require.NoError(t, err)
+	require.Equal(t, Role{ARN: "arn:aws:iam::123456789012:role/infrakit"}, role)
+
+	_, err = ParseRole("infrakit")
+	require.Error(t, err)
+	_, err = ParseRole("arn:aws:iam::123456789012:role/infrakit secret other")
+	require.Error(t, err)
+}
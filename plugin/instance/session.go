@@ -0,0 +1,274 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/docker/infrakit.aws/plugin/metrics"
+	"github.com/docker/infrakit.aws/plugin/recording"
+	"github.com/spf13/pflag"
+)
+
+// emulatorRegion is the region used with an emulator if none is given.
+const emulatorRegion = "us-east-1"
+
+// SessionOptions configure the sessions of the AWS API clients.
+type SessionOptions struct {
+	// Region is the AWS region.  It is discovered from the instance metadata if empty.
+	Region string
+
+	// Credentials select the source of the API credentials.
+	Credentials CredentialsOptions
+
+	// Role is the role to assume, if any.
+	Role Role
+
+	// Retries is the number of retries of API operations.
+	Retries int
+
+	// Debug turns on logging of API requests.
+	Debug bool
+
+	// Endpoint overrides the endpoint of all services.
+	Endpoint string
+
+	// Endpoints override the endpoints of services, as <service>=<URL>, e.g. ec2=http://localhost:4597.  Services
+	// are named as in their API, e.g. ec2, autoscaling, elasticloadbalancing, iam, logs, sqs or sts.
+	Endpoints []string
+
+	// DisableSSL uses HTTP instead of HTTPS for the default endpoints.
+	DisableSSL bool
+
+	// S3ForcePathStyle addresses buckets by path rather than by host name.
+	S3ForcePathStyle bool
+
+	// MetadataURL overrides the URL of the EC2 instance metadata service.
+	MetadataURL string
+
+	// Emulator is the URL of an AWS emulator serving all services.  Without credentials or region options, the
+	// session uses dummy static credentials and the us-east-1 region.
+	Emulator string
+
+	// RateLimit limits the rate of API requests to each service.
+	RateLimit RateLimitOptions
+
+	// DryRun prevents mutating operations.  EC2 operations are only checked with their DryRun flag, and those of
+	// other services fail without being sent.
+	DryRun bool
+
+	// Record is the path of a fixture that API requests and their responses are appended to, scrubbed of
+	// credentials and account IDs.
+	Record string
+
+	// Replay is the path of a fixture whose responses are served instead of making API requests.  Without
+	// credentials or region options, the session uses dummy static credentials and the us-east-1 region.
+	Replay string
+}
+
+// Flags returns the flags of the options.
+func (o *SessionOptions) Flags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("aws", pflag.PanicOnError)
+	flags.StringVar(&o.Region, "region", "", "AWS region")
+	flags.StringVar(&o.Credentials.Source, "credentials", CredentialsChain,
+		"Source of AWS API credentials: chain, instance, env, shared or static")
+	flags.StringVar(&o.Credentials.Profile, "profile", "", "Profile of the shared credentials file")
+	flags.StringVar(&o.Credentials.AccessKeyID, "access-key-id", "", "IAM access key ID")
+	flags.StringVar(&o.Credentials.SecretAccessKey, "secret-access-key", "", "IAM access key secret")
+	flags.StringVar(&o.Credentials.SessionToken, "session-token", "", "AWS STS token")
+	flags.StringVar(&o.Role.ARN, "role-arn", "", "ARN of an IAM role to assume")
+	flags.StringVar(&o.Role.ExternalID, "external-id", "", "External ID to assume the IAM role with")
+	flags.IntVar(&o.Retries, "retries", 5, "Number of retries for AWS API operations")
+	flags.BoolVar(&o.Debug, "api-debug", false, "True to turn on API debugging")
+	flags.StringVar(&o.Record, "api-record", "", "Path of a fixture to record API requests and responses to")
+	flags.StringVar(&o.Replay, "api-replay", "", "Path of a fixture to replay API responses from")
+	flags.StringVar(&o.Endpoint, "endpoint", "", "Endpoint URL of all AWS services")
+	flags.StringSliceVar(&o.Endpoints, "endpoints", []string{},
+		"Endpoint URLs of AWS services, as <service>=<URL>, e.g. ec2=http://localhost:4597")
+	flags.BoolVar(&o.DisableSSL, "disable-ssl", false, "True to use HTTP for AWS API operations")
+	flags.BoolVar(&o.S3ForcePathStyle, "s3-force-path-style", false, "True to address S3 buckets by path")
+	flags.StringVar(&o.MetadataURL, "metadata-url", "", "URL of the EC2 instance metadata service")
+	flags.StringVar(&o.Emulator, "emulator", "", "URL of an AWS emulator serving all services")
+	flags.Float64Var(&o.RateLimit.Rate, "rate-limit", 20,
+		"Number of API requests per second to each AWS service, 0 for no limit")
+	flags.IntVar(&o.RateLimit.Burst, "rate-burst", 40, "Number of API requests to each AWS service at once")
+	flags.IntVar(&o.RateLimit.Reserve, "rate-reserve", 10,
+		"Number of requests of the burst reserved for requests other than describes")
+	return flags
+}
+
+// endpoints returns the endpoint overrides by service.
+func (o SessionOptions) endpoints() (map[string]*url.URL, error) {
+	endpoints := map[string]*url.URL{}
+	for _, endpoint := range o.Endpoints {
+		parts := strings.SplitN(endpoint, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Endpoints must be formatted as service=URL: %s", endpoint)
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("Invalid endpoint of %s: %s", parts[0], parts[1])
+		}
+		endpoints[parts[0]] = u
+	}
+	return endpoints, nil
+}
+
+// transport returns the transport recording or replaying the API requests, or nil to make them.
+func (o SessionOptions) transport() (http.RoundTripper, error) {
+	switch {
+	case o.Record != "" && o.Replay != "":
+		return nil, errors.New("API requests cannot be both recorded and replayed")
+	case o.Record != "":
+		return recording.NewRecorder(o.Record, nil)
+	case o.Replay != "":
+		return recording.NewReplayer(o.Replay)
+	}
+	return nil, nil
+}
+
+// metadataURL returns the URL of the instance metadata service of the session.
+func (o SessionOptions) metadataURL() string {
+	if o.MetadataURL != "" {
+		return strings.TrimSuffix(o.MetadataURL, "/")
+	}
+	return defaultMetadataURL
+}
+
+// GetMetadata returns the value of the metadata by key, from the instance metadata service of the session.
+func (o SessionOptions) GetMetadata(key MetadataKey) (string, error) {
+	return getMetadata(o.metadataURL(), key)
+}
+
+// GetRegion returns the AWS region this instance is in, from the instance metadata service of the session.
+func (o SessionOptions) GetRegion() (string, error) {
+	return getRegion(o.metadataURL())
+}
+
+// ResolveRegion sets the region from the instance metadata if it is not set.
+func (o *SessionOptions) ResolveRegion() error {
+	if o.Region != "" {
+		return nil
+	}
+	if o.Emulator != "" || o.Replay != "" {
+		o.Region = emulatorRegion
+		return nil
+	}
+
+	log.Println("region not specified, attempting to discover from EC2 instance metadata")
+	region, err := getRegion(o.metadataURL())
+	if err != nil {
+		return errors.New("Unable to determine region")
+	}
+
+	log.Printf("Defaulting to local region %s\n", region)
+	o.Region = region
+	return nil
+}
+
+// NewSession creates a session with the options.  The region must be resolved.
+func NewSession(o SessionOptions) (client.ConfigProvider, error) {
+	endpoints, err := o.endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := o.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsOptions := o.Credentials
+	endpoint := o.Endpoint
+	if o.Emulator != "" && endpoint == "" {
+		endpoint = o.Emulator
+	}
+	if o.Emulator != "" || o.Replay != "" {
+		if credentialsOptions.Source == "" || credentialsOptions.Source == CredentialsChain {
+			credentialsOptions = CredentialsOptions{
+				Source:          CredentialsStatic,
+				AccessKeyID:     "infrakit",
+				SecretAccessKey: "infrakit",
+			}
+		}
+	}
+
+	creds, err := credentialsOptions.credentials(o.metadataURL())
+	if err != nil {
+		return nil, err
+	}
+
+	config := aws.NewConfig().
+		WithRegion(o.Region).
+		WithCredentials(creds).
+		WithCredentialsChainVerboseErrors(true).
+		WithLogger(GetLogger()).
+		WithMaxRetries(o.Retries).
+		WithDisableSSL(o.DisableSSL).
+		WithS3ForcePathStyle(o.S3ForcePathStyle)
+	if endpoint != "" {
+		config.WithEndpoint(endpoint)
+	}
+	if transport != nil {
+		config.WithHTTPClient(&http.Client{Transport: transport})
+	}
+	if o.Debug {
+		config.WithLogLevel(aws.LogDebugWithRequestErrors)
+	}
+
+	var limiter *rateLimiter
+	if o.RateLimit.Rate > 0 {
+		limiter = newRateLimiter(o.RateLimit)
+	}
+
+	sess := session.New(config)
+	if len(endpoints) > 0 {
+		sess.Handlers.Build.PushBack(overrideEndpoint(endpoints))
+	}
+	if limiter != nil {
+		limiter.install(&sess.Handlers)
+	}
+	metrics.InstallHandlers(&sess.Handlers)
+	if o.DryRun {
+		installDryRun(&sess.Handlers)
+	}
+	if o.Role.ARN == "" {
+		return sess, nil
+	}
+
+	roleSession := NewRoleSession(sess, config, o.Role)
+	if len(endpoints) > 0 {
+		roleSession.Handlers.Build.PushBack(overrideEndpoint(endpoints))
+	}
+	if limiter != nil {
+		limiter.install(&roleSession.Handlers)
+	}
+	metrics.InstallHandlers(&roleSession.Handlers)
+	if o.DryRun {
+		installDryRun(&roleSession.Handlers)
+	}
+	return roleSession, nil
+}
+
+// overrideEndpoint returns a handler sending the requests of the services to their endpoint overrides.
+func overrideEndpoint(endpoints map[string]*url.URL) func(*request.Request) {
+	return func(r *request.Request) {
+		endpoint, has := endpoints[r.ClientInfo.ServiceName]
+		if !has {
+			return
+		}
+		r.HTTPRequest.URL.Scheme = endpoint.Scheme
+		r.HTTPRequest.URL.Host = endpoint.Host
+		r.HTTPRequest.Host = endpoint.Host
+		if endpoint.Path != "" && endpoint.Path != "/" {
+			r.HTTPRequest.URL.Path = strings.TrimSuffix(endpoint.Path, "/") + r.HTTPRequest.URL.Path
+		}
+	}
+}
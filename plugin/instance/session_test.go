@@ -0,0 +1,59 @@
+package instance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionEndpointOverrides(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-09-15/"></DescribeInstancesResponse>`))
+	}))
+	defer server.Close()
+
+	// Per service endpoint, with a path prefix.
+	sess, err := NewSession(SessionOptions{
+		Region:      "us-west-2",
+		Credentials: CredentialsOptions{Source: CredentialsStatic, AccessKeyID: "id", SecretAccessKey: "secret"},
+		Endpoints:   []string{"ec2=" + server.URL + "/ec2"},
+	})
+	require.NoError(t, err)
+	_, err = ec2.New(sess).DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/ec2/"}, requests)
+
+	// Emulator for all services, with dummy credentials and a default region.
+	options := SessionOptions{Emulator: server.URL}
+	require.NoError(t, options.ResolveRegion())
+	require.Equal(t, "us-east-1", options.Region)
+	sess, err = NewSession(options)
+	require.NoError(t, err)
+	_, err = ec2.New(sess).DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/ec2/", "/"}, requests)
+
+	_, err = NewSession(SessionOptions{Region: "us-west-2", Endpoints: []string{"ec2"}})
+	require.Error(t, err)
+}
+
+func TestResolveRegionFromMetadataURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/latest/meta-data/placement/availability-zone", r.URL.Path)
+		w.Write([]byte("eu-west-1b"))
+	}))
+	defer server.Close()
+
+	options := SessionOptions{MetadataURL: server.URL + "/"}
+	require.NoError(t, options.ResolveRegion())
+	require.Equal(t, "eu-west-1", options.Region)
+	region, err := options.GetRegion()
+	require.NoError(t, err)
+	require.Equal(t, "eu-west-1", region)
+}
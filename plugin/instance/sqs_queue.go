@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/docker/infrakit.aws/plugin/audit"
 	"github.com/docker/infrakit/pkg/spi/instance"
 	"github.com/docker/infrakit/pkg/types"
 )
@@ -26,14 +27,24 @@ type createQueueRequest struct {
 	CreateQueueInput sqs.CreateQueueInput
 }
 
+// WithCall returns the plugin making its operations with the client of the call.
+func (p awsQueuePlugin) WithCall(call *audit.Call) instance.Plugin {
+	p.client = call.Service(p.client).(sqsiface.SQSAPI)
+	return p
+}
+
 func (p awsQueuePlugin) Validate(req *types.Any) error {
+	request := createQueueRequest{}
+	if err := req.Decode(&request); err != nil {
+		return invalidSpecError(err)
+	}
 	return nil
 }
 
 func (p awsQueuePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request := createQueueRequest{}
 	if err := json.Unmarshal(*spec.Properties, &request); err != nil {
-		return nil, fmt.Errorf("Invalid input formatting: %s", err)
+		return nil, invalidSpecError(err)
 	}
 
 	name := newQueueName(spec.Tags, p.namespaceTags)
@@ -41,7 +52,7 @@ func (p awsQueuePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	request.CreateQueueInput.QueueName = aws.String(name)
 	output, err := p.client.CreateQueue(&request.CreateQueueInput)
 	if err != nil {
-		return nil, fmt.Errorf("CreateQueue failed: %s", err)
+		return nil, apiError("CreateQueue", err)
 	}
 
 	getQueueAttributesOutput, err := p.client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
@@ -49,7 +60,7 @@ func (p awsQueuePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 		QueueUrl:       output.QueueUrl,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("GetQueueAttributes failed: %s", err)
+		return nil, apiError("GetQueueAttributes", err)
 	}
 
 	var id instance.ID
@@ -66,6 +77,11 @@ func (p awsQueuePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
 	return &id, nil
 }
 
+// inNamespace returns true if the name of the queue has the values of the namespace tags.
+func (p awsQueuePlugin) inNamespace(id instance.ID) (bool, error) {
+	return namedInNamespace(arnOrNameToName(string(id)), newQueueName, p.namespaceTags), nil
+}
+
 func (p awsQueuePlugin) Label(id instance.ID, labels map[string]string) error {
 	return nil
 }
@@ -73,11 +89,11 @@ func (p awsQueuePlugin) Label(id instance.ID, labels map[string]string) error {
 func (p awsQueuePlugin) Destroy(id instance.ID) error {
 	output, err := p.client.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(arnOrNameToName(string(id)))})
 	if err != nil {
-		return fmt.Errorf("GetQueueUrl failed: %s", err)
+		return apiError("GetQueueUrl", err)
 	}
 
 	if _, err = p.client.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: output.QueueUrl}); err != nil {
-		return fmt.Errorf("DeleteQueue failed: %s", err)
+		return apiError("DeleteQueue", err)
 	}
 	return nil
 }
@@ -87,7 +103,7 @@ func (p awsQueuePlugin) DescribeInstances(tags map[string]string, properties boo
 
 	output, err := p.client.ListQueues(&sqs.ListQueuesInput{QueueNamePrefix: aws.String(name)})
 	if err != nil {
-		return []instance.Description{}, fmt.Errorf("ListQueues failed: %s", err)
+		return []instance.Description{}, apiError("ListQueues", err)
 	}
 
 	descriptions := []instance.Description{}
@@ -101,7 +117,7 @@ func (p awsQueuePlugin) DescribeInstances(tags map[string]string, properties boo
 				// A deleted queue may wind up here.
 				continue
 			}
-			return []instance.Description{}, fmt.Errorf("GetQueueAttributes failed: %s", err)
+			return []instance.Description{}, apiError("GetQueueAttributes", err)
 		}
 
 		var id instance.ID
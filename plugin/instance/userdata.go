@@ -0,0 +1,314 @@
+package instance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/template"
+)
+
+// MaxUserDataSize is the maximum size of the user data of an instance, before base64 encoding.
+const MaxUserDataSize = 16384
+
+// UserDataPart is a part of a multipart user data, as understood by cloud-init.
+type UserDataPart struct {
+	// ContentType is the MIME type of the part, e.g. text/cloud-config.  It is detected from the content if empty.
+	ContentType string
+
+	// Filename is the optional file name of the part.
+	Filename string
+
+	// Content is the content of the part.  It is rendered as a template like the rest of the user data, if the
+	// request sets RenderUserData.
+	Content string
+}
+
+// UserDataContext is the context the user data of an instance is rendered with at Provision time, if the request
+// sets RenderUserData.
+type UserDataContext struct {
+	// LogicalID is the logical ID of the instance, if any.
+	LogicalID string
+
+	// AvailabilityZone is the availability zone the instance is placed in, if known.
+	AvailabilityZone string
+
+	// SubnetID is the subnet the instance is placed in, if known.
+	SubnetID string
+
+	// Tags are the merged tags the instance is tagged with.
+	Tags map[string]string
+
+	// Namespace are the namespace tags of the plugin.
+	Namespace map[string]string
+
+	// InstanceID is the ID of the instance when a stopped instance is restarted.  It is empty for a new instance,
+	// whose ID is only known after it is launched with the user data.
+	InstanceID string
+
+	// Devices are the device names of the ebs attachments of the instance, by attachment ID.
+	Devices map[string]string
+
+	region string
+	client ec2iface.EC2API
+}
+
+func (p awsInstancePlugin) newUserDataContext(
+	spec instance.Spec,
+	request CreateInstanceRequest,
+	attachments []ebsAttachment,
+	instanceID *string) (*UserDataContext, error) {
+
+	_, tags := mergeTags(request.Tags, spec.Tags, request.pluginTags(spec), p.namespaceTags)
+
+	context := &UserDataContext{
+		Tags:       tags,
+		Namespace:  p.namespaceTags,
+		InstanceID: aws.StringValue(instanceID),
+		Devices:    map[string]string{},
+		region:     p.options.Region,
+		client:     p.client,
+	}
+
+	for _, attachment := range attachments {
+		context.Devices[attachment.ID] = attachment.Device
+	}
+
+	if spec.LogicalID != nil {
+		context.LogicalID = string(*spec.LogicalID)
+	}
+
+	run := request.RunInstancesInput
+	if run.Placement != nil {
+		context.AvailabilityZone = aws.StringValue(run.Placement.AvailabilityZone)
+	}
+	if len(run.NetworkInterfaces) > 0 && run.NetworkInterfaces[0].SubnetId != nil {
+		context.SubnetID = aws.StringValue(run.NetworkInterfaces[0].SubnetId)
+	} else {
+		context.SubnetID = aws.StringValue(run.SubnetId)
+	}
+
+	if context.AvailabilityZone == "" && context.SubnetID != "" {
+		output, err := p.client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+			SubnetIds: []*string{aws.String(context.SubnetID)},
+		})
+		if err != nil {
+			return nil, apiError("DescribeSubnets", err)
+		}
+		if len(output.Subnets) > 0 {
+			context.AvailabilityZone = aws.StringValue(output.Subnets[0].AvailabilityZone)
+		}
+	}
+
+	return context, nil
+}
+
+// Funcs returns the AWS functions available to user data templates, in addition to the infrakit ones.
+func (c *UserDataContext) Funcs() []template.Function {
+	return []template.Function{
+		{
+			Name: "region",
+			Description: []string{
+				"region returns the AWS region of the plugin, which creates the instance.",
+			},
+			Func: func() (string, error) {
+				if c.region != "" {
+					return c.region, nil
+				}
+				if client, is := c.client.(*ec2.EC2); is && client.Config.Region != nil {
+					return *client.Config.Region, nil
+				}
+				return GetRegion()
+			},
+		},
+		{
+			Name: "account",
+			Description: []string{
+				"account returns the ID of the AWS account the instance is created in.",
+			},
+			Func: func() (string, error) {
+				return getAccountID(c.client)
+			},
+		},
+	}
+}
+
+// getAccountID returns the account ID by looking up the owner of the default security group.
+func getAccountID(client ec2iface.EC2API) (string, error) {
+	output, err := client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String("default")},
+			},
+		},
+	})
+	if err != nil {
+		return "", apiError("DescribeSecurityGroups", err)
+	}
+	if len(output.SecurityGroups) == 0 {
+		return "", fmt.Errorf("Unable to determine account")
+	}
+	return aws.StringValue(output.SecurityGroups[0].OwnerId), nil
+}
+
+// renderUserData renders the user data as a template with the given context.
+func renderUserData(userData string, context *UserDataContext) (string, error) {
+	t, err := template.NewTemplate("str://"+userData, template.Options{})
+	if err != nil {
+		return "", newError(ErrorClassInvalidSpec, "Invalid user data template: %s", err)
+	}
+	rendered, err := t.Render(context)
+	if err != nil {
+		return "", fmt.Errorf("Failed to render user data: %s", err)
+	}
+	return rendered, nil
+}
+
+// buildUserData renders, if the request sets RenderUserData, and composes the user data of an instance from
+// RunInstancesInput.UserData, the flavor Init and the UserDataParts of the request.  A single part without an
+// explicit content type is used as is; otherwise the parts are composed into a multipart MIME document.  The result
+// is gzipped if it exceeds MaxUserDataSize.  Returns nil if there is no user data.  The instanceID is that of a
+// stopped instance being restarted, and nil for a new instance.
+func (p awsInstancePlugin) buildUserData(
+	spec instance.Spec,
+	request CreateInstanceRequest,
+	attachments []ebsAttachment,
+	instanceID *string) ([]byte, error) {
+
+	parts := []UserDataPart{}
+	if request.RunInstancesInput.UserData != nil {
+		parts = append(parts, UserDataPart{Content: *request.RunInstancesInput.UserData})
+	}
+	if spec.Init != "" {
+		parts = append(parts, UserDataPart{Content: spec.Init})
+	}
+	parts = append(parts, request.UserDataParts...)
+
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	if request.RenderUserData {
+		context, err := p.newUserDataContext(spec, request, attachments, instanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range parts {
+			rendered, err := renderUserData(parts[i].Content, context)
+			if err != nil {
+				return nil, err
+			}
+			parts[i].Content = rendered
+		}
+	}
+
+	var (
+		userData []byte
+		err      error
+	)
+	if len(parts) == 1 && parts[0].ContentType == "" {
+		userData = []byte(parts[0].Content)
+	} else {
+		userData, err = composeUserData(parts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(userData) <= MaxUserDataSize {
+		return userData, nil
+	}
+
+	compressed, err := gzipUserData(userData)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) > MaxUserDataSize {
+		return nil, newError(ErrorClassInvalidSpec, "User data is %d bytes gzipped, exceeding the limit of %d bytes",
+			len(compressed), MaxUserDataSize)
+	}
+	return compressed, nil
+}
+
+// detectContentType returns the cloud-init content type of the content by its first line.
+func detectContentType(content string) string {
+	switch {
+	case strings.HasPrefix(content, "#cloud-config"):
+		return "text/cloud-config"
+	case strings.HasPrefix(content, "#!"):
+		return "text/x-shellscript"
+	case strings.HasPrefix(content, "#include"):
+		return "text/x-include-url"
+	case strings.HasPrefix(content, "#cloud-boothook"):
+		return "text/cloud-boothook"
+	case strings.HasPrefix(content, "#upstart-job"):
+		return "text/upstart-job"
+	case strings.HasPrefix(content, "#part-handler"):
+		return "text/part-handler"
+	}
+	return "text/plain"
+}
+
+// composeUserData composes the parts into a multipart MIME document.
+func composeUserData(parts []UserDataPart) ([]byte, error) {
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+
+	for i, part := range parts {
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = detectContentType(part.Content)
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = fmt.Sprintf("part-%03d", i+1)
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", fmt.Sprintf(`%s; charset="utf-8"`, contentType))
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "7bit")
+		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.Content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	buff := bytes.Buffer{}
+	fmt.Fprintf(&buff, "Content-Type: multipart/mixed; boundary=\"%s\"\r\n", writer.Boundary())
+	fmt.Fprintf(&buff, "MIME-Version: 1.0\r\n\r\n")
+	buff.Write(body.Bytes())
+	return buff.Bytes(), nil
+}
+
+func gzipUserData(userData []byte) ([]byte, error) {
+	buff := bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(&buff, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(userData); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
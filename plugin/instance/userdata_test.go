@@ -0,0 +1,98 @@
+package instance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMultipartUserData(t *testing.T) {
+	pluginImpl := awsInstancePlugin{namespaceTags: testNamespace}
+
+	request := CreateInstanceRequest{
+		RunInstancesInput: ec2.RunInstancesInput{
+			UserData:  aws.String("#cloud-config\npackages: [jq]\n"),
+			Placement: &ec2.Placement{AvailabilityZone: aws.String("us-west-2a")},
+		},
+		UserDataParts: []UserDataPart{
+			{ContentType: "text/x-include-url", Filename: "extra", Content: "http://example.com/{{ .Namespace.cluster }}"},
+		},
+		RenderUserData: true,
+	}
+
+	userData, err := pluginImpl.buildUserData(instance.Spec{Init: "#!/bin/bash\necho hello"}, request, nil, nil)
+	require.NoError(t, err)
+
+	message, err := mail.ReadMessage(bytes.NewReader(userData))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	type part struct {
+		contentType, filename, content string
+	}
+	parts := []part{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		content, err := ioutil.ReadAll(p)
+		require.NoError(t, err)
+		parts = append(parts, part{p.Header.Get("Content-Type"), p.FileName(), string(content)})
+	}
+
+	require.Equal(t, []part{
+		{`text/cloud-config; charset="utf-8"`, "part-001", "#cloud-config\npackages: [jq]\n"},
+		{`text/x-shellscript; charset="utf-8"`, "part-002", "#!/bin/bash\necho hello"},
+		{`text/x-include-url; charset="utf-8"`, "extra", "http://example.com/test"},
+	}, parts)
+}
+
+func TestBuildUserDataUnrendered(t *testing.T) {
+	pluginImpl := awsInstancePlugin{namespaceTags: testNamespace}
+
+	// User data is only rendered if the request asks for it.
+	init := "#!/bin/bash\ndocker inspect --format '{{.Id}}' infrakit"
+	userData, err := pluginImpl.buildUserData(instance.Spec{Init: init}, CreateInstanceRequest{}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, init, string(userData))
+}
+
+func TestBuildUserDataGzip(t *testing.T) {
+	pluginImpl := awsInstancePlugin{namespaceTags: testNamespace}
+
+	// Compressible user data over the limit is gzipped.
+	large := "#!/bin/bash\n" + strings.Repeat("echo hello\n", MaxUserDataSize/10)
+	userData, err := pluginImpl.buildUserData(instance.Spec{Init: large}, CreateInstanceRequest{}, nil, nil)
+	require.NoError(t, err)
+	require.True(t, len(userData) <= MaxUserDataSize)
+
+	reader, err := gzip.NewReader(bytes.NewReader(userData))
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, large, string(decompressed))
+
+	// Incompressible user data over the limit is refused.
+	incompressible := randomString(4 * MaxUserDataSize)
+	_, err = pluginImpl.buildUserData(instance.Spec{Init: incompressible}, CreateInstanceRequest{}, nil, nil)
+	require.Error(t, err)
+
+	// No user data at all.
+	userData, err = pluginImpl.buildUserData(instance.Spec{}, CreateInstanceRequest{}, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, userData)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/docker/infrakit/pkg/spi/instance"
@@ -48,6 +49,17 @@ func ec2CreateTags(client ec2iface.EC2API, id instance.ID, tags ...map[string]st
 	return err
 }
 
+// withoutReservedTags returns the tags without the keys reserved by AWS, which can't be set by CreateTags.
+func withoutReservedTags(tags map[string]string) map[string]string {
+	allowed := map[string]string{}
+	for k, v := range tags {
+		if !strings.HasPrefix(k, "aws:") {
+			allowed[k] = v
+		}
+	}
+	return allowed
+}
+
 var iamNameProhibitedCharRegexp = regexp.MustCompile(`[^\w+=,.@-]`)
 
 func newIamName(tags ...map[string]string) string {
@@ -109,12 +121,46 @@ func newUnrestrictedName(tags ...map[string]string) string {
 	return strings.Join(parts, "_")
 }
 
+// notFoundErrorCodes are the codes of the errors of missing resources, besides the EC2 codes ending with .NotFound.
+var notFoundErrorCodes = map[string]bool{
+	"AWS.SimpleQueueService.NonExistentQueue": true,
+	"LoadBalancerNotFound":                    true,
+	"NoSuchEntity":                            true,
+	"ResourceNotFoundException":               true,
+}
+
+// isNotFound returns true if the error is that of a missing resource.
+func isNotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return strings.HasSuffix(awsErr.Code(), ".NotFound") || notFoundErrorCodes[awsErr.Code()]
+	}
+	return false
+}
+
+// maxRetrySleep is the longest sleep between retries.
+const maxRetrySleep = 8 * time.Second
+
+// retry calls f until it succeeds or the duration elapses, or the call is a dry run.  The sleep between calls starts
+// at the given sleep and doubles after each call, up to maxRetrySleep, with some jitter so that concurrent retries
+// spread out.
 func retry(duration time.Duration, sleep time.Duration, f func() error) error {
 	stop := time.Now().Add(duration)
+	if sleep <= 0 {
+		sleep = time.Millisecond
+	}
 	for {
-		if err := f(); err == nil || time.Now().After(stop) {
+		if err := f(); err == nil || isDryRun(err) || time.Now().After(stop) {
 			return err
 		}
-		time.Sleep(sleep)
+
+		wait := sleep/2 + time.Duration(rand.Int63n(int64(sleep)))
+		if remaining := stop.Sub(time.Now()); wait > remaining {
+			wait = remaining
+		}
+		time.Sleep(wait)
+
+		if sleep *= 2; sleep > maxRetrySleep {
+			sleep = maxRetrySleep
+		}
 	}
 }
@@ -5,7 +5,9 @@ import (
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/docker/infrakit.aws/plugin"
 	"github.com/docker/infrakit.aws/plugin/metadata"
+	"github.com/docker/infrakit.aws/plugin/metrics"
 	"github.com/docker/infrakit/pkg/cli"
 	metadata_rpc "github.com/docker/infrakit/pkg/rpc/metadata"
 	"github.com/docker/infrakit/pkg/template"
@@ -18,7 +20,7 @@ func main() {
 	options := &metadata.Options{}
 
 	var logLevel int
-	var name, stack, templateURL string
+	var name, stack, templateURL, metricsListen string
 	poll := 1 * time.Second
 
 	cmd := &cobra.Command{
@@ -26,7 +28,16 @@ func main() {
 		Short: "AWS metadata plugin",
 		RunE: func(c *cobra.Command, args []string) error {
 
+			config, err := plugin.LoadConfig(c.Flags())
+			if err != nil {
+				return err
+			}
+			if config.Print(os.Stdout) {
+				return nil
+			}
+
 			cli.SetLogLevel(logLevel)
+			metrics.Serve(metricsListen)
 
 			stop := make(chan struct{})
 
@@ -53,6 +64,8 @@ func main() {
 	cmd.Flags().StringVar(&stack, "stack", "", "CFN stack name to introspect")
 	cmd.Flags().StringVar(&templateURL, "template-url", "", "URL of the template to evaluate and export metadata.")
 	cmd.Flags().DurationVar(&poll, "poll-interval", poll, "Polling interval")
+	cmd.Flags().AddFlagSet(metrics.Flags(&metricsListen))
+	cmd.Flags().AddFlagSet(plugin.ConfigFlags())
 
 	cmd.AddCommand(cli.VersionCommand())
 
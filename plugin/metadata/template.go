@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/docker/infrakit.aws/plugin/instance"
+	"github.com/docker/infrakit.aws/plugin/metrics"
 	metadata_plugin "github.com/docker/infrakit/pkg/plugin/metadata"
 	"github.com/docker/infrakit/pkg/spi/metadata"
 	"github.com/docker/infrakit/pkg/template"
@@ -33,6 +34,7 @@ type Context struct {
 	templateOptions template.Options
 	stop            <-chan struct{}
 	stackName       string // cloudformation stackname
+	session         instance.SessionOptions
 	clients         AWSClients
 	impl            metadata.Plugin
 }
@@ -66,7 +68,9 @@ func (c *Context) start() {
 
 				// Note the actual exporting of the values is done via the 'export' function
 				// that are invoked as part of processing the template.
+				start := time.Now()
 				_, err = t.Render(c)
+				metrics.ObserveRender(start, err)
 				if err != nil {
 					log.Warningln("err evaluating template:", err)
 					update <- func(view map[string]interface{}) {
@@ -96,7 +100,7 @@ func (c *Context) List(path types.Path) (child []string, err error) {
 		return []string{"export", "local"}, nil
 	}
 	if first := path.Index(0); first != nil && "local" == *first {
-		str, err := instance.GetMetadata(instance.MetadataKeyFromSlice([]string(path.Shift(1))))
+		str, err := c.session.GetMetadata(instance.MetadataKeyFromSlice([]string(path.Shift(1))))
 		if err != nil {
 			return nil, nil // this will stop any further traversals into local/
 		}
@@ -120,7 +124,7 @@ func (c *Context) Get(path types.Path) (value *types.Any, err error) {
 		return nil, nil
 	}
 	if first := path.Index(0); first != nil && "local" == *first {
-		str, err := instance.GetMetadata(instance.MetadataKeyFromSlice([]string(path.Shift(1))))
+		str, err := c.session.GetMetadata(instance.MetadataKeyFromSlice([]string(path.Shift(1))))
 		if err != nil {
 			return types.AnyString(err.Error()), nil // let the value be the error
 		}
@@ -201,7 +205,7 @@ func (c *Context) Funcs() []template.Function {
 				"region returns the AWS region using metdata lookup",
 			},
 			Func: func() (string, error) {
-				return instance.GetRegion()
+				return c.session.GetRegion()
 			},
 		},
 		{
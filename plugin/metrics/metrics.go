@@ -0,0 +1,117 @@
+// Package metrics exposes counters and histograms of the plugins to Prometheus.
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/pflag"
+)
+
+// ListenFlag is the name of the flag of the address serving the metrics.
+const ListenFlag = "metrics-listen"
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of duration histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// registry registers the metrics of the plugins.
+var registry = prometheus.NewRegistry()
+
+// Counter counts events by label values.
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter creates and registers a counter.
+func NewCounter(name, help string, labels ...string) *Counter {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	registry.MustRegister(vec)
+	return &Counter{vec: vec}
+}
+
+// Inc increments the counter of the label values, given in the order of the labels of the counter.
+func (c *Counter) Inc(values ...string) {
+	c.vec.WithLabelValues(values...).Inc()
+}
+
+// Value returns the count of the label values.
+func (c *Counter) Value(values ...string) float64 {
+	m := &dto.Metric{}
+	if err := c.vec.WithLabelValues(values...).Write(m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
+// Histogram counts observations, e.g. durations in seconds, in buckets by label values.
+type Histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogram creates and registers a histogram with the DefaultBuckets.
+func NewHistogram(name, help string, labels ...string) *Histogram {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: DefaultBuckets}, labels)
+	registry.MustRegister(vec)
+	return &Histogram{vec: vec}
+}
+
+// Observe adds the observation to the histogram of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.vec.WithLabelValues(values...).Observe(v)
+}
+
+// Count returns the number of observations of the label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	m := &dto.Metric{}
+	if err := h.vec.WithLabelValues(values...).(prometheus.Metric).Write(m); err != nil {
+		return 0
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+// Write writes all metrics in the Prometheus text format.
+func Write(w io.Writer) error {
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns the handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Flags returns the flag of the address serving the metrics.
+func Flags(listen *string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("metrics", pflag.PanicOnError)
+	flags.StringVar(listen, ListenFlag, "",
+		"Address serving Prometheus metrics at /metrics, e.g. :9100.  Not served if empty")
+	return flags
+}
+
+// Serve serves the metrics at /metrics on the address in the background, if the address is not empty.
+func Serve(listen string) {
+	if listen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		log.Infoln("Serving metrics on", listen)
+		if err := http.ListenAndServe(listen, mux); err != nil {
+			log.Errorln("Serving metrics failed:", err)
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+// parse parses the metrics written in the Prometheus text format.
+func parse(t *testing.T) map[string]*dto.MetricFamily {
+	buff := &bytes.Buffer{}
+	require.NoError(t, Write(buff))
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(buff)
+	require.NoError(t, err)
+	return families
+}
+
+func TestWrite(t *testing.T) {
+	counter := NewCounter("test_total", "Test counter.", "kind")
+	counter.Inc("b")
+	counter.Inc("a")
+	counter.Inc("a")
+	histogram := NewHistogram("test_seconds", "Test histogram.")
+	histogram.Observe(0.02)
+	histogram.Observe(100)
+
+	families := parse(t)
+
+	require.Equal(t, dto.MetricType_COUNTER, families["test_total"].GetType())
+	counts := map[string]float64{}
+	for _, m := range families["test_total"].Metric {
+		counts[m.Label[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	require.Equal(t, map[string]float64{"a": 2, "b": 1}, counts)
+
+	require.Equal(t, dto.MetricType_HISTOGRAM, families["test_seconds"].GetType())
+	h := families["test_seconds"].Metric[0].GetHistogram()
+	require.Equal(t, uint64(2), h.GetSampleCount())
+	require.Equal(t, 100.02, h.GetSampleSum())
+	buckets := map[float64]uint64{}
+	for _, bucket := range h.Bucket {
+		buckets[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	require.Equal(t, uint64(0), buckets[0.01])
+	require.Equal(t, uint64(1), buckets[0.025])
+	require.Equal(t, uint64(1), buckets[60])
+
+	// The handler serves the same metrics.
+	response := httptest.NewRecorder()
+	Handler().ServeHTTP(response, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	served, err := (&expfmt.TextParser{}).TextToMetricFamilies(response.Body)
+	require.NoError(t, err)
+	require.Contains(t, served, "test_total")
+}
+
+func TestInstallHandlers(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`<Response><Errors><Error><Code>InvalidParameterValue</Code>` +
+				`<Message>invalid</Message></Error></Errors><RequestID>1</RequestID></Response>`))
+			return
+		}
+		w.Write([]byte(`<DescribeTagsResponse xmlns="http://ec2.amazonaws.com/doc/2016-09-15/"></DescribeTagsResponse>`))
+	}))
+	defer server.Close()
+
+	sess := session.New(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL).
+		WithMaxRetries(0).
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")))
+	InstallHandlers(&sess.Handlers)
+	client := ec2.New(sess)
+
+	_, err := client.DescribeTags(&ec2.DescribeTagsInput{})
+	require.Error(t, err)
+	require.Equal(t, 1.0, AWSRequests.Value("ec2", "DescribeTags", "InvalidParameterValue"))
+
+	fail = false
+	_, err = client.DescribeTags(&ec2.DescribeTagsInput{})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, AWSRequests.Value("ec2", "DescribeTags", ""))
+	require.Equal(t, uint64(2), AWSRequestDuration.Count("ec2", "DescribeTags"))
+}
+
+type testPlugin struct {
+	instance.Plugin
+}
+
+func (p testPlugin) Destroy(id instance.ID) error {
+	if id == "missing" {
+		return errors.New("missing")
+	}
+	return nil
+}
+
+func (p testPlugin) ExampleProperties() *types.Any {
+	return types.AnyString(`{}`)
+}
+
+func TestInstancePlugin(t *testing.T) {
+	plugin := InstancePlugin("test-resource", testPlugin{})
+
+	require.NoError(t, plugin.Destroy("i-1"))
+	require.Error(t, plugin.Destroy("missing"))
+	require.Error(t, plugin.Destroy("missing"))
+
+	require.Equal(t, 1.0, InstanceCalls.Value("test-resource", "Destroy", "success"))
+	require.Equal(t, 2.0, InstanceCalls.Value("test-resource", "Destroy", "error"))
+	require.Equal(t, uint64(3), InstanceCallDuration.Count("test-resource", "Destroy"))
+
+	// The optional interfaces are passed through.
+	require.Nil(t, plugin.(*instancePlugin).VendorInfo())
+	require.NotNil(t, plugin.(*instancePlugin).ExampleProperties())
+
+	failed := 0.0
+	for _, m := range parse(t)["infrakit_aws_instance_calls_total"].Metric {
+		labels := map[string]string{}
+		for _, label := range m.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["resource"] == "test-resource" && labels["method"] == "Destroy" && labels["result"] == "error" {
+			failed = m.GetCounter().GetValue()
+		}
+	}
+	require.Equal(t, 2.0, failed)
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/docker/infrakit.aws/plugin/passthrough"
+	"github.com/docker/infrakit/pkg/spi/instance"
+)
+
+var (
+	// AWSRequests counts the attempts of AWS API requests, by error code, which is empty for successes.
+	AWSRequests = NewCounter("infrakit_aws_api_requests_total", "Attempts of AWS API requests.",
+		"service", "operation", "error_code")
+
+	// AWSRequestDuration is the duration of the attempts of AWS API requests.
+	AWSRequestDuration = NewHistogram("infrakit_aws_api_request_duration_seconds",
+		"Duration of attempts of AWS API requests.", "service", "operation")
+
+	// InstanceCalls counts the calls of instance plugin methods, by result, success or error.
+	InstanceCalls = NewCounter("infrakit_aws_instance_calls_total", "Calls of instance plugin methods.",
+		"resource", "method", "result")
+
+	// InstanceCallDuration is the duration of the calls of instance plugin methods.
+	InstanceCallDuration = NewHistogram("infrakit_aws_instance_call_duration_seconds",
+		"Duration of calls of instance plugin methods.", "resource", "method")
+
+	// MonitorEvents counts the events published by instance monitors.
+	MonitorEvents = NewCounter("infrakit_aws_monitor_events_total", "Events published by instance monitors.",
+		"topic")
+
+	// TemplateRenderDuration is the duration of the renders of the metadata template.
+	TemplateRenderDuration = NewHistogram("infrakit_aws_metadata_template_render_duration_seconds",
+		"Duration of renders of the metadata template.", "result")
+)
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObserveRender records the duration of a render of the metadata template since start.
+func ObserveRender(start time.Time, err error) {
+	TemplateRenderDuration.Observe(time.Since(start).Seconds(), result(err))
+}
+
+// InstallHandlers installs handlers recording the attempts of the requests.
+func InstallHandlers(handlers *request.Handlers) {
+	observe := func(r *request.Request) {
+		code := ""
+		if r.Error != nil {
+			code = "Unknown"
+			if awsErr, is := r.Error.(awserr.Error); is {
+				code = awsErr.Code()
+			}
+		}
+		AWSRequests.Inc(r.ClientInfo.ServiceName, r.Operation.Name, code)
+		if !r.LastSignedAt.IsZero() {
+			AWSRequestDuration.Observe(time.Since(r.LastSignedAt).Seconds(), r.ClientInfo.ServiceName, r.Operation.Name)
+		}
+	}
+
+	// Failed attempts run the retry handlers, and successful ones end with the unmarshal handlers.
+	handlers.Retry.PushFront(observe)
+	handlers.Unmarshal.PushBack(func(r *request.Request) {
+		if r.Error == nil {
+			observe(r)
+		}
+	})
+}
+
+// instancePlugin records the calls of an instance plugin.
+type instancePlugin struct {
+	passthrough.Plugin
+	resource string
+}
+
+// InstancePlugin returns the instance plugin of the resource type recording its calls.
+func InstancePlugin(resource string, plugin instance.Plugin) instance.Plugin {
+	return &instancePlugin{Plugin: passthrough.Plugin{Plugin: plugin}, resource: resource}
+}
+
+func (p *instancePlugin) observe(method string, start time.Time, err error) {
+	InstanceCalls.Inc(p.resource, method, result(err))
+	InstanceCallDuration.Observe(time.Since(start).Seconds(), p.resource, method)
+}
+
+// Provision provisions an instance.
+func (p *instancePlugin) Provision(spec instance.Spec) (*instance.ID, error) {
+	start := time.Now()
+	id, err := p.Plugin.Provision(spec)
+	p.observe("Provision", start, err)
+	return id, err
+}
+
+// Label labels the instance.
+func (p *instancePlugin) Label(id instance.ID, labels map[string]string) error {
+	start := time.Now()
+	err := p.Plugin.Label(id, labels)
+	p.observe("Label", start, err)
+	return err
+}
+
+// Destroy destroys the instance.
+func (p *instancePlugin) Destroy(id instance.ID) error {
+	start := time.Now()
+	err := p.Plugin.Destroy(id)
+	p.observe("Destroy", start, err)
+	return err
+}
+
+// DescribeInstances describes the instances with the tags.
+func (p *instancePlugin) DescribeInstances(tags map[string]string, properties bool) ([]instance.Description, error) {
+	start := time.Now()
+	descriptions, err := p.Plugin.DescribeInstances(tags, properties)
+	p.observe("DescribeInstances", start, err)
+	return descriptions, err
+}
@@ -0,0 +1,35 @@
+// Package passthrough helps implement instance plugins that decorate others, e.g. to record or guard their calls.
+package passthrough
+
+import (
+	"github.com/docker/infrakit/pkg/spi"
+	"github.com/docker/infrakit/pkg/spi/instance"
+	"github.com/docker/infrakit/pkg/types"
+)
+
+// Plugin passes the calls of its methods through to the instance plugin it wraps, also those of the optional
+// interfaces of the SPI, so that a decorator embedding it only implements the methods it decorates.
+type Plugin struct {
+	instance.Plugin
+}
+
+// Wrapped returns the plugin wrapped, e.g. to check the optional interfaces it implements.
+func (p Plugin) Wrapped() instance.Plugin {
+	return p.Plugin
+}
+
+// VendorInfo returns the vendor info of the plugin, if any.
+func (p Plugin) VendorInfo() *spi.VendorInfo {
+	if vendor, is := p.Plugin.(spi.Vendor); is {
+		return vendor.VendorInfo()
+	}
+	return nil
+}
+
+// ExampleProperties returns the example properties of the plugin, if any.
+func (p Plugin) ExampleProperties() *types.Any {
+	if example, is := p.Plugin.(spi.InputExample); is {
+		return example.ExampleProperties()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Recorder is a transport that makes requests with another transport, and appends them and their responses to a
+// fixture, a JSONL file of interactions.
+type Recorder struct {
+	transport http.RoundTripper
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// NewRecorder returns a recorder appending to the fixture at the path, making requests with the transport, or
+// http.DefaultTransport if nil.
+func NewRecorder(path string, transport http.RoundTripper) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open fixture: %s", err)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport, file: file}, nil
+}
+
+// RoundTrip makes the request and records it with its response.  Requests failing without a response are not
+// recorded.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction, copied, err := newInteraction(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.transport.RoundTrip(copied)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction.Response = Response{StatusCode: resp.StatusCode, Header: map[string]string{}, Body: Scrub(string(body))}
+	for _, name := range responseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			interaction.Response.Header[name] = value
+		}
+	}
+	if err := r.append(interaction); err != nil {
+		return nil, fmt.Errorf("Failed to record %s: %s", interaction.Operation, err)
+	}
+	return resp, nil
+}
+
+// append appends the interaction to the fixture, on a line, with the markup of its bodies unescaped to be readable.
+func (r *Recorder) append(interaction Interaction) error {
+	buff := &bytes.Buffer{}
+	encoder := json.NewEncoder(buff)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(interaction); err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, err := r.file.Write(buff.Bytes())
+	return err
+}
+
+// Close closes the fixture.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,123 @@
+// Package recording records the AWS API requests of the plugins and their responses into fixture files, and replays
+// them, so that scenarios captured once against a real account can be tested offline.
+package recording
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Account is the account ID that account IDs are replaced with in fixtures.
+const Account = "123456789012"
+
+// Redacted replaces the credentials in fixtures.
+const Redacted = "REDACTED"
+
+// Interaction is an API request and its response, as recorded in a fixture.
+type Interaction struct {
+	// Service and Operation are the names of the AWS API operation, e.g. ec2 and DescribeInstances.
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the part of an API request needed to replay it.  Its headers, with the signature of the request, are
+// not recorded.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is an API response.
+type Response struct {
+	StatusCode int               `json:"status_code"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// responseHeaders are the response headers recorded.  Others, e.g. the CRC32 checksum of DynamoDB responses that
+// scrubbing invalidates, are dropped.
+var responseHeaders = []string{"Content-Type", "X-Amzn-Requestid", "X-Amz-Request-Id"}
+
+var (
+	// credentialScope is the credential scope of the signature of a request, <date>/<region>/<service>/aws4_request.
+	credentialScope = regexp.MustCompile(`Credential=[^/]*/[^/]*/[^/]*/([^/]*)/aws4_request`)
+
+	// accountIDs are account IDs, as in ARNs, owner IDs and queue URLs, also when URL encoded in query parameters.
+	accountIDs = regexp.MustCompile(`(^|[^0-9A-Za-z]|%2F|%3A)[0-9]{12}($|[^0-9A-Za-z]|%)`)
+
+	// accessKeyIDs are the IDs of long term and temporary access keys.
+	accessKeyIDs = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+
+	// secrets are the XML elements and JSON properties with secrets, e.g. the credentials returned by AssumeRole
+	// and the private key returned by CreateKeyPair.
+	secrets = regexp.MustCompile(`(<(SecretAccessKey|SessionToken|keyMaterial|passwordData)>)[^<]*(</)|` +
+		`("(SecretAccessKey|SessionToken|KeyMaterial|PasswordData)"\s*:\s*")[^"]*(")`)
+)
+
+// Scrub replaces the account IDs and credentials in the recorded text.
+func Scrub(s string) string {
+	s = secrets.ReplaceAllString(s, "${1}${4}"+Redacted+"${3}${6}")
+	s = accessKeyIDs.ReplaceAllString(s, "${1}"+strings.Repeat("X", 16))
+	return accountIDs.ReplaceAllString(s, "${1}"+Account+"${2}")
+}
+
+// operationOf returns the service and operation of the request and its body.
+func operationOf(req *http.Request, body []byte) (string, string) {
+	service := ""
+	if match := credentialScope.FindStringSubmatch(req.Header.Get("Authorization")); match != nil {
+		service = match[1]
+	}
+
+	// JSON protocols name the operation in the target header, as <API version>.<operation>, and query protocols in
+	// the Action parameter.
+	if target := req.Header.Get("X-Amz-Target"); target != "" {
+		return service, target[strings.LastIndex(target, ".")+1:]
+	}
+	if values, err := url.ParseQuery(string(body)); err == nil && values.Get("Action") != "" {
+		return service, values.Get("Action")
+	}
+	return service, req.URL.Query().Get("Action")
+}
+
+// readBody reads the body of the request, and replaces it with a reader of what was read.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	buff, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(buff))
+	return buff, nil
+}
+
+// newInteraction returns the interaction of the request, without its response, and a copy of the request to make
+// in its place, since reading the body of the request consumes it.
+func newInteraction(req *http.Request) (Interaction, *http.Request, error) {
+	copied := new(http.Request)
+	*copied = *req
+	body, err := readBody(copied)
+	if err != nil {
+		return Interaction{}, nil, err
+	}
+	service, operation := operationOf(req, body)
+	return Interaction{
+		Service:   service,
+		Operation: operation,
+		Request: Request{
+			Method: req.Method,
+			Path:   Scrub(req.URL.RequestURI()),
+			Body:   Scrub(string(body)),
+		},
+	}, copied, nil
+}
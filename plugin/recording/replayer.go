@@ -0,0 +1,122 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Read reads the interactions of the fixture at the path.
+func Read(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open fixture: %s", err)
+	}
+	defer file.Close()
+
+	interactions := []Interaction{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		interaction := Interaction{}
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("Invalid interaction at %s:%d: %s", path, line, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, scanner.Err()
+}
+
+// Replayer is a transport that serves the responses of a fixture instead of making requests.  Each interaction is
+// replayed once, to a request with the same method, path and body, so that the pages of a paginated operation or the
+// states of a resource are replayed in order.
+type Replayer struct {
+	lock         sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// NewReplayer returns a replayer of the fixture at the path.
+func NewReplayer(path string) (*Replayer, error) {
+	interactions, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replayer{interactions: interactions, replayed: make([]bool, len(interactions))}, nil
+}
+
+// next returns the first interaction not replayed yet with the same request, or an error if there is none, so that
+// requests deviating from the recorded ones fail rather than being served the responses of others.
+func (r *Replayer) next(request Interaction) (*Interaction, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	left := false
+	for i, interaction := range r.interactions {
+		if r.replayed[i] || interaction.Service != request.Service || interaction.Operation != request.Operation {
+			continue
+		}
+		if interaction.Request == request.Request {
+			r.replayed[i] = true
+			return &r.interactions[i], nil
+		}
+		left = true
+	}
+	if !left {
+		return nil, fmt.Errorf("No interaction left to replay for %s %s", request.Service, request.Operation)
+	}
+	return nil, fmt.Errorf("No interaction left to replay for %s %s matches the request %s %s %s",
+		request.Service, request.Operation, request.Request.Method, request.Request.Path, request.Request.Body)
+}
+
+// RoundTrip serves the response of the next interaction of the operation of the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, _, err := newInteraction(req)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction, err := r.next(request)
+	if err != nil {
+		return nil, err
+	}
+
+	status := interaction.Response.StatusCode
+	header := http.Header{}
+	for name, value := range interaction.Response.Header {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the interactions not replayed yet.
+func (r *Replayer) Remaining() []Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	remaining := []Interaction{}
+	for i, interaction := range r.interactions {
+		if !r.replayed[i] {
+			remaining = append(remaining, interaction)
+		}
+	}
+	return remaining
+}